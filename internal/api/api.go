@@ -2,6 +2,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -151,24 +153,95 @@ func paramName(ctx *gin.Context) (string, bool) {
 func recordingsOfPath(
 	pathConf *conf.Path,
 	pathName string,
+	start *time.Time,
+	end *time.Time,
 ) *defs.APIRecording {
 	ret := &defs.APIRecording{
 		Name: pathName,
 	}
 
-	segments, _ := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	segments, _ := recordstore.FindSegments(pathConf, pathName, start, end)
+
+	ret.SegmentCount = len(segments)
+	if len(segments) != 0 {
+		ret.SegmentPageCount = 1
+	}
 
 	ret.Segments = make([]*defs.APIRecordingSegment, len(segments))
 
 	for i, seg := range segments {
+		codecs := seg.Codecs
+		if codecs == nil {
+			codecs = []string{}
+		}
+
 		ret.Segments[i] = &defs.APIRecordingSegment{
-			Start: seg.Start,
+			Start:    seg.Start,
+			Duration: seg.Duration.Seconds(),
+			Size:     seg.Size,
+			Codecs:   codecs,
+		}
+	}
+
+	gaps, _ := recordstore.FindGaps(pathConf, pathName, segments)
+
+	ret.Gaps = make([]*defs.APIRecordingGap, len(gaps))
+
+	for i, g := range gaps {
+		ret.Gaps[i] = &defs.APIRecordingGap{
+			Start:  g.Start,
+			End:    g.End,
+			Reason: string(g.Reason),
+		}
+	}
+
+	locks, _ := recordstore.FindLocks(pathConf, pathName)
+
+	ret.Locks = make([]*defs.APIRecordingLock, len(locks))
+
+	for i, l := range locks {
+		ret.Locks[i] = &defs.APIRecordingLock{
+			ID:      l.ID,
+			Start:   l.Start,
+			End:     l.End,
+			Reason:  l.Reason,
+			Created: l.Created,
 		}
 	}
 
+	bookmarks, _ := recordstore.FindBookmarks(pathConf, pathName, start, end)
+
+	ret.Bookmarks = make([]*defs.APIRecordingBookmark, len(bookmarks))
+
+	for i, b := range bookmarks {
+		ret.Bookmarks[i] = apiRecordingBookmark(b)
+	}
+
 	return ret
 }
 
+func apiRecordingBookmark(b *recordstore.Bookmark) *defs.APIRecordingBookmark {
+	tags := b.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return &defs.APIRecordingBookmark{
+		ID:      b.ID,
+		Path:    b.Path,
+		Time:    b.Time,
+		End:     b.End,
+		Title:   b.Title,
+		Tags:    tags,
+		Author:  b.Author,
+		Created: b.Created,
+		Updated: b.Updated,
+	}
+}
+
+// key of the gin context that contains the name of the authenticated user.
+const authenticatedUserKey = "authenticatedUser"
+
 type apiAuthManager interface {
 	Authenticate(req *auth.Request) *auth.Error
 	RefreshJWTJWKS()
@@ -236,6 +309,7 @@ func (a *API) Initialize() error {
 
 	group.GET("/paths/list", a.onPathsList)
 	group.GET("/paths/get/*name", a.onPathsGet)
+	group.POST("/paths/switch/*name", a.onPathsSwitch)
 
 	if !interfaceIsEmpty(a.HLSServer) {
 		group.GET("/hlsmuxers/list", a.onHLSMuxersList)
@@ -285,6 +359,15 @@ func (a *API) Initialize() error {
 	group.GET("/recordings/list", a.onRecordingsList)
 	group.GET("/recordings/get/*name", a.onRecordingsGet)
 	group.DELETE("/recordings/deletesegment", a.onRecordingDeleteSegment)
+	group.DELETE("/recordings/deleterange", a.onRecordingDeleteRange)
+	group.GET("/recordings/verify", a.onRecordingsVerify)
+	group.POST("/recordings/lock", a.onRecordingsLock)
+	group.DELETE("/recordings/unlock", a.onRecordingsUnlock)
+	group.GET("/recordings/bookmarks/list", a.onRecordingsBookmarksList)
+	group.GET("/recordings/bookmarks/get", a.onRecordingsBookmarksGet)
+	group.POST("/recordings/bookmarks/add", a.onRecordingsBookmarksAdd)
+	group.PATCH("/recordings/bookmarks/patch", a.onRecordingsBookmarksPatch)
+	group.DELETE("/recordings/bookmarks/delete", a.onRecordingsBookmarksDelete)
 
 	// PTZ API routes
 	ptzGroup := group.Group("/ptz")
@@ -379,6 +462,8 @@ func (a *API) middlewareAuth(ctx *gin.Context) {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
+
+	ctx.Set(authenticatedUserKey, req.User)
 }
 
 func (a *API) onConfigGlobalGet(ctx *gin.Context) {
@@ -701,6 +786,36 @@ func (a *API) onPathsGet(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, data)
 }
 
+func (a *API) onPathsSwitch(ctx *gin.Context) {
+	pathName, ok := paramName(ctx)
+	if !ok {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid name"))
+		return
+	}
+
+	var req defs.APIPathSwitch
+	err := jsonwrapper.Decode(ctx.Request.Body, &req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	data, err := a.PathManager.APIPathsSwitch(pathName, req.Input)
+	if err != nil {
+		switch {
+		case errors.Is(err, conf.ErrPathNotFound):
+			a.writeError(ctx, http.StatusNotFound, err)
+		case errors.Is(err, defs.ErrPathNotSwitcher), errors.Is(err, defs.ErrSwitcherInputNotFound):
+			a.writeError(ctx, http.StatusBadRequest, err)
+		default:
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
 func (a *API) onRTSPConnsList(ctx *gin.Context) {
 	data, err := a.RTSPServer.APIConnsList()
 	if err != nil {
@@ -1184,7 +1299,7 @@ func (a *API) onRecordingsList(ctx *gin.Context) {
 
 	for i, pathName := range pathNames {
 		pathConf, _, _ := conf.FindPathConf(c.Paths, pathName)
-		data.Items[i] = recordingsOfPath(pathConf, pathName)
+		data.Items[i] = recordingsOfPath(pathConf, pathName, nil, nil)
 	}
 
 	ctx.JSON(http.StatusOK, data)
@@ -1207,7 +1322,30 @@ func (a *API) onRecordingsGet(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, recordingsOfPath(pathConf, pathName))
+	start, err := parseOptionalTime(ctx.Query("start"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'start' parameter: %w", err))
+		return
+	}
+
+	end, err := parseOptionalTime(ctx.Query("end"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end' parameter: %w", err))
+		return
+	}
+
+	data := recordingsOfPath(pathConf, pathName, start, end)
+
+	// segments are paginated only when requested, in order to keep compatibility.
+	if ctx.Query("itemsPerPage") != "" || ctx.Query("page") != "" {
+		data.SegmentPageCount, err = paginate(&data.Segments, ctx.Query("itemsPerPage"), ctx.Query("page"))
+		if err != nil {
+			a.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, data)
 }
 
 func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
@@ -1238,12 +1376,518 @@ func (a *API) onRecordingDeleteSegment(ctx *gin.Context) {
 		Start: start,
 	}.Encode(pathFormat)
 
+	locked, err := segmentIsLocked(pathConf, pathName, start)
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	if locked {
+		a.writeError(ctx, http.StatusConflict, recordstore.ErrSegmentLocked)
+		return
+	}
+
 	err = os.Remove(segmentPath)
 	if err != nil {
 		a.writeError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
+	err = recordstore.IndexSegmentRemove(pathConf, pathName, segmentPath)
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *API) onRecordingDeleteRange(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'start' parameter: %w", err))
+		return
+	}
+
+	end, err := time.Parse(time.RFC3339, ctx.Query("end"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end' parameter: %w", err))
+		return
+	}
+
+	if !end.After(start) {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("'end' must be after 'start'"))
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	locks, err := recordstore.FindRetentionLocks(pathConf, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	// segments that start inside the range are deleted.
+	// Nothing is deleted if at least one of them is locked.
+	var toDelete []*recordstore.Segment
+
+	for i, seg := range segments {
+		if seg.Start.Before(start) || !seg.Start.Before(end) {
+			continue
+		}
+
+		var next *recordstore.Segment
+		if i < (len(segments) - 1) {
+			next = segments[i+1]
+		}
+
+		if recordstore.SegmentIsLocked(locks, seg, next) {
+			a.writeError(ctx, http.StatusConflict, recordstore.ErrSegmentLocked)
+			return
+		}
+
+		toDelete = append(toDelete, seg)
+	}
+
+	if toDelete == nil {
+		a.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	for _, seg := range toDelete {
+		err = recordstore.RemoveSegment(pathConf, pathName, seg.Fpath)
+		if err != nil {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func parseOptionalTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func (a *API) onRecordingsVerify(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	start, err := parseOptionalTime(ctx.Query("start"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'start' parameter: %w", err))
+		return
+	}
+
+	end, err := parseOptionalTime(ctx.Query("end"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end' parameter: %w", err))
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := recordstore.Verify(pathConf, pathName, start, end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	data := &defs.APIRecordingVerification{
+		Name:     pathName,
+		Valid:    true,
+		Segments: make([]*defs.APIRecordingVerificationSegment, len(results)),
+	}
+
+	for i, res := range results {
+		seg := &defs.APIRecordingVerificationSegment{
+			Start:  res.Start,
+			Status: string(res.Status),
+		}
+
+		if res.Entry != nil {
+			seg.Hash = res.Entry.Hash
+			seg.Chain = res.Entry.Chain
+		}
+
+		if res.Status != recordstore.VerificationStatusOK {
+			data.Valid = false
+		}
+
+		data.Segments[i] = seg
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func segmentIsLocked(pathConf *conf.Path, pathName string, start time.Time) (bool, error) {
+	locks, err := recordstore.FindRetentionLocks(pathConf, pathName)
+	if err != nil {
+		return false, err
+	}
+
+	if len(locks) == 0 {
+		return false, nil
+	}
+
+	segments, _ := recordstore.FindSegments(pathConf, pathName, nil, nil)
+
+	for i, seg := range segments {
+		if seg.Start.Equal(start) {
+			var next *recordstore.Segment
+			if i < (len(segments) - 1) {
+				next = segments[i+1]
+			}
+			return recordstore.SegmentIsLocked(locks, seg, next), nil
+		}
+	}
+
+	return false, nil
+}
+
+func (a *API) onRecordingsLock(ctx *gin.Context) {
+	var req defs.APIRecordingLockAdd
+	err := jsonwrapper.Decode(ctx.Request.Body, &req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, req.Path)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	l := &recordstore.Lock{
+		Path:   req.Path,
+		Start:  req.Start,
+		End:    req.End,
+		Reason: req.Reason,
+	}
+
+	err = recordstore.AddLock(pathConf, l)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, &defs.APIRecordingLock{
+		ID:      l.ID,
+		Start:   l.Start,
+		End:     l.End,
+		Reason:  l.Reason,
+		Created: l.Created,
+	})
+}
+
+func (a *API) onRecordingsUnlock(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	id, err := uuid.Parse(ctx.Query("id"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'id' parameter: %w", err))
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = recordstore.RemoveLock(pathConf, pathName, id)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrLockNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *API) onRecordingsBookmarksList(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	start, err := parseOptionalTime(ctx.Query("start"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'start' parameter: %w", err))
+		return
+	}
+
+	end, err := parseOptionalTime(ctx.Query("end"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end' parameter: %w", err))
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	bookmarks, err := recordstore.FindBookmarks(pathConf, pathName, start, end)
+	if err != nil {
+		a.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	tag := ctx.Query("tag")
+	if tag != "" {
+		var filtered []*recordstore.Bookmark
+		for _, b := range bookmarks {
+			if slices.Contains(b.Tags, tag) {
+				filtered = append(filtered, b)
+			}
+		}
+		bookmarks = filtered
+	}
+
+	data := defs.APIRecordingBookmarkList{}
+
+	data.ItemCount = len(bookmarks)
+	pageCount, err := paginate(&bookmarks, ctx.Query("itemsPerPage"), ctx.Query("page"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	data.PageCount = pageCount
+
+	data.Items = make([]*defs.APIRecordingBookmark, len(bookmarks))
+
+	for i, b := range bookmarks {
+		data.Items[i] = apiRecordingBookmark(b)
+	}
+
+	ctx.JSON(http.StatusOK, data)
+}
+
+func (a *API) onRecordingsBookmarksGet(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	id, err := uuid.Parse(ctx.Query("id"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'id' parameter: %w", err))
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	b, err := recordstore.GetBookmark(pathConf, pathName, id)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrBookmarkNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiRecordingBookmark(b))
+}
+
+func (a *API) onRecordingsBookmarksAdd(ctx *gin.Context) {
+	var req defs.APIRecordingBookmarkAdd
+	err := jsonwrapper.Decode(ctx.Request.Body, &req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, req.Path)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	b := &recordstore.Bookmark{
+		Path:   req.Path,
+		Time:   req.Time,
+		End:    req.End,
+		Title:  req.Title,
+		Tags:   req.Tags,
+		Author: ctx.GetString(authenticatedUserKey),
+	}
+
+	err = recordstore.AddBookmark(pathConf, b)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiRecordingBookmark(b))
+}
+
+func (a *API) onRecordingsBookmarksPatch(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	id, err := uuid.Parse(ctx.Query("id"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'id' parameter: %w", err))
+		return
+	}
+
+	var req defs.APIRecordingBookmarkPatch
+	err = jsonwrapper.Decode(ctx.Request.Body, &req)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	b, err := recordstore.GetBookmark(pathConf, pathName, id)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrBookmarkNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	if req.Time != nil {
+		b.Time = *req.Time
+	}
+	if req.End != nil {
+		if string(req.End) == "null" {
+			b.End = nil
+		} else {
+			var end time.Time
+			err = json.Unmarshal(req.End, &end)
+			if err != nil {
+				a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'end': %w", err))
+				return
+			}
+			b.End = &end
+		}
+	}
+	if req.Title != nil {
+		b.Title = *req.Title
+	}
+	if req.Tags != nil {
+		b.Tags = *req.Tags
+	}
+
+	err = recordstore.UpdateBookmark(pathConf, b)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrBookmarkNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiRecordingBookmark(b))
+}
+
+func (a *API) onRecordingsBookmarksDelete(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	id, err := uuid.Parse(ctx.Query("id"))
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid 'id' parameter: %w", err))
+		return
+	}
+
+	a.mutex.RLock()
+	c := a.Conf
+	a.mutex.RUnlock()
+
+	pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+	if err != nil {
+		a.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	err = recordstore.RemoveBookmark(pathConf, pathName, id)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrBookmarkNotFound) {
+			a.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			a.writeError(ctx, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
 	ctx.Status(http.StatusOK)
 }
 
@@ -102,6 +102,10 @@ func (m *Manager) Authenticate(req *Request) *Error {
 		}
 	}
 
+	if req.User == "" && req.Credentials != nil {
+		req.User = req.Credentials.User
+	}
+
 	return nil
 }
 
@@ -236,6 +240,8 @@ func (m *Manager) authenticateJWT(req *Request) error {
 		return fmt.Errorf("user doesn't have permission to perform action")
 	}
 
+	req.User = cc.Subject
+
 	return nil
 }
 
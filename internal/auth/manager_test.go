@@ -473,6 +473,7 @@ func TestAuthJWT(t *testing.T) {
 			// second request
 			err2 = m.Authenticate(req)
 			require.Nil(t, err2)
+			require.Equal(t, "somebody", req.User)
 		})
 	}
 }
@@ -29,4 +29,8 @@ type Request struct {
 	Credentials      *Credentials
 	IP               net.IP
 	CustomVerifyFunc func(expectedUser string, expectedPass string) bool
+
+	// filled by Manager.Authenticate with the name of the authenticated user.
+	// With JWT, it is the subject of the token.
+	User string
 }
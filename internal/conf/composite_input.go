@@ -0,0 +1,11 @@
+package conf
+
+// CompositeInput is an input of a composite source.
+type CompositeInput struct {
+	// name of the path to read from.
+	Path string `json:"path"`
+	// types of the medias to read (video, audio or application). When empty, all medias are read.
+	Medias []string `json:"medias"`
+	// offset added to timestamps, in order to compensate the delay of the device.
+	Offset Duration `json:"offset"`
+}
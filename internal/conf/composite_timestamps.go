@@ -0,0 +1,58 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// CompositeTimestamps is the time reference used to align inputs of a composite source.
+type CompositeTimestamps int
+
+// supported values.
+const (
+	CompositeTimestampsNTP CompositeTimestamps = iota
+	CompositeTimestampsReceiveTime
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d CompositeTimestamps) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case CompositeTimestampsReceiveTime:
+		out = "receiveTime"
+
+	default:
+		out = "ntp"
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *CompositeTimestamps) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "ntp":
+		*d = CompositeTimestampsNTP
+
+	case "receiveTime":
+		*d = CompositeTimestampsReceiveTime
+
+	default:
+		return fmt.Errorf("invalid composite timestamps: '%s'", in)
+	}
+
+	return nil
+}
+
+// UnmarshalEnv implements env.Unmarshaler.
+func (d *CompositeTimestamps) UnmarshalEnv(_ string, v string) error {
+	return d.UnmarshalJSON([]byte(`"` + v + `"`))
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"reflect"
 	"slices"
@@ -207,14 +208,27 @@ type Conf struct {
 	PPROFTrustedProxies IPNetworks     `json:"pprofTrustedProxies"`
 
 	// Playback
-	Playback               bool           `json:"playback"`
-	PlaybackAddress        string         `json:"playbackAddress"`
-	PlaybackEncryption     bool           `json:"playbackEncryption"`
-	PlaybackServerKey      string         `json:"playbackServerKey"`
-	PlaybackServerCert     string         `json:"playbackServerCert"`
-	PlaybackAllowOrigin    *string        `json:"playbackAllowOrigin,omitempty"` // deprecated
-	PlaybackAllowOrigins   AllowedOrigins `json:"playbackAllowOrigins"`
-	PlaybackTrustedProxies IPNetworks     `json:"playbackTrustedProxies"`
+	Playback                bool           `json:"playback"`
+	PlaybackAddress         string         `json:"playbackAddress"`
+	PlaybackEncryption      bool           `json:"playbackEncryption"`
+	PlaybackServerKey       string         `json:"playbackServerKey"`
+	PlaybackServerCert      string         `json:"playbackServerCert"`
+	PlaybackAllowOrigin     *string        `json:"playbackAllowOrigin,omitempty"` // deprecated
+	PlaybackAllowOrigins    AllowedOrigins `json:"playbackAllowOrigins"`
+	PlaybackTrustedProxies  IPNetworks     `json:"playbackTrustedProxies"`
+	PlaybackSigningKey      string         `json:"playbackSigningKey"`
+	PlaybackExportPath      string         `json:"playbackExportPath"`
+	PlaybackExportWorkers   int            `json:"playbackExportWorkers"`
+	PlaybackExportRetention Duration       `json:"playbackExportRetention"`
+
+	// Upload
+	Upload          bool   `json:"upload"`
+	UploadEndpoint  string `json:"uploadEndpoint"`
+	UploadBucket    string `json:"uploadBucket"`
+	UploadRegion    string `json:"uploadRegion"`
+	UploadAccessKey string `json:"uploadAccessKey"`
+	UploadSecretKey string `json:"uploadSecretKey"`
+	UploadQueuePath string `json:"uploadQueuePath"`
 
 	// RTSP server
 	RTSP                  bool             `json:"rtsp"`
@@ -365,6 +379,13 @@ func (conf *Conf) setDefaults() {
 	conf.PlaybackServerKey = "server.key"
 	conf.PlaybackServerCert = "server.crt"
 	conf.PlaybackAllowOrigins = []string{"*"}
+	conf.PlaybackExportPath = "./exports"
+	conf.PlaybackExportWorkers = 2
+	conf.PlaybackExportRetention = 24 * Duration(time.Hour)
+
+	// Upload
+	conf.UploadRegion = "us-east-1"
+	conf.UploadQueuePath = "./upload-queue.jsonl"
 
 	// RTSP server
 	conf.RTSP = true
@@ -649,6 +670,31 @@ func (conf *Conf) Validate(l logger.Writer) error {
 		conf.PlaybackAllowOrigins = []string{*conf.PlaybackAllowOrigin}
 	}
 
+	if conf.PlaybackExportWorkers <= 0 {
+		return fmt.Errorf("'playbackExportWorkers' must be greater than zero")
+	}
+
+	// Upload
+
+	if conf.Upload {
+		if conf.UploadEndpoint == "" {
+			return fmt.Errorf("'uploadEndpoint' is empty")
+		}
+
+		u, err := url.Parse(conf.UploadEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid 'uploadEndpoint': %s", conf.UploadEndpoint)
+		}
+
+		if conf.UploadBucket == "" {
+			return fmt.Errorf("'uploadBucket' is empty")
+		}
+
+		if conf.UploadQueuePath == "" {
+			return fmt.Errorf("'uploadQueuePath' is empty")
+		}
+	}
+
 	// RTSP server
 
 	if conf.RTSPDisable != nil {
@@ -52,12 +52,24 @@ func TestConfFromFile(t *testing.T) {
 			Source:                       "publisher",
 			SourceOnDemandStartTimeout:   10 * Duration(time.Second),
 			SourceOnDemandCloseAfter:     10 * Duration(time.Second),
+			SourceFailover:               []string{},
+			SourceFailoverStallTimeout:   10 * Duration(time.Second),
+			SourceFailbackInterval:       30 * Duration(time.Second),
+			ReadTracks:                   []string{},
+			GOPCacheMaxSize:              20 * 1024 * 1024,
+			TimeShiftDuration:            60 * Duration(time.Second),
+			TimeShiftMaxSize:             100 * 1024 * 1024,
+			SwitcherInputs:               []string{},
+			CompositeInputs:              []CompositeInput{},
 			RecordPath:                   "./recordings/%path/%Y-%m-%d_%H-%M-%S-%f",
 			RecordFormat:                 RecordFormatFMP4,
 			RecordPartDuration:           Duration(1 * time.Second),
 			RecordMaxPartSize:            50 * 1024 * 1024,
 			RecordSegmentDuration:        3600000000000,
 			RecordDeleteAfter:            86400000000000,
+			HealthFrameTimeout:           5 * Duration(time.Second),
+			HealthFrozenTimeout:          10 * Duration(time.Second),
+			HealthMaxKeyFrameInterval:    10 * Duration(time.Second),
 			OverridePublisher:            true,
 			RPICameraWidth:               1920,
 			RPICameraHeight:              1080,
@@ -438,6 +450,129 @@ func TestConfErrors(t *testing.T) {
 				"    recordDeleteAfter: 20m\n",
 			`'recordDeleteAfter' cannot be lower than 'recordSegmentDuration'`,
 		},
+		{
+			"failover without static source",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    sourceFailover: [rtsp://localhost:8554/other]\n",
+			`'sourceFailover' can only be used when source is an URL`,
+		},
+		{
+			"invalid failover source",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    source: rtsp://localhost:8554/primary\n" +
+				"    sourceFailover: [publisher]\n",
+			`invalid 'sourceFailover': invalid source: 'publisher'`,
+		},
+		{
+			"failover source with username only",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    source: rtsp://localhost:8554/primary\n" +
+				"    sourceFailover: [rtmp://user@localhost/other]\n",
+			`invalid 'sourceFailover': username and password must be both provided`,
+		},
+		{
+			"standby with on-demand source",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    source: rtsp://localhost:8554/primary\n" +
+				"    sourceOnDemand: yes\n" +
+				"    standby: slate\n",
+			`'standby' can't be used with redirects and on-demand sources`,
+		},
+		{
+			"health restart with publisher",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    healthCheck: yes\n" +
+				"    healthRestartSource: yes\n",
+			`'healthRestartSource' can be used only with static sources`,
+		},
+		{
+			"time-shift without duration",
+			"paths:\n" +
+				"  my_path:\n" +
+				"    timeShift: yes\n" +
+				"    timeShiftDuration: 0s\n",
+			`'timeShiftDuration' must be greater than zero`,
+		},
+		{
+			"switcher without inputs",
+			"paths:\n" +
+				"  program:\n" +
+				"    source: switcher\n",
+			`'switcherInputs' must contain at least one path`,
+		},
+		{
+			"switcher with itself as input",
+			"paths:\n" +
+				"  program:\n" +
+				"    source: switcher\n" +
+				"    switcherInputs: [cam1, program]\n",
+			`'switcherInputs' can't contain the path itself`,
+		},
+		{
+			"switcher inputs without switcher",
+			"paths:\n" +
+				"  program:\n" +
+				"    switcherInputs: [cam1]\n",
+			`'switcherInputs' is useless when source is not 'switcher'`,
+		},
+		{
+			"record upload delete with hash chain",
+			"upload: yes\n" +
+				"uploadEndpoint: http://localhost:9000\n" +
+				"uploadBucket: recordings\n" +
+				"paths:\n" +
+				"  cam:\n" +
+				"    recordHashChain: yes\n" +
+				"    recordUpload: yes\n" +
+				"    recordUploadDelete: yes\n",
+			`'recordUploadDelete' and 'recordHashChain' cannot be used together`,
+		},
+		{
+			"invalid read tracks",
+			"paths:\n" +
+				"  cam:\n" +
+				"    readTracks: [video, subtitles]\n",
+			`invalid 'readTracks': invalid track selector: 'subtitles'`,
+		},
+		{
+			"read tracks with invalid name",
+			"paths:\n" +
+				"  cam:\n" +
+				"    readTracks: [video=main-cam]\n",
+			`invalid 'readTracks': invalid track selector: 'video=main-cam'`,
+		},
+		{
+			"composite without inputs",
+			"paths:\n" +
+				"  cam:\n" +
+				"    source: composite\n",
+			`'compositeInputs' must contain at least one path`,
+		},
+		{
+			"composite with invalid media type",
+			"paths:\n" +
+				"  cam:\n" +
+				"    source: composite\n" +
+				"    compositeInputs:\n" +
+				"      - path: camA\n" +
+				"        medias: [subtitles]\n",
+			`invalid media type in 'compositeInputs': 'subtitles'`,
+		},
+		{
+			"composite with invalid timestamps",
+			"paths:\n" +
+				"  cam:\n" +
+				"    source: composite\n" +
+				"    compositeInputs:\n" +
+				"      - path: camA\n" +
+				"    compositeTimestamps: pts\n",
+			`invalid composite timestamps: 'pts'`,
+		},
 	} {
 		t.Run(ca.name, func(t *testing.T) {
 			tmpf, err := createTempFile([]byte(ca.conf))
@@ -14,6 +14,7 @@ type RecordFormat int
 const (
 	RecordFormatFMP4 RecordFormat = iota
 	RecordFormatMPEGTS
+	RecordFormatMKV
 )
 
 // MarshalJSON implements json.Marshaler.
@@ -24,6 +25,9 @@ func (d RecordFormat) MarshalJSON() ([]byte, error) {
 	case RecordFormatMPEGTS:
 		out = "mpegts"
 
+	case RecordFormatMKV:
+		out = "mkv"
+
 	default:
 		out = "fmp4"
 	}
@@ -45,6 +49,9 @@ func (d *RecordFormat) UnmarshalJSON(b []byte) error {
 	case "fmp4":
 		*d = RecordFormatFMP4
 
+	case "mkv":
+		*d = RecordFormatMKV
+
 	default:
 		return fmt.Errorf("invalid record format '%s'", in)
 	}
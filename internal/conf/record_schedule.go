@@ -0,0 +1,221 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	// time zones must be available in Docker images, which don't contain a time zone database.
+	_ "time/tzdata"
+
+	"github.com/bluenviron/mediamtx/internal/conf/jsonwrapper"
+)
+
+// maximum number of days that are scanned in order to find the next change of a schedule.
+const recordScheduleHorizon = 8
+
+var recordScheduleDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// RecordScheduleDay is a day of the week of a recording schedule.
+type RecordScheduleDay time.Weekday
+
+// MarshalJSON implements json.Marshaler.
+func (d RecordScheduleDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(recordScheduleDays[d])
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *RecordScheduleDay) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	for i, day := range recordScheduleDays {
+		if strings.ToLower(in) == day {
+			*d = RecordScheduleDay(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid day '%s'", in)
+}
+
+// RecordScheduleTime is a time of the day of a recording schedule, in HH:MM format.
+type RecordScheduleTime time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d RecordScheduleTime) MarshalJSON() ([]byte, error) {
+	h, m := d.clock()
+	return json.Marshal(fmt.Sprintf("%02d:%02d", h, m))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *RecordScheduleTime) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	var h, m int
+	n, err := fmt.Sscanf(in, "%d:%d", &h, &m)
+	if err != nil || n != 2 || len(in) != 5 || h < 0 || m < 0 || m > 59 || h > 24 || (h == 24 && m != 0) {
+		return fmt.Errorf("invalid time '%s'", in)
+	}
+
+	*d = RecordScheduleTime(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute)
+	return nil
+}
+
+func (d RecordScheduleTime) clock() (int, int) {
+	return int(time.Duration(d).Hours()), int(time.Duration(d).Minutes()) % 60
+}
+
+// RecordScheduleDate is a date of a recording schedule, in YYYY-MM-DD format.
+type RecordScheduleDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d RecordScheduleDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *RecordScheduleDate) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := jsonwrapper.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	t, err := time.Parse("2006-01-02", in)
+	if err != nil {
+		return fmt.Errorf("invalid date '%s'", in)
+	}
+
+	d.Year, d.Month, d.Day = t.Date()
+	return nil
+}
+
+// RecordScheduleWindow is a time window in which recording is enabled.
+// When End is not after Start, the window ends on the following day.
+type RecordScheduleWindow struct {
+	Days  []RecordScheduleDay `json:"days"`
+	Start RecordScheduleTime  `json:"start"`
+	End   RecordScheduleTime  `json:"end"`
+}
+
+func (w RecordScheduleWindow) appliesTo(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bounds returns start and end of the window when it starts on the given date.
+// Bounds are built from the wall clock, since days in which DST changes are not 24 hours long.
+func (w RecordScheduleWindow) bounds(year int, month time.Month, day int, loc *time.Location) (time.Time, time.Time) {
+	h, m := w.Start.clock()
+	start := time.Date(year, month, day, h, m, 0, 0, loc)
+
+	endDay := day
+	if w.End <= w.Start {
+		endDay++
+	}
+
+	h, m = w.End.clock()
+	end := time.Date(year, month, endDay, h, m, 0, 0, loc)
+
+	return start, end
+}
+
+// RecordSchedule is a recording schedule.
+type RecordSchedule struct {
+	Windows    []RecordScheduleWindow
+	Location   *time.Location
+	Exceptions []RecordScheduleDate
+}
+
+func (s *RecordSchedule) isException(year int, month time.Month, day int) bool {
+	for _, e := range s.Exceptions {
+		if e.Year == year && e.Month == month && e.Day == day {
+			return true
+		}
+	}
+	return false
+}
+
+// windowsAround returns bounds of all windows that start around t.
+func (s *RecordSchedule) windowsAround(t time.Time, daysBefore int, daysAfter int) [][2]time.Time {
+	var ret [][2]time.Time
+
+	year, month, day := t.In(s.Location).Date()
+
+	for i := -daysBefore; i <= daysAfter; i++ {
+		date := time.Date(year, month, day+i, 0, 0, 0, 0, s.Location)
+		y, m, d := date.Date()
+
+		if s.isException(y, m, d) {
+			continue
+		}
+
+		for _, w := range s.Windows {
+			if w.appliesTo(date.Weekday()) {
+				start, end := w.bounds(y, m, d, s.Location)
+				ret = append(ret, [2]time.Time{start, end})
+			}
+		}
+	}
+
+	return ret
+}
+
+// Active checks whether recording is enabled at the given time.
+func (s *RecordSchedule) Active(t time.Time) bool {
+	for _, b := range s.windowsAround(t, 1, 0) {
+		if !t.Before(b[0]) && t.Before(b[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextChange returns the first time after t in which the state of the schedule changes.
+// If the state doesn't change in the next days, the returned time is the one
+// in which the schedule must be evaluated again.
+func (s *RecordSchedule) NextChange(t time.Time) time.Time {
+	var candidates []time.Time
+
+	for _, b := range s.windowsAround(t, 1, recordScheduleHorizon) {
+		for _, c := range b {
+			if c.After(t) {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Before(candidates[j])
+	})
+
+	cur := s.Active(t)
+
+	for _, c := range candidates {
+		if s.Active(c) != cur {
+			return c
+		}
+	}
+
+	return t.Add(24 * time.Hour)
+}
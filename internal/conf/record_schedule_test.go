@@ -0,0 +1,173 @@
+package conf
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordScheduleUnmarshal(t *testing.T) {
+	var windows []RecordScheduleWindow
+	err := json.Unmarshal([]byte(`[{"days":["mon","Fri"],"start":"09:00","end":"18:30"}]`), &windows)
+	require.NoError(t, err)
+
+	require.Equal(t, []RecordScheduleWindow{{
+		Days:  []RecordScheduleDay{RecordScheduleDay(time.Monday), RecordScheduleDay(time.Friday)},
+		Start: RecordScheduleTime(9 * time.Hour),
+		End:   RecordScheduleTime(18*time.Hour + 30*time.Minute),
+	}}, windows)
+
+	enc, err := json.Marshal(windows)
+	require.NoError(t, err)
+	require.Equal(t, `[{"days":["mon","fri"],"start":"09:00","end":"18:30"}]`, string(enc))
+
+	for _, ca := range []string{`"9:00"`, `"25:00"`, `"10:60"`, `"24:01"`} {
+		var tm RecordScheduleTime
+		err = json.Unmarshal([]byte(ca), &tm)
+		require.Error(t, err, ca)
+	}
+
+	var day RecordScheduleDay
+	err = json.Unmarshal([]byte(`"monday"`), &day)
+	require.Error(t, err)
+
+	var date RecordScheduleDate
+	err = json.Unmarshal([]byte(`"2025-12-25"`), &date)
+	require.NoError(t, err)
+	require.Equal(t, RecordScheduleDate{Year: 2025, Month: time.December, Day: 25}, date)
+}
+
+func TestRecordSchedule(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Rome")
+	require.NoError(t, err)
+
+	s := &RecordSchedule{
+		Windows: []RecordScheduleWindow{
+			{ // business hours
+				Days: []RecordScheduleDay{
+					RecordScheduleDay(time.Monday),
+					RecordScheduleDay(time.Tuesday),
+					RecordScheduleDay(time.Wednesday),
+					RecordScheduleDay(time.Thursday),
+					RecordScheduleDay(time.Friday),
+				},
+				Start: RecordScheduleTime(9 * time.Hour),
+				End:   RecordScheduleTime(18 * time.Hour),
+			},
+			{ // saturday night, until sunday morning
+				Days:  []RecordScheduleDay{RecordScheduleDay(time.Saturday)},
+				Start: RecordScheduleTime(22 * time.Hour),
+				End:   RecordScheduleTime(6 * time.Hour),
+			},
+		},
+		Location:   loc,
+		Exceptions: []RecordScheduleDate{{Year: 2025, Month: time.December, Day: 25}},
+	}
+
+	for _, ca := range []struct {
+		name   string
+		t      time.Time
+		active bool
+		next   time.Time
+	}{
+		{
+			"before business hours",
+			time.Date(2025, 12, 22, 8, 0, 0, 0, loc),
+			false,
+			time.Date(2025, 12, 22, 9, 0, 0, 0, loc),
+		},
+		{
+			"business hours",
+			time.Date(2025, 12, 22, 10, 0, 0, 0, loc),
+			true,
+			time.Date(2025, 12, 22, 18, 0, 0, 0, loc),
+		},
+		{
+			"time zone",
+			time.Date(2025, 12, 22, 8, 30, 0, 0, time.UTC),
+			true,
+			time.Date(2025, 12, 22, 18, 0, 0, 0, loc),
+		},
+		{
+			"exception",
+			time.Date(2025, 12, 25, 10, 0, 0, 0, loc),
+			false,
+			time.Date(2025, 12, 26, 9, 0, 0, 0, loc),
+		},
+		{
+			"before exception",
+			time.Date(2025, 12, 24, 19, 0, 0, 0, loc),
+			false,
+			time.Date(2025, 12, 26, 9, 0, 0, 0, loc),
+		},
+		{
+			"overnight",
+			time.Date(2025, 12, 28, 3, 0, 0, 0, loc),
+			true,
+			time.Date(2025, 12, 28, 6, 0, 0, 0, loc),
+		},
+		{
+			"friday evening",
+			time.Date(2025, 12, 26, 20, 0, 0, 0, loc),
+			false,
+			time.Date(2025, 12, 27, 22, 0, 0, 0, loc),
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.active, s.Active(ca.t))
+			require.True(t, ca.next.Equal(s.NextChange(ca.t)), s.NextChange(ca.t))
+		})
+	}
+}
+
+func TestRecordScheduleDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	s := &RecordSchedule{
+		Windows: []RecordScheduleWindow{{
+			Start: RecordScheduleTime(8 * time.Hour),
+			End:   RecordScheduleTime(20 * time.Hour),
+		}},
+		Location: loc,
+	}
+
+	for _, ca := range []struct {
+		name   string
+		t      time.Time
+		active bool
+		next   time.Time
+	}{
+		{
+			"last sunday of march, before start",
+			time.Date(2026, 3, 29, 7, 30, 0, 0, loc),
+			false,
+			time.Date(2026, 3, 29, 8, 0, 0, 0, loc),
+		},
+		{
+			"last sunday of march, after start",
+			time.Date(2026, 3, 29, 8, 30, 0, 0, loc),
+			true,
+			time.Date(2026, 3, 29, 20, 0, 0, 0, loc),
+		},
+		{
+			"last sunday of october, before start",
+			time.Date(2026, 10, 25, 7, 30, 0, 0, loc),
+			false,
+			time.Date(2026, 10, 25, 8, 0, 0, 0, loc),
+		},
+		{
+			"last sunday of october, before end",
+			time.Date(2026, 10, 25, 19, 30, 0, 0, loc),
+			true,
+			time.Date(2026, 10, 25, 20, 0, 0, 0, loc),
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.active, s.Active(ca.t))
+			require.True(t, ca.next.Equal(s.NextChange(ca.t)), s.NextChange(ca.t))
+		})
+	}
+}
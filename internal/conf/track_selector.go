@@ -0,0 +1,112 @@
+package conf
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var trackSelectorMediaTypes = []string{"video", "audio", "application"}
+
+// codec names, normalized, and their aliases.
+var trackSelectorCodecs = map[string][]string{
+	"av1":            {"av1"},
+	"vp8":            {"vp8"},
+	"vp9":            {"vp9"},
+	"h264":           {"h264"},
+	"h265":           {"h265"},
+	"hevc":           {"h265"},
+	"mjpeg":          {"mjpeg"},
+	"mpeg12video":    {"mpeg12video"},
+	"mpeg4video":     {"mpeg4video"},
+	"opus":           {"opus"},
+	"vorbis":         {"vorbis"},
+	"speex":          {"speex"},
+	"aac":            {"mpeg4audio", "mpeg4audiolatm"},
+	"mpeg4audio":     {"mpeg4audio"},
+	"mpeg4audiolatm": {"mpeg4audiolatm"},
+	"mp3":            {"mpeg12audio"},
+	"mpeg12audio":    {"mpeg12audio"},
+	"ac3":            {"ac3"},
+	"g711":           {"g711"},
+	"g722":           {"g722"},
+	"g726":           {"g726"},
+	"lpcm":           {"lpcm"},
+	"klv":            {"klv"},
+	"mpegts":         {"mpegts"},
+	"generic":        {"generic"},
+}
+
+// normalizeCodec converts a codec name into lower case and removes non-alphanumeric characters,
+// in order to convert "MPEG-4 Audio" into "mpeg4audio".
+func normalizeCodec(codec string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(codec) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// TrackSelector is a rule that selects or excludes tracks by media type or codec.
+type TrackSelector struct {
+	Exclude   bool
+	MediaType string
+	Codecs    []string
+	Name      string // name given to selected tracks
+}
+
+// Matches checks whether the selector matches a track.
+func (s TrackSelector) Matches(mediaType string, codec string) bool {
+	if s.MediaType != "" {
+		return mediaType == s.MediaType
+	}
+	return slices.Contains(s.Codecs, normalizeCodec(codec))
+}
+
+// ParseTrackSelectors parses track selectors.
+// Each selector is a media type (video, audio, application) or a codec (h264, aac, klv, ...),
+// optionally prefixed by "!" in order to exclude matching tracks,
+// or followed by "=name" in order to rename selected tracks.
+func ParseTrackSelectors(in []string) ([]TrackSelector, error) {
+	out := make([]TrackSelector, 0, len(in))
+
+	for _, entry := range in {
+		entry = strings.TrimSpace(entry)
+
+		var s TrackSelector
+
+		if strings.HasPrefix(entry, "!") {
+			s.Exclude = true
+			entry = entry[1:]
+		}
+
+		if i := strings.Index(entry, "="); i >= 0 {
+			s.Name = entry[i+1:]
+			entry = entry[:i]
+
+			// names are used as RTSP media IDs, that must be alphanumeric
+			if s.Exclude || s.Name == "" || strings.IndexFunc(s.Name, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+			}) >= 0 {
+				return nil, fmt.Errorf("invalid track selector: '%s'", entry+"="+s.Name)
+			}
+		}
+
+		name := strings.ToLower(entry)
+
+		if slices.Contains(trackSelectorMediaTypes, name) {
+			s.MediaType = name
+		} else if codecs, ok := trackSelectorCodecs[normalizeCodec(name)]; ok {
+			s.Codecs = codecs
+		} else {
+			return nil, fmt.Errorf("invalid track selector: '%s'", entry)
+		}
+
+		out = append(out, s)
+	}
+
+	return out, nil
+}
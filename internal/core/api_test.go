@@ -742,6 +742,7 @@ func TestAPIProtocolListGet(t *testing.T) {
 						map[string]any{
 							"bytesSent":   out1.(map[string]any)["items"].([]any)[0].(map[string]any)["bytesSent"],
 							"created":     out1.(map[string]any)["items"].([]any)[0].(map[string]any)["created"],
+							"delay":       "",
 							"lastRequest": out1.(map[string]any)["items"].([]any)[0].(map[string]any)["lastRequest"],
 							"path":        "mypath",
 						},
@@ -26,9 +26,11 @@ import (
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/metrics"
+	"github.com/bluenviron/mediamtx/internal/objectstore"
 	"github.com/bluenviron/mediamtx/internal/playback"
 	"github.com/bluenviron/mediamtx/internal/pprof"
 	"github.com/bluenviron/mediamtx/internal/recordcleaner"
+	"github.com/bluenviron/mediamtx/internal/recorduploader"
 	"github.com/bluenviron/mediamtx/internal/rlimit"
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
 	"github.com/bluenviron/mediamtx/internal/servers/rtmp"
@@ -56,20 +58,39 @@ var defaultConfPathsNotWin = []string{
 }
 
 var cli struct {
-	Confpath string `arg:"" default:""`
-	Version  bool   `help:"print version"`
-	Upgrade  bool   `help:"upgrade executable to the latest version"`
+	Run struct {
+		Confpath string `arg:"" default:""`
+	} `cmd:"" default:"withargs" help:"run the server"`
+	Repair struct {
+		Confpath string `arg:"" default:""`
+	} `cmd:"" help:"repair recordings left unfinalized by a crash, then exit"`
+	Version bool `help:"print version"`
+	Upgrade bool `help:"upgrade executable to the latest version"`
 }
 
-func atLeastOneRecordDeleteAfter(pathConfs map[string]*conf.Path) bool {
+func atLeastOneRecordCleanup(pathConfs map[string]*conf.Path) bool {
 	for _, e := range pathConfs {
-		if e.RecordDeleteAfter != 0 {
+		if e.RecordDeleteAfter != 0 || e.RecordDownsampleAfter != 0 {
 			return true
 		}
 	}
 	return false
 }
 
+func newObjectStoreClient(cnf *conf.Conf) *objectstore.Client {
+	if !cnf.Upload {
+		return nil
+	}
+
+	return &objectstore.Client{
+		Endpoint:  cnf.UploadEndpoint,
+		Bucket:    cnf.UploadBucket,
+		Region:    cnf.UploadRegion,
+		AccessKey: cnf.UploadAccessKey,
+		SecretKey: cnf.UploadSecretKey,
+	}
+}
+
 func getRTPMaxPayloadSize(udpMaxPayloadSize int, rtspEncryption conf.Encryption) int {
 	// UDP max payload size - 12 (RTP header)
 	v := udpMaxPayloadSize - 12
@@ -94,6 +115,7 @@ type Core struct {
 	metrics         *metrics.Metrics
 	pprof           *pprof.PPROF
 	recordCleaner   *recordcleaner.Cleaner
+	recordUploader  *recorduploader.Uploader
 	playbackServer  *playback.Server
 	pathManager     *pathManager
 	rtspServer      *rtsp.Server
@@ -131,7 +153,7 @@ func New(args []string) (*Core, bool) {
 		panic(err)
 	}
 
-	_, err = parser.Parse(args)
+	kctx, err := parser.Parse(args)
 	parser.FatalIfErrorf(err)
 
 	if cli.Version {
@@ -164,12 +186,30 @@ func New(args []string) (*Core, bool) {
 		confPaths = append(confPaths, defaultConfPathsNotWin...)
 	}
 
-	p.conf, p.confPath, err = conf.Load(cli.Confpath, confPaths, tempLogger)
+	repair := strings.HasPrefix(kctx.Command(), "repair")
+
+	confPath := cli.Run.Confpath
+	if repair {
+		confPath = cli.Repair.Confpath
+	}
+
+	p.conf, p.confPath, err = conf.Load(confPath, confPaths, tempLogger)
 	if err != nil {
 		fmt.Printf("ERR: %s\n", err)
 		return nil, false
 	}
 
+	// the repair command exits as soon as recordings have been repaired.
+	if repair {
+		err = repairRecordings(p.conf)
+		if err != nil {
+			fmt.Printf("ERR: %v\n", err)
+			return nil, false
+		}
+		close(p.done)
+		return p, true
+	}
+
 	err = p.createResources(true)
 	if err != nil {
 		if p.logger != nil {
@@ -360,28 +400,49 @@ func (p *Core) createResources(initial bool) error {
 	}
 
 	if p.recordCleaner == nil &&
-		atLeastOneRecordDeleteAfter(p.conf.Paths) {
+		atLeastOneRecordCleanup(p.conf.Paths) {
 		p.recordCleaner = &recordcleaner.Cleaner{
+			PathConfs:   p.conf.Paths,
+			ObjectStore: newObjectStoreClient(p.conf),
+			Parent:      p,
+		}
+		p.recordCleaner.Initialize()
+	}
+
+	if p.conf.Upload &&
+		p.recordUploader == nil {
+		i := &recorduploader.Uploader{
+			Client:    newObjectStoreClient(p.conf),
+			QueuePath: p.conf.UploadQueuePath,
 			PathConfs: p.conf.Paths,
 			Parent:    p,
 		}
-		p.recordCleaner.Initialize()
+		err = i.Initialize()
+		if err != nil {
+			return err
+		}
+		p.recordUploader = i
 	}
 
 	if p.conf.Playback &&
 		p.playbackServer == nil {
 		i := &playback.Server{
-			Address:        p.conf.PlaybackAddress,
-			Encryption:     p.conf.PlaybackEncryption,
-			ServerKey:      p.conf.PlaybackServerKey,
-			ServerCert:     p.conf.PlaybackServerCert,
-			AllowOrigins:   p.conf.PlaybackAllowOrigins,
-			TrustedProxies: p.conf.PlaybackTrustedProxies,
-			ReadTimeout:    p.conf.ReadTimeout,
-			WriteTimeout:   p.conf.WriteTimeout,
-			PathConfs:      p.conf.Paths,
-			AuthManager:    p.authManager,
-			Parent:         p,
+			Address:         p.conf.PlaybackAddress,
+			Encryption:      p.conf.PlaybackEncryption,
+			ServerKey:       p.conf.PlaybackServerKey,
+			ServerCert:      p.conf.PlaybackServerCert,
+			AllowOrigins:    p.conf.PlaybackAllowOrigins,
+			TrustedProxies:  p.conf.PlaybackTrustedProxies,
+			SigningKey:      p.conf.PlaybackSigningKey,
+			ExportPath:      p.conf.PlaybackExportPath,
+			ExportWorkers:   p.conf.PlaybackExportWorkers,
+			ExportRetention: p.conf.PlaybackExportRetention,
+			ObjectStore:     newObjectStoreClient(p.conf),
+			ReadTimeout:     p.conf.ReadTimeout,
+			WriteTimeout:    p.conf.WriteTimeout,
+			PathConfs:       p.conf.Paths,
+			AuthManager:     p.authManager,
+			Parent:          p,
 		}
 		err = i.Initialize()
 		if err != nil {
@@ -405,6 +466,7 @@ func (p *Core) createResources(initial bool) error {
 			pathConfs:         p.conf.Paths,
 			externalCmdPool:   p.externalCmdPool,
 			metrics:           p.metrics,
+			recordUploader:    p.recordUploader,
 			parent:            p,
 		}
 		p.pathManager.initialize()
@@ -733,8 +795,22 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeAuthManager ||
 		closeLogger
 
+	closeRecordUploader := newConf == nil ||
+		newConf.Upload != p.conf.Upload ||
+		newConf.UploadEndpoint != p.conf.UploadEndpoint ||
+		newConf.UploadBucket != p.conf.UploadBucket ||
+		newConf.UploadRegion != p.conf.UploadRegion ||
+		newConf.UploadAccessKey != p.conf.UploadAccessKey ||
+		newConf.UploadSecretKey != p.conf.UploadSecretKey ||
+		newConf.UploadQueuePath != p.conf.UploadQueuePath ||
+		closeLogger
+	if !closeRecordUploader && p.recordUploader != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
+		p.recordUploader.ReloadPathConfs(newConf.Paths)
+	}
+
 	closeRecorderCleaner := newConf == nil ||
-		atLeastOneRecordDeleteAfter(newConf.Paths) != atLeastOneRecordDeleteAfter(p.conf.Paths) ||
+		atLeastOneRecordCleanup(newConf.Paths) != atLeastOneRecordCleanup(p.conf.Paths) ||
+		closeRecordUploader ||
 		closeLogger
 	if !closeRecorderCleaner && p.recordCleaner != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
 		p.recordCleaner.ReloadPathConfs(newConf.Paths)
@@ -748,8 +824,13 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.PlaybackServerCert != p.conf.PlaybackServerCert ||
 		!slices.Equal(newConf.PlaybackAllowOrigins, p.conf.PlaybackAllowOrigins) ||
 		!reflect.DeepEqual(newConf.PlaybackTrustedProxies, p.conf.PlaybackTrustedProxies) ||
+		newConf.PlaybackSigningKey != p.conf.PlaybackSigningKey ||
+		newConf.PlaybackExportPath != p.conf.PlaybackExportPath ||
+		newConf.PlaybackExportWorkers != p.conf.PlaybackExportWorkers ||
+		newConf.PlaybackExportRetention != p.conf.PlaybackExportRetention ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
+		closeRecordUploader ||
 		closeAuthManager ||
 		closeLogger
 	if !closePlaybackServer && p.playbackServer != nil && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
@@ -766,6 +847,7 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.UDPMaxPayloadSize != p.conf.UDPMaxPayloadSize ||
 		newConf.RTSPEncryption != p.conf.RTSPEncryption ||
 		closeMetrics ||
+		closeRecordUploader ||
 		closeAuthManager ||
 		closeLogger
 	if !closePathManager && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
@@ -990,6 +1072,11 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.recordCleaner = nil
 	}
 
+	if closeRecordUploader && p.recordUploader != nil {
+		p.recordUploader.Close()
+		p.recordUploader = nil
+	}
+
 	if closePPROF && p.pprof != nil {
 		p.pprof.Close()
 		p.pprof = nil
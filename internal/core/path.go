@@ -17,6 +17,8 @@ import (
 	"github.com/bluenviron/mediamtx/internal/hooks"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recorder"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/bluenviron/mediamtx/internal/recorduploader"
 	"github.com/bluenviron/mediamtx/internal/staticsources"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
@@ -27,6 +29,60 @@ func emptyTimer() *time.Timer {
 	return t
 }
 
+func apiTrackStats(strm *stream.Stream) []defs.APIPathTrackStats {
+	var mediaTypes []description.MediaType
+	for _, media := range strm.Desc.Medias {
+		for range media.Formats {
+			mediaTypes = append(mediaTypes, media.Type)
+		}
+	}
+
+	stats := strm.TrackStats()
+	ret := make([]defs.APIPathTrackStats, len(stats))
+
+	for i, st := range stats {
+		ret[i] = defs.APIPathTrackStats{
+			Codec:          st.Codec,
+			Bitrate:        st.Bitrate,
+			AverageBitrate: st.AverageBitrate,
+		}
+
+		if mediaTypes[i] == description.MediaTypeVideo {
+			ret[i].FPS = &st.FPS
+			ret[i].BFrames = &st.BFrames
+		}
+
+		if st.Width != 0 {
+			ret[i].Width = &st.Width
+			ret[i].Height = &st.Height
+		}
+
+		if st.Profile != "" {
+			ret[i].Profile = &st.Profile
+		}
+
+		if st.Level != "" {
+			ret[i].Level = &st.Level
+		}
+
+		if st.ChromaFormat != "" {
+			ret[i].ChromaFormat = &st.ChromaFormat
+		}
+
+		if st.KeyFrameInterval != 0 {
+			v := st.KeyFrameInterval.Seconds()
+			ret[i].KeyFrameInterval = &v
+		}
+
+		if st.SampleRate != 0 {
+			ret[i].SampleRate = &st.SampleRate
+			ret[i].ChannelCount = &st.ChannelCount
+		}
+	}
+
+	return ret
+}
+
 type pathParent interface {
 	logger.Writer
 	pathReady(*path)
@@ -64,6 +120,16 @@ type pathAPIPathsGetReq struct {
 	res  chan pathAPIPathsGetRes
 }
 
+type pathAPIPathsSwitchRes struct {
+	data *defs.APIPathSwitcher
+	err  error
+}
+
+type pathAPIPathsSwitchReq struct {
+	input string
+	res   chan pathAPIPathsSwitchRes
+}
+
 type path struct {
 	parentCtx         context.Context
 	logLevel          conf.LogLevel
@@ -78,6 +144,7 @@ type path struct {
 	matches           []string
 	wg                *sync.WaitGroup
 	externalCmdPool   *externalcmd.Pool
+	recordUploader    *recorduploader.Uploader
 	parent            pathParent
 
 	ctx                            context.Context
@@ -93,12 +160,19 @@ type path struct {
 	readers                        map[defs.Reader]struct{}
 	describeRequestsOnHold         []defs.PathDescribeReq
 	readerAddRequestsOnHold        []defs.PathAddReaderReq
+	standby                        *pathStandby
+	health                         *pathHealth
+	onHealthyHook                  func()
 	onDemandStaticSourceState      pathOnDemandState
 	onDemandStaticSourceReadyTimer *time.Timer
 	onDemandStaticSourceCloseTimer *time.Timer
 	onDemandPublisherState         pathOnDemandState
 	onDemandPublisherReadyTimer    *time.Timer
 	onDemandPublisherCloseTimer    *time.Timer
+	recordSchedule                 *conf.RecordSchedule
+	recordScheduleActive           bool
+	recordScheduleNextChange       time.Time
+	recordScheduleTimer            *time.Timer
 
 	// in
 	chReloadConf              chan *conf.Path
@@ -110,6 +184,8 @@ type path struct {
 	chAddReader               chan defs.PathAddReaderReq
 	chRemoveReader            chan defs.PathRemoveReaderReq
 	chAPIPathsGet             chan pathAPIPathsGetReq
+	chAPIPathsSwitch          chan pathAPIPathsSwitchReq
+	chHealthChange            chan pathHealthChange
 
 	// out
 	done chan struct{}
@@ -125,6 +201,7 @@ func (pa *path) initialize() {
 	pa.onDemandStaticSourceCloseTimer = emptyTimer()
 	pa.onDemandPublisherReadyTimer = emptyTimer()
 	pa.onDemandPublisherCloseTimer = emptyTimer()
+	pa.recordScheduleTimer = emptyTimer()
 	pa.chReloadConf = make(chan *conf.Path)
 	pa.chStaticSourceSetReady = make(chan defs.PathSourceStaticSetReadyReq)
 	pa.chStaticSourceSetNotReady = make(chan defs.PathSourceStaticSetNotReadyReq)
@@ -134,6 +211,8 @@ func (pa *path) initialize() {
 	pa.chAddReader = make(chan defs.PathAddReaderReq)
 	pa.chRemoveReader = make(chan defs.PathRemoveReaderReq)
 	pa.chAPIPathsGet = make(chan pathAPIPathsGetReq)
+	pa.chAPIPathsSwitch = make(chan pathAPIPathsSwitchReq)
+	pa.chHealthChange = make(chan pathHealthChange)
 	pa.done = make(chan struct{})
 
 	pa.Log(logger.Debug, "created")
@@ -196,6 +275,8 @@ func (pa *path) run() {
 		ExternalCmdEnv:  pa.ExternalCmdEnv(),
 	})
 
+	pa.updateRecordSchedule()
+
 	err := pa.runInner()
 
 	// call before destroying context
@@ -207,6 +288,7 @@ func (pa *path) run() {
 	pa.onDemandStaticSourceCloseTimer.Stop()
 	pa.onDemandPublisherReadyTimer.Stop()
 	pa.onDemandPublisherCloseTimer.Stop()
+	pa.recordScheduleTimer.Stop()
 
 	onUnInitHook()
 
@@ -266,6 +348,9 @@ func (pa *path) runInner() error {
 		case <-pa.onDemandPublisherCloseTimer.C:
 			pa.doOnDemandPublisherCloseTimer()
 
+		case <-pa.recordScheduleTimer.C:
+			pa.doRecordScheduleTimer()
+
 		case newConf := <-pa.chReloadConf:
 			pa.doReloadConf(newConf)
 
@@ -309,6 +394,12 @@ func (pa *path) runInner() error {
 		case req := <-pa.chAPIPathsGet:
 			pa.doAPIPathsGet(req)
 
+		case req := <-pa.chAPIPathsSwitch:
+			pa.doAPIPathsSwitch(req)
+
+		case change := <-pa.chHealthChange:
+			pa.doHealthChange(change)
+
 		case <-pa.ctx.Done():
 			return fmt.Errorf("terminated")
 		}
@@ -352,6 +443,18 @@ func (pa *path) doOnDemandPublisherCloseTimer() {
 	pa.onDemandPublisherStop("not needed by anyone")
 }
 
+func (pa *path) doRecordScheduleTimer() {
+	pa.updateRecordSchedule()
+
+	if pa.recorder != nil && !pa.shouldRecord() {
+		pa.stopRecording(recordstore.GapReasonOutsideSchedule)
+	}
+
+	if pa.stream != nil && pa.recorder == nil && pa.shouldRecord() {
+		pa.startRecording()
+	}
+}
+
 func (pa *path) doReloadConf(newConf *conf.Path) {
 	pa.confMutex.Lock()
 	oldConf := pa.conf
@@ -369,12 +472,28 @@ func (pa *path) doReloadConf(newConf *conf.Path) {
 			newConf.RecordPartDuration != oldConf.RecordPartDuration ||
 			newConf.RecordMaxPartSize != oldConf.RecordMaxPartSize ||
 			newConf.RecordSegmentDuration != oldConf.RecordSegmentDuration ||
-			newConf.RecordDeleteAfter != oldConf.RecordDeleteAfter) {
-		pa.recorder.Close()
-		pa.recorder = nil
+			newConf.RecordDeleteAfter != oldConf.RecordDeleteAfter ||
+			newConf.RecordHashChain != oldConf.RecordHashChain ||
+			newConf.RecordEncryptionKey != oldConf.RecordEncryptionKey ||
+			newConf.RecordEncryptionKeyFile != oldConf.RecordEncryptionKeyFile) {
+		if !newConf.Record {
+			pa.stopRecording(recordstore.GapReasonRecordingDisabled)
+		} else {
+			pa.stopRecording("")
+		}
 	}
 
-	if newConf.Record && pa.stream != nil && pa.recorder == nil {
+	if pa.health != nil {
+		pa.health.reloadConf(newConf)
+	}
+
+	pa.updateRecordSchedule()
+
+	if pa.recorder != nil && !pa.shouldRecord() {
+		pa.stopRecording(recordstore.GapReasonOutsideSchedule)
+	}
+
+	if pa.shouldRecord() && pa.stream != nil && pa.recorder == nil {
 		pa.startRecording()
 	}
 }
@@ -398,7 +517,7 @@ func (pa *path) doSourceStaticSetReady(req defs.PathSourceStaticSetReadyReq) {
 }
 
 func (pa *path) doSourceStaticSetNotReady(req defs.PathSourceStaticSetNotReadyReq) {
-	pa.setNotReady()
+	pa.setStandbyOrNotReady()
 
 	// send response before calling onDemandStaticSourceStop()
 	// in order to avoid a deadlock due to staticsources.Handler.stop()
@@ -558,6 +677,24 @@ func (pa *path) doAPIPathsGet(req pathAPIPathsGetReq) {
 				v := pa.source.APISourceDescribe()
 				return &v
 			}(),
+			SourceFailover: func() *defs.APIPathSourceFailover {
+				if source, ok := pa.source.(*staticsources.Handler); ok {
+					return source.APIFailoverDescribe()
+				}
+				return nil
+			}(),
+			Switcher: func() *defs.APIPathSwitcher {
+				if source, ok := pa.source.(*staticsources.Handler); ok {
+					return source.APISwitcherDescribe()
+				}
+				return nil
+			}(),
+			Health: func() *defs.APIPathHealth {
+				if pa.health == nil {
+					return nil
+				}
+				return pa.health.apiDescribe()
+			}(),
 			Ready: pa.isReady(),
 			ReadyTime: func() *time.Time {
 				if !pa.isReady() {
@@ -572,6 +709,12 @@ func (pa *path) doAPIPathsGet(req pathAPIPathsGetReq) {
 				}
 				return defs.MediasToCodecs(pa.stream.Desc.Medias)
 			}(),
+			TrackStats: func() []defs.APIPathTrackStats {
+				if !pa.isReady() {
+					return []defs.APIPathTrackStats{}
+				}
+				return apiTrackStats(pa.stream)
+			}(),
 			BytesReceived: func() uint64 {
 				if !pa.isReady() {
 					return 0
@@ -584,13 +727,37 @@ func (pa *path) doAPIPathsGet(req pathAPIPathsGetReq) {
 				}
 				return pa.stream.BytesSent()
 			}(),
-			Readers: func() []defs.APIPathSourceOrReader {
-				ret := []defs.APIPathSourceOrReader{}
+			ReaderSkips: func() uint64 {
+				if !pa.isReady() {
+					return 0
+				}
+				return pa.stream.ReaderSkips()
+			}(),
+			Readers: func() []defs.APIPathReader {
+				ret := []defs.APIPathReader{}
 				for r := range pa.readers {
-					ret = append(ret, r.APIReaderDescribe())
+					desc := r.APIReaderDescribe()
+					item := defs.APIPathReader{
+						Type: desc.Type,
+						ID:   desc.ID,
+					}
+					if rs, ok := r.(defs.ReaderWithSkips); ok {
+						item.Skips = rs.APIReaderSkips()
+					}
+					ret = append(ret, item)
 				}
 				return ret
 			}(),
+			Recording: pa.recorder != nil,
+			RecordSchedule: func() *defs.APIPathRecordSchedule {
+				if pa.recordSchedule == nil {
+					return nil
+				}
+				return &defs.APIPathRecordSchedule{
+					Active:     pa.recordScheduleActive,
+					NextChange: pa.recordScheduleNextChange,
+				}
+			}(),
 			PTZ: pa.conf.PTZ,
 			PTZType: func() string {
 				src := pa.conf.PTZSource
@@ -608,6 +775,17 @@ func (pa *path) doAPIPathsGet(req pathAPIPathsGetReq) {
 	}
 }
 
+func (pa *path) doAPIPathsSwitch(req pathAPIPathsSwitchReq) {
+	source, ok := pa.source.(*staticsources.Handler)
+	if !ok {
+		req.res <- pathAPIPathsSwitchRes{err: defs.ErrPathNotSwitcher}
+		return
+	}
+
+	data, err := source.APISwitcherSwitch(req.input)
+	req.res <- pathAPIPathsSwitchRes{data: data, err: err}
+}
+
 func (pa *path) SafeConf() *conf.Path {
 	pa.confMutex.RLock()
 	defer pa.confMutex.RUnlock()
@@ -701,12 +879,32 @@ func (pa *path) onDemandPublisherStop(reason string) {
 }
 
 func (pa *path) setReady(desc *description.Session, generateRTPPackets bool, fillNTP bool) error {
+	if pa.standby != nil {
+		pa.standby.close()
+		pa.standby = nil
+
+		err := pa.stream.SpliceSource(desc, generateRTPPackets)
+		if err == nil {
+			pa.Log(logger.Info, "source is available again, leaving standby")
+			return nil
+		}
+
+		pa.Log(logger.Warn, "source is not compatible with the stream (%v), readers will be disconnected", err)
+		pa.setNotReady()
+	}
+
 	pa.stream = &stream.Stream{
 		WriteQueueSize:     pa.writeQueueSize,
 		RTPMaxPayloadSize:  pa.rtpMaxPayloadSize,
 		Desc:               desc,
 		GenerateRTPPackets: generateRTPPackets,
 		FillNTP:            fillNTP,
+		Spliceable:         pa.conf.Standby != "" || pa.conf.Source == "switcher",
+		GOPCache:           pa.conf.GOPCache,
+		GOPCacheMaxSize:    uint64(pa.conf.GOPCacheMaxSize),
+		TimeShift:          pa.conf.TimeShift,
+		TimeShiftDuration:  time.Duration(pa.conf.TimeShiftDuration),
+		TimeShiftMaxSize:   uint64(pa.conf.TimeShiftMaxSize),
 		Parent:             pa.source,
 	}
 	err := pa.stream.Initialize()
@@ -716,10 +914,14 @@ func (pa *path) setReady(desc *description.Session, generateRTPPackets bool, fil
 
 	pa.readyTime = time.Now()
 
-	if pa.conf.Record {
+	if pa.shouldRecord() {
 		pa.startRecording()
 	}
 
+	if pa.conf.HealthCheck {
+		pa.startHealth()
+	}
+
 	pa.onNotReadyHook = hooks.OnReady(hooks.OnReadyParams{
 		Logger:          pa,
 		ExternalCmdPool: pa.externalCmdPool,
@@ -748,7 +950,30 @@ func (pa *path) consumeOnHoldRequests() {
 	pa.readerAddRequestsOnHold = nil
 }
 
+// setStandbyOrNotReady is called when the source is lost.
+// When a standby path is configured, readers are kept connected and media of the standby path is written
+// into the stream until the source is available again.
+func (pa *path) setStandbyOrNotReady() {
+	if pa.conf.Standby != "" && pa.standby == nil {
+		pa.standby = &pathStandby{
+			pathName:    pa.conf.Standby,
+			stream:      pa.stream,
+			pathManager: pa.parent,
+			parent:      pa,
+		}
+		pa.standby.initialize()
+		return
+	}
+
+	pa.setNotReady()
+}
+
 func (pa *path) setNotReady() {
+	if pa.standby != nil {
+		pa.standby.close()
+		pa.standby = nil
+	}
+
 	pa.parent.pathNotReady(pa)
 
 	for r := range pa.readers {
@@ -758,9 +983,14 @@ func (pa *path) setNotReady() {
 
 	pa.onNotReadyHook()
 
+	if pa.health != nil {
+		pa.health.close()
+		pa.health = nil
+		pa.onHealthyHook = nil
+	}
+
 	if pa.recorder != nil {
-		pa.recorder.Close()
-		pa.recorder = nil
+		pa.stopRecording(recordstore.GapReasonSourceOffline)
 	}
 
 	if pa.stream != nil {
@@ -769,15 +999,104 @@ func (pa *path) setNotReady() {
 	}
 }
 
+func (pa *path) startHealth() {
+	pa.health = &pathHealth{
+		conf:     pa.conf,
+		stream:   pa.stream,
+		chChange: pa.chHealthChange,
+		parent:   pa,
+	}
+	pa.health.initialize()
+}
+
+func (pa *path) doHealthChange(change pathHealthChange) {
+	if change.healthy {
+		if pa.onHealthyHook != nil {
+			pa.onHealthyHook()
+			pa.onHealthyHook = nil
+		}
+		return
+	}
+
+	pa.onHealthyHook = hooks.OnUnhealthy(hooks.OnUnhealthyParams{
+		Logger:          pa,
+		ExternalCmdPool: pa.externalCmdPool,
+		Conf:            pa.conf,
+		ExternalCmdEnv:  pa.ExternalCmdEnv(),
+		Desc:            pa.source.APISourceDescribe(),
+		Query:           pa.publisherQuery,
+		Issues:          change.issues,
+	})
+
+	if pa.conf.HealthRestartSource {
+		if source, ok := pa.source.(*staticsources.Handler); ok {
+			source.Restart("stream is unhealthy: " + strings.Join(change.issues, "; "))
+		}
+	}
+}
+
+// shouldRecord checks whether recording is enabled and, if there's a schedule, whether it is active.
+func (pa *path) shouldRecord() bool {
+	return pa.conf.Record && (pa.recordSchedule == nil || pa.recordScheduleActive)
+}
+
+// updateRecordSchedule evaluates the recording schedule and sets a timer that fires at its next change.
+func (pa *path) updateRecordSchedule() {
+	pa.recordScheduleTimer.Stop()
+	pa.recordScheduleTimer = emptyTimer()
+
+	pa.recordSchedule = pa.conf.GetRecordSchedule()
+	if pa.recordSchedule == nil {
+		pa.recordScheduleActive = false
+		pa.recordScheduleNextChange = time.Time{}
+		return
+	}
+
+	now := time.Now()
+	active := pa.recordSchedule.Active(now)
+
+	if pa.conf.Record && (active != pa.recordScheduleActive || pa.recordScheduleNextChange.IsZero()) {
+		if active {
+			pa.Log(logger.Info, "recording schedule is active")
+		} else {
+			pa.Log(logger.Info, "recording schedule is not active")
+		}
+	}
+
+	pa.recordScheduleActive = active
+	pa.recordScheduleNextChange = pa.recordSchedule.NextChange(now)
+	pa.recordScheduleTimer = time.NewTimer(pa.recordScheduleNextChange.Sub(now))
+}
+
 func (pa *path) startRecording() {
+	var encryptionKey recordstore.EncryptionKey
+
+	if pa.conf.RecordEncryptionKey != "" || pa.conf.RecordEncryptionKeyFile != "" {
+		keys, err := recordstore.LoadEncryptionKeys(pa.conf)
+		if err != nil {
+			pa.Log(logger.Error, "unable to load record encryption keys, recording is disabled: %v", err)
+			return
+		}
+		encryptionKey = keys[0]
+	}
+
+	desc, err := defs.ReaderDesc(pa.stream.Desc, "", pa.conf)
+	if err != nil {
+		pa.Log(logger.Error, "unable to select tracks to record, recording is disabled: %v", err)
+		return
+	}
+
 	pa.recorder = &recorder.Recorder{
 		PathFormat:      pa.conf.RecordPath,
 		Format:          pa.conf.RecordFormat,
 		PartDuration:    time.Duration(pa.conf.RecordPartDuration),
 		MaxPartSize:     pa.conf.RecordMaxPartSize,
 		SegmentDuration: time.Duration(pa.conf.RecordSegmentDuration),
+		HashChain:       pa.conf.RecordHashChain,
+		EncryptionKey:   encryptionKey,
 		PathName:        pa.name,
 		Stream:          pa.stream,
+		Desc:            desc,
 		OnSegmentCreate: func(segmentPath string) {
 			if pa.conf.RunOnRecordSegmentCreate != "" {
 				env := pa.ExternalCmdEnv()
@@ -793,6 +1112,10 @@ func (pa *path) startRecording() {
 			}
 		},
 		OnSegmentComplete: func(segmentPath string, segmentDuration time.Duration) {
+			if pa.conf.RecordUpload && pa.recordUploader != nil {
+				pa.recordUploader.Push(pa.name, segmentPath)
+			}
+
 			if pa.conf.RunOnRecordSegmentComplete != "" {
 				env := pa.ExternalCmdEnv()
 				env["MTX_SEGMENT_PATH"] = segmentPath
@@ -812,13 +1135,28 @@ func (pa *path) startRecording() {
 	pa.recorder.Initialize()
 }
 
+// stopRecording stops the recorder and stores the reason of the stop, if any.
+func (pa *path) stopRecording(reason recordstore.GapReason) {
+	recordPath := pa.recorder.PathFormat
+
+	pa.recorder.Close()
+	pa.recorder = nil
+
+	if reason != "" {
+		err := recordstore.AddRecordingStop(recordPath, pa.name, reason)
+		if err != nil {
+			pa.Log(logger.Warn, "unable to save recording stop: %v", err)
+		}
+	}
+}
+
 func (pa *path) executeRemoveReader(r defs.Reader) {
 	delete(pa.readers, r)
 }
 
 func (pa *path) executeRemovePublisher() {
 	if pa.stream != nil {
-		pa.setNotReady()
+		pa.setStandbyOrNotReady()
 	}
 
 	pa.source = nil
@@ -838,6 +1176,30 @@ func (pa *path) addReaderPost(req defs.PathAddReaderReq) {
 		return
 	}
 
+	delay, err := defs.ReaderDelay(req.AccessRequest.Query)
+	if err != nil {
+		req.Res <- defs.PathAddReaderRes{Err: err}
+		return
+	}
+
+	if delay > 0 {
+		if !pa.conf.TimeShift {
+			req.Res <- defs.PathAddReaderRes{Err: fmt.Errorf("time-shift is not enabled on this path")}
+			return
+		}
+
+		if delay > time.Duration(pa.conf.TimeShiftDuration) {
+			req.Res <- defs.PathAddReaderRes{Err: fmt.Errorf("delay is greater than 'timeShiftDuration'")}
+			return
+		}
+	}
+
+	_, err = defs.ReaderDesc(pa.stream.Desc, req.AccessRequest.Query, pa.conf)
+	if err != nil {
+		req.Res <- defs.PathAddReaderRes{Err: err}
+		return
+	}
+
 	pa.readers[req.Author] = struct{}{}
 
 	if pa.conf.HasOnDemandStaticSource() {
@@ -968,3 +1330,16 @@ func (pa *path) APIPathsGet(req pathAPIPathsGetReq) (*defs.APIPath, error) {
 		return nil, fmt.Errorf("terminated")
 	}
 }
+
+// APIPathsSwitch is called by api.
+func (pa *path) APIPathsSwitch(req pathAPIPathsSwitchReq) (*defs.APIPathSwitcher, error) {
+	req.res = make(chan pathAPIPathsSwitchRes)
+	select {
+	case pa.chAPIPathsSwitch <- req:
+		res := <-req.res
+		return res.data, res.err
+
+	case <-pa.ctx.Done():
+		return nil, fmt.Errorf("terminated")
+	}
+}
@@ -0,0 +1,343 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	healthCheckPeriod = 1 * time.Second
+
+	// a timestamp regression makes the stream unhealthy for this amount of time
+	healthRegressionHold = 10 * time.Second
+
+	// an encoder that keeps encoding a still image produces non-key frames
+	// that are smaller than this fraction of key frames.
+	healthFrozenMaxDeltaRatio = 100
+
+	// key frames of a still image differ by their headers only,
+	// therefore their size differs by less than this fraction.
+	healthFrozenMaxKeyFrameRatio = 100
+)
+
+type pathHealthTrack struct {
+	media *description.Media
+	forma format.Format
+
+	lastFrameTime      time.Time
+	lastKeyFrameTime   time.Time
+	lastChangeTime     time.Time
+	lastRegressionTime time.Time
+	curHash            uint32
+	lastHash           uint32
+	curSize            uint64
+	lastKeyFrameSize   uint64
+	maxPTS             int64
+	hasPTS             bool
+	regressions        uint64
+	bytes              uint64
+	bitrate            uint64
+}
+
+func (t *pathHealthTrack) onUnit(u *unit.Unit) {
+	now := time.Now()
+
+	for _, pkt := range u.RTPPackets {
+		t.curHash = crc32.Update(t.curHash, crc32.IEEETable, pkt.Payload)
+		t.curSize += uint64(len(pkt.Payload))
+		t.bytes += uint64(len(pkt.Payload))
+	}
+
+	// frames can be split into multiple units
+	if u.NilPayload() {
+		return
+	}
+
+	t.lastFrameTime = now
+
+	if t.media.Type == description.MediaTypeVideo {
+		if t.frameChanged(u, now) || t.lastChangeTime.IsZero() {
+			t.lastChangeTime = now
+		}
+	}
+
+	t.curHash = 0
+	t.curSize = 0
+
+	// video frames can be reordered, allow one second of difference
+	var tolerance int64
+	if t.media.Type == description.MediaTypeVideo {
+		tolerance = int64(t.forma.ClockRate())
+	}
+
+	switch {
+	case !t.hasPTS:
+		t.maxPTS = u.PTS
+		t.hasPTS = true
+
+	case u.PTS < (t.maxPTS - tolerance):
+		t.regressions++
+		t.lastRegressionTime = now
+		t.maxPTS = u.PTS
+
+	case u.PTS > t.maxPTS:
+		t.maxPTS = u.PTS
+	}
+}
+
+// frameChanged checks whether the current frame changes the image.
+func (t *pathHealthTrack) frameChanged(u *unit.Unit, now time.Time) bool {
+	// frames of formats without key frames are compared byte by byte.
+	if !stream.FormatHasKeyFrames(t.forma) {
+		changed := (t.curHash != t.lastHash)
+		t.lastHash = t.curHash
+		return changed
+	}
+
+	// headers of H264 and H265 frames change every frame, even when the image doesn't,
+	// therefore frames are compared by size.
+	if stream.IsKeyFrame(t.forma, u) {
+		t.lastKeyFrameTime = now
+
+		prev := t.lastKeyFrameSize
+		t.lastKeyFrameSize = t.curSize
+
+		diff := max(t.curSize, prev) - min(t.curSize, prev)
+		return diff > (t.curSize / healthFrozenMaxKeyFrameRatio)
+	}
+
+	return t.lastKeyFrameSize == 0 ||
+		t.curSize > (t.lastKeyFrameSize/healthFrozenMaxDeltaRatio)
+}
+
+// pathHealthChange is a transition of the state of a stream.
+type pathHealthChange struct {
+	healthy bool
+	issues  []string
+}
+
+// pathHealth is a watchdog that checks the stream of a path
+// and detects frozen and broken streams.
+// State transitions are sent to the path, that runs hooks in its own routine.
+type pathHealth struct {
+	conf     *conf.Path
+	stream   *stream.Stream
+	chChange chan<- pathHealthChange
+	parent   logger.Writer
+
+	ctx       context.Context
+	ctxCancel func()
+	reader    *stream.Reader
+	mutex     sync.Mutex
+	tracks    []*pathHealthTrack
+	start     time.Time
+	lastCheck time.Time
+	healthy   bool
+	since     time.Time
+	issues    []string
+
+	done chan struct{}
+}
+
+func (h *pathHealth) initialize() {
+	h.ctx, h.ctxCancel = context.WithCancel(context.Background())
+	h.start = time.Now()
+	h.lastCheck = h.start
+	h.healthy = true
+	h.since = h.start
+	h.issues = []string{}
+	h.done = make(chan struct{})
+
+	h.reader = &stream.Reader{
+		SkipBytesSent: true,
+		Parent:        h,
+	}
+
+	for _, media := range h.stream.Desc.Medias {
+		for _, forma := range media.Formats {
+			t := &pathHealthTrack{
+				media: media,
+				forma: forma,
+			}
+			h.tracks = append(h.tracks, t)
+
+			h.reader.OnData(media, forma, func(u *unit.Unit) error {
+				h.mutex.Lock()
+				defer h.mutex.Unlock()
+				t.onUnit(u)
+				return nil
+			})
+		}
+	}
+
+	h.stream.AddReader(h.reader)
+
+	go h.run()
+}
+
+func (h *pathHealth) close() {
+	h.ctxCancel()
+	<-h.done
+	h.stream.RemoveReader(h.reader)
+}
+
+func (h *pathHealth) reloadConf(newConf *conf.Path) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.conf = newConf
+}
+
+// Log implements logger.Writer.
+func (h *pathHealth) Log(level logger.Level, format string, args ...any) {
+	h.parent.Log(level, "[health] "+format, args...)
+}
+
+func (h *pathHealth) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(healthCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.check()
+
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
+
+func (h *pathHealth) check() {
+	h.mutex.Lock()
+
+	now := time.Now()
+	issues := h.findIssues(now)
+	wasHealthy := h.healthy
+	h.issues = issues
+	h.healthy = (len(issues) == 0)
+
+	if h.healthy != wasHealthy {
+		h.since = now
+	}
+
+	h.mutex.Unlock()
+
+	switch {
+	case wasHealthy && len(issues) != 0:
+		h.Log(logger.Warn, "stream is unhealthy: %s", strings.Join(issues, "; "))
+
+	case !wasHealthy && len(issues) == 0:
+		h.Log(logger.Info, "stream is healthy again")
+
+	default:
+		return
+	}
+
+	select {
+	case h.chChange <- pathHealthChange{healthy: len(issues) == 0, issues: issues}:
+	case <-h.ctx.Done():
+	}
+}
+
+func (h *pathHealth) findIssues(now time.Time) []string {
+	since := func(t time.Time) time.Duration {
+		if t.IsZero() {
+			t = h.start
+		}
+		return now.Sub(t)
+	}
+
+	issues := []string{}
+	elapsed := now.Sub(h.lastCheck)
+	h.lastCheck = now
+	var totalBitrate uint64
+
+	for i, t := range h.tracks {
+		name := fmt.Sprintf("track %d (%s)", i+1, t.forma.Codec())
+
+		switch {
+		case h.conf.HealthFrameTimeout > 0 && since(t.lastFrameTime) > time.Duration(h.conf.HealthFrameTimeout):
+			issues = append(issues, fmt.Sprintf("%s: no frames received in the last %v",
+				name, time.Duration(h.conf.HealthFrameTimeout)))
+
+		case t.media.Type == description.MediaTypeVideo:
+			if h.conf.HealthFrozenTimeout > 0 && since(t.lastChangeTime) > time.Duration(h.conf.HealthFrozenTimeout) {
+				issues = append(issues, fmt.Sprintf("%s: frozen, the same frame has been received for %v",
+					name, time.Duration(h.conf.HealthFrozenTimeout)))
+			}
+
+			if h.conf.HealthMaxKeyFrameInterval > 0 && stream.FormatHasKeyFrames(t.forma) &&
+				since(t.lastKeyFrameTime) > time.Duration(h.conf.HealthMaxKeyFrameInterval) {
+				issues = append(issues, fmt.Sprintf("%s: no key frames received in the last %v",
+					name, time.Duration(h.conf.HealthMaxKeyFrameInterval)))
+			}
+		}
+
+		if !t.lastRegressionTime.IsZero() && now.Sub(t.lastRegressionTime) < healthRegressionHold {
+			issues = append(issues, fmt.Sprintf("%s: timestamps are going backwards", name))
+		}
+
+		if elapsed > 0 {
+			t.bitrate = uint64(float64(t.bytes*8) / elapsed.Seconds())
+		}
+		t.bytes = 0
+		totalBitrate += t.bitrate
+	}
+
+	if h.conf.HealthMinBitrate > 0 && totalBitrate < uint64(h.conf.HealthMinBitrate) {
+		issues = append(issues, fmt.Sprintf("bitrate is %d bit/s, lower than %d bit/s",
+			totalBitrate, h.conf.HealthMinBitrate))
+	}
+
+	return issues
+}
+
+func (h *pathHealth) apiDescribe() *defs.APIPathHealth {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	timePtr := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+
+	tracks := make([]defs.APIPathHealthTrack, len(h.tracks))
+	for i, t := range h.tracks {
+		tracks[i] = defs.APIPathHealthTrack{
+			Codec:                t.forma.Codec(),
+			LastFrameTime:        timePtr(t.lastFrameTime),
+			LastKeyFrameTime:     timePtr(t.lastKeyFrameTime),
+			Bitrate:              t.bitrate,
+			TimestampRegressions: t.regressions,
+		}
+	}
+
+	return &defs.APIPathHealth{
+		State: func() defs.APIPathHealthState {
+			if h.healthy {
+				return defs.APIPathHealthStateHealthy
+			}
+			return defs.APIPathHealthStateUnhealthy
+		}(),
+		Since:  h.since,
+		Issues: h.issues,
+		Tracks: tracks,
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/externalcmd"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/metrics"
+	"github.com/bluenviron/mediamtx/internal/recorduploader"
 	"github.com/bluenviron/mediamtx/internal/servers/hls"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
@@ -29,6 +30,14 @@ func pathConfCanBeUpdated(oldPathConf *conf.Path, newPathConf *conf.Path) bool {
 	clone.RecordMaxPartSize = newPathConf.RecordMaxPartSize
 	clone.RecordSegmentDuration = newPathConf.RecordSegmentDuration
 	clone.RecordDeleteAfter = newPathConf.RecordDeleteAfter
+	clone.RecordDownsampleAfter = newPathConf.RecordDownsampleAfter
+	clone.RecordEncryptionKey = newPathConf.RecordEncryptionKey
+	clone.RecordEncryptionKeyFile = newPathConf.RecordEncryptionKeyFile
+	clone.RecordUpload = newPathConf.RecordUpload
+	clone.RecordUploadDelete = newPathConf.RecordUploadDelete
+	clone.RecordSchedule = newPathConf.RecordSchedule
+	clone.RecordScheduleTimezone = newPathConf.RecordScheduleTimezone
+	clone.RecordScheduleExceptions = newPathConf.RecordScheduleExceptions
 
 	clone.RPICameraBrightness = newPathConf.RPICameraBrightness
 	clone.RPICameraContrast = newPathConf.RPICameraContrast
@@ -81,6 +90,7 @@ type pathManager struct {
 	pathConfs         map[string]*conf.Path
 	externalCmdPool   *externalcmd.Pool
 	metrics           *metrics.Metrics
+	recordUploader    *recorduploader.Uploader
 	parent            pathManagerParent
 
 	ctx       context.Context
@@ -447,6 +457,7 @@ func (pm *pathManager) createPath(
 		matches:           matches,
 		wg:                &pm.wg,
 		externalCmdPool:   pm.externalCmdPool,
+		recordUploader:    pm.recordUploader,
 		parent:            pm,
 	}
 	pa.initialize()
@@ -636,3 +647,24 @@ func (pm *pathManager) APIPathsGet(name string) (*defs.APIPath, error) {
 		return nil, fmt.Errorf("terminated")
 	}
 }
+
+// APIPathsSwitch is called by api.
+func (pm *pathManager) APIPathsSwitch(name string, input string) (*defs.APIPathSwitcher, error) {
+	req := pathAPIPathsGetReq{
+		name: name,
+		res:  make(chan pathAPIPathsGetRes),
+	}
+
+	select {
+	case pm.chAPIPathsGet <- req:
+		res := <-req.res
+		if res.err != nil {
+			return nil, res.err
+		}
+
+		return res.path.APIPathsSwitch(pathAPIPathsSwitchReq{input: input})
+
+	case <-pm.ctx.Done():
+		return nil, fmt.Errorf("terminated")
+	}
+}
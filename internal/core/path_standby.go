@@ -0,0 +1,165 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	standbyRetryPause = 1 * time.Second
+)
+
+type pathStandbyPathManager interface {
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
+type pathStandbyReader struct {
+	ctx       context.Context
+	ctxCancel func()
+}
+
+// Close implements defs.Reader.
+func (r *pathStandbyReader) Close() {
+	r.ctxCancel()
+}
+
+// APIReaderDescribe implements defs.Reader.
+func (*pathStandbyReader) APIReaderDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "standby",
+		ID:   "",
+	}
+}
+
+// pathStandby reads a standby path and writes its media into the stream of a path
+// whose source is not available, allowing readers of the path to stay connected.
+type pathStandby struct {
+	pathName    string
+	stream      *stream.Stream
+	pathManager pathStandbyPathManager
+	parent      logger.Writer
+
+	ctx       context.Context
+	ctxCancel func()
+
+	done chan struct{}
+}
+
+func (s *pathStandby) initialize() {
+	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	s.done = make(chan struct{})
+
+	s.Log(logger.Info, "source is not available, switching to standby path '%s'", s.pathName)
+
+	go s.run()
+}
+
+func (s *pathStandby) close() {
+	s.ctxCancel()
+	<-s.done
+}
+
+// Log implements logger.Writer.
+func (s *pathStandby) Log(level logger.Level, format string, args ...any) {
+	s.parent.Log(level, "[standby] "+format, args...)
+}
+
+func (s *pathStandby) run() {
+	defer close(s.done)
+
+	for {
+		err := s.runInner()
+		if err != nil {
+			s.Log(logger.Warn, err.Error())
+		}
+
+		select {
+		case <-time.After(standbyRetryPause):
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *pathStandby) runInner() error {
+	r := &pathStandbyReader{}
+	r.ctx, r.ctxCancel = context.WithCancel(context.Background())
+	defer r.ctxCancel()
+
+	path, standbyStream, err := s.pathManager.AddReader(defs.PathAddReaderReq{
+		Author: r,
+		AccessRequest: defs.PathAccessRequest{
+			Name:     s.pathName,
+			SkipAuth: true,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	defer path.RemoveReader(defs.PathRemoveReaderReq{Author: r})
+
+	rdr := &stream.Reader{
+		SkipBytesSent: true,
+		Parent:        s,
+	}
+
+	n := s.setupTracks(standbyStream.Desc, rdr)
+	if n == 0 {
+		return fmt.Errorf("standby path '%s' has no tracks compatible with %s",
+			s.pathName, defs.MediasInfo(s.stream.Desc.Medias))
+	}
+
+	s.stream.Splice()
+
+	standbyStream.AddReader(rdr)
+	defer standbyStream.RemoveReader(rdr)
+
+	select {
+	case err = <-rdr.Error():
+		return err
+
+	case <-r.ctx.Done():
+		return fmt.Errorf("standby path closed")
+
+	case <-s.ctx.Done():
+		return nil
+	}
+}
+
+// setupTracks routes every track of the standby path to a compatible track of the stream.
+func (s *pathStandby) setupTracks(standbyDesc *description.Session, rdr *stream.Reader) int {
+	used := make(map[format.Format]struct{})
+	n := 0
+
+	for _, media := range s.stream.Desc.Medias {
+		for _, forma := range media.Formats {
+			standbyMedia, standbyFormat := stream.FindCompatibleFormat(standbyDesc, media.Type, forma, used)
+			if standbyFormat == nil {
+				continue
+			}
+
+			used[standbyFormat] = struct{}{}
+			n++
+
+			cmedia := media
+			cforma := forma
+
+			rdr.OnData(standbyMedia, standbyFormat, func(u *unit.Unit) error {
+				s.stream.WriteSplicedUnit(cmedia, cforma, u)
+				return nil
+			})
+		}
+	}
+
+	return n
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/bluenviron/gortsplib/v5"
 	"github.com/bluenviron/gortsplib/v5/pkg/base"
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
 	"github.com/bluenviron/gortsplib/v5/pkg/headers"
 	"github.com/bluenviron/gortsplib/v5/pkg/sdp"
 	srt "github.com/datarhei/gosrt"
@@ -27,6 +30,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/protocols/whip"
 	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/bluenviron/mediamtx/internal/unit"
 )
 
 type testServer struct {
@@ -779,7 +783,99 @@ func TestPathRecord(t *testing.T) {
 
 	files, err = os.ReadDir(filepath.Join(dir, "mystream"))
 	require.NoError(t, err)
-	require.Equal(t, 2, len(files))
+
+	// the recording stop is stored next to segments
+	var segments []string
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name(), ".") {
+			segments = append(segments, f.Name())
+		}
+	}
+	require.Equal(t, 2, len(segments))
+}
+
+func TestPathRecordSchedule(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rtsp-path-record")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Now().UTC()
+
+	p, ok := newInstance("api: yes\n" +
+		"recordPath: " + filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f") + "\n" +
+		"paths:\n" +
+		"  all_others:\n" +
+		"    record: yes\n" +
+		"    recordSchedule:\n" +
+		"      - start: \"00:00\"\n" +
+		"        end: \"00:00\"\n" +
+		"    recordScheduleTimezone: UTC\n" +
+		"    recordScheduleExceptions:\n" +
+		"      - \"" + now.AddDate(0, 0, -1).Format("2006-01-02") + "\"\n" +
+		"      - \"" + now.Format("2006-01-02") + "\"\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	media0 := test.UniqueMediaH264()
+
+	source := gortsplib.Client{}
+
+	err = source.StartRecording(
+		"rtsp://localhost:8554/mystream",
+		&description.Session{Medias: []*description.Media{media0}})
+	require.NoError(t, err)
+	defer source.Close()
+
+	writePackets := func(start int) {
+		for i := start; i < start+4; i++ {
+			err = source.WritePacketRTP(media0, &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: 1123 + uint16(i),
+					Timestamp:      45343 + 90000*uint32(i),
+					SSRC:           563423,
+				},
+				Payload: []byte{5},
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	writePackets(0)
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = os.Stat(filepath.Join(dir, "mystream"))
+	require.True(t, os.IsNotExist(err))
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var out map[string]any
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/mystream", nil, &out)
+	require.Equal(t, false, out["recording"])
+	require.Equal(t, false, out["recordSchedule"].(map[string]any)["active"])
+
+	httpRequest(t, hc, http.MethodPatch, "http://localhost:9997/v3/config/paths/patch/all_others", map[string]any{
+		"recordScheduleExceptions": []string{},
+	}, nil)
+
+	time.Sleep(500 * time.Millisecond)
+
+	writePackets(4)
+
+	time.Sleep(500 * time.Millisecond)
+
+	files, err := os.ReadDir(filepath.Join(dir, "mystream"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(files))
+
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/mystream", nil, &out)
+	require.Equal(t, true, out["recording"])
+	require.Equal(t, true, out["recordSchedule"].(map[string]any)["active"])
 }
 
 func TestPathFallback(t *testing.T) {
@@ -1010,3 +1106,948 @@ func TestPathOverridePublisher(t *testing.T) {
 		})
 	}
 }
+
+func TestPathSourceFailover(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  secondary:\n" +
+		"  failover:\n" +
+		"    source: rtsp://127.0.0.1:8555/primary\n" +
+		"    sourceFailover: [rtsp://127.0.0.1:8554/secondary]\n" +
+		"    sourceFailbackInterval: 1s\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	publisher := gortsplib.Client{}
+	err := publisher.StartRecording("rtsp://127.0.0.1:8554/secondary",
+		&description.Session{Medias: []*description.Media{test.UniqueMediaH264()}})
+	require.NoError(t, err)
+	defer publisher.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	waitActiveSource := func(active int) defs.APIPath {
+		for i := 0; ; i++ {
+			require.Less(t, i, 100)
+
+			var out defs.APIPath
+			httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/failover", nil, &out)
+			require.NotNil(t, out.SourceFailover)
+
+			if out.Ready && out.SourceFailover.ActiveSource == active {
+				return out
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	out := waitActiveSource(1)
+	require.False(t, out.SourceFailover.Sources[0].Healthy)
+	require.NotNil(t, out.SourceFailover.Sources[0].LastError)
+	require.True(t, out.SourceFailover.Sources[1].Healthy)
+	require.Equal(t, 0, out.SourceFailover.History[0].From)
+	require.Equal(t, 1, out.SourceFailover.History[0].To)
+
+	var stream *gortsplib.ServerStream
+
+	s := gortsplib.Server{
+		Handler: &testServer{
+			onDescribe: func(_ *gortsplib.ServerHandlerOnDescribeCtx,
+			) (*base.Response, *gortsplib.ServerStream, error) {
+				return &base.Response{
+					StatusCode: base.StatusOK,
+				}, stream, nil
+			},
+			onSetup: func(_ *gortsplib.ServerHandlerOnSetupCtx) (*base.Response, *gortsplib.ServerStream, error) {
+				return &base.Response{
+					StatusCode: base.StatusOK,
+				}, stream, nil
+			},
+			onPlay: func(_ *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
+				return &base.Response{
+					StatusCode: base.StatusOK,
+				}, nil
+			},
+		},
+		RTSPAddress: "127.0.0.1:8555",
+	}
+
+	err = s.Start()
+	require.NoError(t, err)
+	defer s.Close()
+
+	stream = &gortsplib.ServerStream{
+		Server: &s,
+		Desc:   &description.Session{Medias: []*description.Media{test.MediaH264}},
+	}
+	err = stream.Initialize()
+	require.NoError(t, err)
+	defer stream.Close()
+
+	out = waitActiveSource(0)
+	require.True(t, out.SourceFailover.Sources[0].Healthy)
+	require.Equal(t, "primary source is healthy again",
+		out.SourceFailover.History[len(out.SourceFailover.History)-1].Reason)
+}
+
+func TestPathStandby(t *testing.T) {
+	p, ok := newInstance("rtmp: no\n" +
+		"paths:\n" +
+		"  standby:\n" +
+		"  main:\n" +
+		"    standby: standby\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	startPublisher := func(pathName string, payload []byte) func() {
+		medi := test.UniqueMediaH264()
+
+		c := &gortsplib.Client{}
+		err := c.StartRecording("rtsp://localhost:8554/"+pathName,
+			&description.Session{Medias: []*description.Media{medi}})
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		terminate := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			ticker := time.NewTicker(20 * time.Millisecond)
+			defer ticker.Stop()
+
+			for i := 0; ; i++ {
+				select {
+				case <-ticker.C:
+				case <-terminate:
+					return
+				}
+
+				c.WritePacketRTP(medi, &rtp.Packet{ //nolint:errcheck
+					Header: rtp.Header{
+						Version:        2,
+						Marker:         true,
+						PayloadType:    96,
+						SequenceNumber: uint16(1000 + i),
+						Timestamp:      uint32(50000 + i*1800),
+						SSRC:           uint32(len(pathName)),
+					},
+					Payload: payload,
+				})
+			}
+		}()
+
+		return func() {
+			close(terminate)
+			<-done
+			c.Close()
+		}
+	}
+
+	closeStandby := startPublisher("standby", []byte{5, 2})
+	defer closeStandby()
+
+	closeMain := startPublisher("main", []byte{5, 1})
+
+	u, err := base.ParseURL("rtsp://localhost:8554/main")
+	require.NoError(t, err)
+
+	reader := gortsplib.Client{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+	}
+
+	err = reader.Start()
+	require.NoError(t, err)
+	defer reader.Close()
+
+	desc, _, err := reader.Describe(u)
+	require.NoError(t, err)
+
+	err = reader.SetupAll(desc.BaseURL, desc.Medias)
+	require.NoError(t, err)
+
+	recv := make(chan *rtp.Packet, 1000)
+
+	reader.OnPacketRTPAny(func(_ *description.Media, _ format.Format, pkt *rtp.Packet) {
+		recv <- pkt
+	})
+
+	_, err = reader.Play(nil)
+	require.NoError(t, err)
+
+	var ssrc uint32
+	var lastSeq uint16
+	var lastTS uint32
+
+	waitPayload := func(payload []byte) {
+		for {
+			select {
+			case pkt := <-recv:
+				if ssrc == 0 {
+					ssrc = pkt.SSRC
+				} else {
+					require.Equal(t, ssrc, pkt.SSRC)
+					require.Equal(t, lastSeq+1, pkt.SequenceNumber)
+					require.Greater(t, pkt.Timestamp-lastTS, uint32(0))
+					require.Less(t, pkt.Timestamp-lastTS, uint32(90000))
+				}
+				lastSeq = pkt.SequenceNumber
+				lastTS = pkt.Timestamp
+
+				if bytes.Equal(pkt.Payload, payload) {
+					return
+				}
+
+			case <-time.After(5 * time.Second):
+				t.Errorf("timed out")
+				return
+			}
+		}
+	}
+
+	waitPayload([]byte{5, 1})
+
+	closeMain()
+
+	waitPayload([]byte{5, 2})
+
+	closeMain = startPublisher("main", []byte{5, 1})
+	defer closeMain()
+
+	waitPayload([]byte{5, 1})
+}
+
+func TestPathSwitcher(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"rtmp: no\n" +
+		"paths:\n" +
+		"  cam1:\n" +
+		"  cam2:\n" +
+		"  program:\n" +
+		"    source: switcher\n" +
+		"    switcherInputs: [cam1, cam2]\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	startPublisher := func(pathName string, payload func(i int) []byte) func() {
+		medi := test.UniqueMediaH264()
+
+		c := &gortsplib.Client{}
+		err := c.StartRecording("rtsp://localhost:8554/"+pathName,
+			&description.Session{Medias: []*description.Media{medi}})
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		terminate := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			ticker := time.NewTicker(20 * time.Millisecond)
+			defer ticker.Stop()
+
+			for i := 0; ; i++ {
+				select {
+				case <-ticker.C:
+				case <-terminate:
+					return
+				}
+
+				c.WritePacketRTP(medi, &rtp.Packet{ //nolint:errcheck
+					Header: rtp.Header{
+						Version:        2,
+						Marker:         true,
+						PayloadType:    96,
+						SequenceNumber: uint16(1000 + i),
+						Timestamp:      uint32(50000 + i*1800),
+						SSRC:           uint32(len(pathName)),
+					},
+					Payload: payload(i),
+				})
+			}
+		}()
+
+		return func() {
+			close(terminate)
+			<-done
+			c.Close()
+		}
+	}
+
+	closeCam1 := startPublisher("cam1", func(int) []byte {
+		return []byte{5, 1}
+	})
+	defer closeCam1()
+
+	// cam2 sends a key frame every 10 frames
+	closeCam2 := startPublisher("cam2", func(i int) []byte {
+		if (i % 10) == 0 {
+			return []byte{5, 2}
+		}
+		return []byte{1, 2}
+	})
+	defer closeCam2()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var path defs.APIPath
+
+	for range 50 {
+		httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/program", nil, &path)
+		if path.Ready {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, true, path.Ready)
+	require.Equal(t, &defs.APIPathSwitcher{
+		Inputs:      []string{"cam1", "cam2"},
+		ActiveInput: "cam1",
+	}, path.Switcher)
+
+	u, err := base.ParseURL("rtsp://localhost:8554/program")
+	require.NoError(t, err)
+
+	reader := gortsplib.Client{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+	}
+
+	err = reader.Start()
+	require.NoError(t, err)
+	defer reader.Close()
+
+	desc, _, err := reader.Describe(u)
+	require.NoError(t, err)
+
+	err = reader.SetupAll(desc.BaseURL, desc.Medias)
+	require.NoError(t, err)
+
+	recv := make(chan *rtp.Packet, 1000)
+
+	reader.OnPacketRTPAny(func(_ *description.Media, _ format.Format, pkt *rtp.Packet) {
+		recv <- pkt
+	})
+
+	_, err = reader.Play(nil)
+	require.NoError(t, err)
+
+	var ssrc uint32
+	var lastSeq uint16
+	var lastTS uint32
+
+	waitPacket := func() *rtp.Packet {
+		select {
+		case pkt := <-recv:
+			if ssrc == 0 {
+				ssrc = pkt.SSRC
+			} else {
+				require.Equal(t, ssrc, pkt.SSRC)
+				require.Equal(t, lastSeq+1, pkt.SequenceNumber)
+				require.Greater(t, pkt.Timestamp-lastTS, uint32(0))
+				require.Less(t, pkt.Timestamp-lastTS, uint32(90000))
+			}
+			lastSeq = pkt.SequenceNumber
+			lastTS = pkt.Timestamp
+			return pkt
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out")
+			return nil
+		}
+	}
+
+	require.Equal(t, []byte{5, 1}, waitPacket().Payload)
+
+	var out defs.APIPathSwitcher
+	httpRequest(t, hc, http.MethodPost, "http://localhost:9997/v3/paths/switch/program",
+		defs.APIPathSwitch{Input: "cam2"}, &out)
+	require.Equal(t, []string{"cam1", "cam2"}, out.Inputs)
+
+	// the cut happens on a key frame of cam2
+	for {
+		pkt := waitPacket()
+		require.NotEqual(t, []byte{1, 2}, pkt.Payload)
+
+		if bytes.Equal(pkt.Payload, []byte{5, 2}) {
+			break
+		}
+	}
+
+	require.Equal(t, []byte{1, 2}, waitPacket().Payload)
+
+	httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/program", nil, &path)
+	require.Equal(t, &defs.APIPathSwitcher{
+		Inputs:      []string{"cam1", "cam2"},
+		ActiveInput: "cam2",
+	}, path.Switcher)
+
+	res, err := hc.Post("http://localhost:9997/v3/paths/switch/program", "application/json",
+		bytes.NewReader([]byte(`{"input":"cam3"}`)))
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestPathComposite(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"rtmp: no\n" +
+		"paths:\n" +
+		"  camA:\n" +
+		"  micB:\n" +
+		"  program:\n" +
+		"    source: composite\n" +
+		"    compositeInputs:\n" +
+		"      - path: camA\n" +
+		"        medias: [video]\n" +
+		"      - path: micB\n" +
+		"        medias: [audio]\n" +
+		"        offset: 200ms\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	startPublisher := func(pathName string, videoPayload []byte, audioPayload []byte) func() {
+		var medias []*description.Media
+
+		if videoPayload != nil {
+			medias = append(medias, test.UniqueMediaH264())
+		}
+
+		audioMedia := &description.Media{
+			Type:    description.MediaTypeAudio,
+			Formats: []format.Format{&format.Opus{PayloadTyp: 97, ChannelCount: 2}},
+		}
+		medias = append(medias, audioMedia)
+
+		c := &gortsplib.Client{}
+		err := c.StartRecording("rtsp://localhost:8554/"+pathName,
+			&description.Session{Medias: medias})
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		terminate := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			ticker := time.NewTicker(20 * time.Millisecond)
+			defer ticker.Stop()
+
+			for i := 0; ; i++ {
+				select {
+				case <-ticker.C:
+				case <-terminate:
+					return
+				}
+
+				if videoPayload != nil {
+					c.WritePacketRTP(medias[0], &rtp.Packet{ //nolint:errcheck
+						Header: rtp.Header{
+							Version:        2,
+							Marker:         true,
+							PayloadType:    96,
+							SequenceNumber: uint16(1000 + i),
+							Timestamp:      uint32(50000 + i*1800),
+							SSRC:           uint32(len(pathName)),
+						},
+						Payload: videoPayload,
+					})
+				}
+
+				c.WritePacketRTP(audioMedia, &rtp.Packet{ //nolint:errcheck
+					Header: rtp.Header{
+						Version:        2,
+						Marker:         true,
+						PayloadType:    97,
+						SequenceNumber: uint16(2000 + i),
+						Timestamp:      uint32(30000 + i*960),
+						SSRC:           uint32(len(pathName) + 1),
+					},
+					Payload: audioPayload,
+				})
+			}
+		}()
+
+		return func() {
+			close(terminate)
+			<-done
+			c.Close()
+		}
+	}
+
+	closeCamA := startPublisher("camA", []byte{5, 1}, []byte{0xf8, 1})
+	defer closeCamA()
+
+	closeMicB := startPublisher("micB", nil, []byte{0xf8, 2})
+	defer closeMicB()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	var path defs.APIPath
+
+	for range 50 {
+		httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/program", nil, &path)
+		if path.Ready {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.Equal(t, true, path.Ready)
+	require.Equal(t, []string{"H264", "Opus"}, path.Tracks)
+
+	u, err := base.ParseURL("rtsp://localhost:8554/program")
+	require.NoError(t, err)
+
+	reader := gortsplib.Client{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+	}
+
+	err = reader.Start()
+	require.NoError(t, err)
+	defer reader.Close()
+
+	desc, _, err := reader.Describe(u)
+	require.NoError(t, err)
+	require.Len(t, desc.Medias, 2)
+
+	err = reader.SetupAll(desc.BaseURL, desc.Medias)
+	require.NoError(t, err)
+
+	videoRecv := make(chan []byte, 1000)
+	audioRecv := make(chan []byte, 1000)
+
+	reader.OnPacketRTPAny(func(medi *description.Media, _ format.Format, pkt *rtp.Packet) {
+		if medi.Type == description.MediaTypeVideo {
+			videoRecv <- pkt.Payload
+		} else {
+			audioRecv <- pkt.Payload
+		}
+	})
+
+	_, err = reader.Play(nil)
+	require.NoError(t, err)
+
+	for _, recv := range []struct {
+		ch      chan []byte
+		payload []byte
+	}{
+		{videoRecv, []byte{5, 1}},
+		{audioRecv, []byte{0xf8, 2}},
+	} {
+		select {
+		case payload := <-recv.ch:
+			// RTP packets are generated again, key frames are preceded by parameters
+			require.True(t, bytes.HasSuffix(payload, recv.payload))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out")
+		}
+	}
+}
+
+func TestPathReadTracks(t *testing.T) {
+	p, ok := newInstance("rtmp: no\n" +
+		"hls: no\n" +
+		"webrtc: no\n" +
+		"paths:\n" +
+		"  all_others:\n" +
+		"    readTracks: [opus, video]\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	videoMedia := test.UniqueMediaH264()
+	audioMedia := &description.Media{
+		Type:    description.MediaTypeAudio,
+		Formats: []format.Format{&format.Opus{PayloadTyp: 97, ChannelCount: 2}},
+	}
+	klvMedia := &description.Media{
+		Type:    description.MediaTypeApplication,
+		Formats: []format.Format{&format.KLV{PayloadTyp: 98}},
+	}
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/mystream",
+		&description.Session{Medias: []*description.Media{videoMedia, audioMedia, klvMedia}})
+	require.NoError(t, err)
+	defer source.Close()
+
+	describe := func(query string) []description.MediaType {
+		u, err2 := base.ParseURL("rtsp://localhost:8554/mystream" + query)
+		require.NoError(t, err2)
+
+		c := gortsplib.Client{
+			Scheme: u.Scheme,
+			Host:   u.Host,
+		}
+		err2 = c.Start()
+		require.NoError(t, err2)
+		defer c.Close()
+
+		desc, _, err2 := c.Describe(u)
+		require.NoError(t, err2)
+
+		var types []description.MediaType
+		for _, medi := range desc.Medias {
+			types = append(types, medi.Type)
+		}
+		return types
+	}
+
+	require.Equal(t, []description.MediaType{
+		description.MediaTypeAudio,
+		description.MediaTypeVideo,
+	}, describe(""))
+
+	require.Equal(t, []description.MediaType{
+		description.MediaTypeApplication,
+	}, describe("?tracks=!video,!audio"))
+
+	// tracks can be renamed
+	u, err := base.ParseURL("rtsp://localhost:8554/mystream?tracks=video=cam")
+	require.NoError(t, err)
+
+	reader := gortsplib.Client{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+	}
+	err = reader.Start()
+	require.NoError(t, err)
+	defer reader.Close()
+
+	desc, _, err := reader.Describe(u)
+	require.NoError(t, err)
+	require.Len(t, desc.Medias, 1)
+	require.Equal(t, description.MediaTypeVideo, desc.Medias[0].Type)
+	require.Equal(t, "cam", desc.Medias[0].ID)
+
+	err = reader.SetupAll(desc.BaseURL, desc.Medias)
+	require.NoError(t, err)
+
+	recv := make(chan []byte, 10)
+
+	reader.OnPacketRTPAny(func(_ *description.Media, _ format.Format, pkt *rtp.Packet) {
+		select {
+		case recv <- pkt.Payload:
+		default:
+		}
+	})
+
+	_, err = reader.Play(nil)
+	require.NoError(t, err)
+
+	for i := range 3 {
+		err = source.WritePacketRTP(videoMedia, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         true,
+				PayloadType:    96,
+				SequenceNumber: uint16(1000 + i),
+				Timestamp:      uint32(50000 + i*1800),
+				SSRC:           123,
+			},
+			Payload: []byte{5, 1},
+		})
+		require.NoError(t, err)
+	}
+
+	select {
+	case payload := <-recv:
+		require.Equal(t, []byte{5, 1}, payload)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out")
+	}
+
+	// selections without tracks are refused
+	u, err = base.ParseURL("rtsp://localhost:8554/mystream?tracks=vp9")
+	require.NoError(t, err)
+
+	c := gortsplib.Client{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+	}
+	err = c.Start()
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, _, err = c.Describe(u)
+	require.EqualError(t, err, "bad status code: 400 (Bad Request)")
+}
+
+func TestPathTimeShift(t *testing.T) {
+	p, ok := newInstance("rtmp: no\n" +
+		"paths:\n" +
+		"  live:\n" +
+		"  main:\n" +
+		"    timeShift: yes\n" +
+		"    timeShiftDuration: 5s\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	medi := test.UniqueMediaH264()
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/main",
+		&description.Session{Medias: []*description.Media{medi}})
+	require.NoError(t, err)
+	defer source.Close()
+
+	var written atomic.Uint32
+	done := make(chan struct{})
+	terminate := make(chan struct{})
+	defer func() {
+		close(terminate)
+		<-done
+	}()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+
+		for i := 0; ; i++ {
+			select {
+			case <-ticker.C:
+			case <-terminate:
+				return
+			}
+
+			source.WritePacketRTP(medi, &rtp.Packet{ //nolint:errcheck
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: uint16(1000 + i),
+					Timestamp:      uint32(50000 + i*1800),
+					SSRC:           123,
+				},
+				Payload: []byte{5, byte(i)},
+			})
+			written.Store(uint32(i))
+		}
+	}()
+
+	time.Sleep(1500 * time.Millisecond)
+
+	t.Run("not enabled", func(t *testing.T) {
+		u, err2 := base.ParseURL("rtsp://localhost:8554/live?delay=1s")
+		require.NoError(t, err2)
+
+		live := gortsplib.Client{}
+		err2 = live.StartRecording("rtsp://localhost:8554/live",
+			&description.Session{Medias: []*description.Media{test.UniqueMediaH264()}})
+		require.NoError(t, err2)
+		defer live.Close()
+
+		reader := gortsplib.Client{
+			Scheme: u.Scheme,
+			Host:   u.Host,
+		}
+
+		err2 = reader.Start()
+		require.NoError(t, err2)
+		defer reader.Close()
+
+		desc, _, err2 := reader.Describe(u)
+		require.NoError(t, err2)
+
+		err2 = reader.SetupAll(desc.BaseURL, desc.Medias)
+		require.EqualError(t, err2, "bad status code: 400 (Bad Request)")
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		u, err2 := base.ParseURL("rtsp://localhost:8554/main?delay=1s")
+		require.NoError(t, err2)
+
+		reader := gortsplib.Client{
+			Scheme: u.Scheme,
+			Host:   u.Host,
+		}
+
+		err2 = reader.Start()
+		require.NoError(t, err2)
+		defer reader.Close()
+
+		desc, _, err2 := reader.Describe(u)
+		require.NoError(t, err2)
+
+		err2 = reader.SetupAll(desc.BaseURL, desc.Medias)
+		require.NoError(t, err2)
+
+		recv := make(chan *rtp.Packet, 1000)
+
+		reader.OnPacketRTPAny(func(_ *description.Media, _ format.Format, pkt *rtp.Packet) {
+			recv <- pkt
+		})
+
+		_, err2 = reader.Play(nil)
+		require.NoError(t, err2)
+
+		select {
+		case pkt := <-recv:
+			// stream is received with a delay of about 50 packets
+			require.Less(t, uint32(pkt.Payload[1]), written.Load()-25)
+
+		case <-time.After(5 * time.Second):
+			t.Errorf("timed out")
+		}
+	})
+}
+
+func TestPathHealth(t *testing.T) {
+	onUnhealthy := filepath.Join(os.TempDir(), "on_unhealthy")
+	defer os.Remove(onUnhealthy)
+
+	onHealthy := filepath.Join(os.TempDir(), "on_healthy")
+	defer os.Remove(onHealthy)
+
+	p, ok := newInstance(fmt.Sprintf("rtmp: no\n"+
+		"hls: no\n"+
+		"webrtc: no\n"+
+		"api: yes\n"+
+		"paths:\n"+
+		"  main:\n"+
+		"    healthCheck: yes\n"+
+		"    healthFrozenTimeout: 1s\n"+
+		"    runOnUnhealthy: sh -c 'echo \"$MTX_PATH $MTX_HEALTH_ISSUES\" > %s'\n"+
+		"    runOnHealthy: sh -c 'echo \"$MTX_PATH\" > %s'\n",
+		onUnhealthy, onHealthy))
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	medi := test.UniqueMediaH264()
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/main",
+		&description.Session{Medias: []*description.Media{medi}})
+	require.NoError(t, err)
+	defer source.Close()
+
+	var frozen atomic.Bool
+	frozen.Store(true)
+
+	done := make(chan struct{})
+	terminate := make(chan struct{})
+	defer func() {
+		close(terminate)
+		<-done
+	}()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(20 * time.Millisecond)
+		defer ticker.Stop()
+
+		for i := 0; ; i++ {
+			select {
+			case <-ticker.C:
+			case <-terminate:
+				return
+			}
+
+			payload := []byte{5, 1}
+			if !frozen.Load() {
+				payload = append([]byte{5}, bytes.Repeat([]byte{byte(i)}, 1+i%10)...)
+			}
+
+			source.WritePacketRTP(medi, &rtp.Packet{ //nolint:errcheck
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: uint16(1000 + i),
+					Timestamp:      uint32(50000 + i*1800),
+					SSRC:           123,
+				},
+				Payload: payload,
+			})
+		}
+	}()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	waitState := func(state defs.APIPathHealthState) defs.APIPathHealth {
+		for range 50 {
+			var out defs.APIPath
+			httpRequest(t, hc, http.MethodGet, "http://localhost:9997/v3/paths/get/main", nil, &out)
+
+			if out.Health != nil && out.Health.State == state {
+				return *out.Health
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		t.Errorf("state '%s' not reached", state)
+		return defs.APIPathHealth{}
+	}
+
+	health := waitState(defs.APIPathHealthStateUnhealthy)
+	require.Equal(t, []string{"track 1 (H264): frozen, the same frame has been received for 1s"}, health.Issues)
+	require.Equal(t, 1, len(health.Tracks))
+	require.NotNil(t, health.Tracks[0].LastKeyFrameTime)
+
+	frozen.Store(false)
+
+	waitState(defs.APIPathHealthStateHealthy)
+
+	time.Sleep(200 * time.Millisecond)
+
+	byts, err := os.ReadFile(onUnhealthy)
+	require.NoError(t, err)
+	require.Equal(t, "main track 1 (H264): frozen, the same frame has been received for 1s\n", string(byts))
+
+	byts, err = os.ReadFile(onHealthy)
+	require.NoError(t, err)
+	require.Equal(t, "main\n", string(byts))
+}
+
+func TestPathHealthFrozen(t *testing.T) {
+	tr := &pathHealthTrack{
+		media: &description.Media{Type: description.MediaTypeVideo},
+		forma: test.FormatH264,
+	}
+
+	frame := func(header byte, content []byte) {
+		nalu := append([]byte{content[0], header}, content[1:]...)
+		tr.onUnit(&unit.Unit{
+			Payload:    unit.PayloadH264{nalu},
+			RTPPackets: []*rtp.Packet{{Payload: nalu}},
+		})
+	}
+
+	idr := append([]byte{0x65}, bytes.Repeat([]byte{1, 2, 3, 4}, 500)...)
+	still := []byte{0x41, 0x9a, 0x01}
+	moving := append([]byte{0x41}, bytes.Repeat([]byte{5, 6, 7, 8}, 100)...)
+
+	frame(0, idr)
+	first := tr.lastChangeTime
+	require.False(t, first.IsZero())
+
+	// frames with a different header and the same content don't change the image
+	for i := byte(1); i < 10; i++ {
+		time.Sleep(time.Millisecond)
+
+		if i%5 == 0 {
+			frame(i, idr)
+		} else {
+			frame(i, still)
+		}
+	}
+
+	require.Equal(t, first, tr.lastChangeTime)
+
+	frame(10, moving)
+	require.True(t, tr.lastChangeTime.After(first))
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+// repairRecordings repairs the segments of every path that have been left unfinalized by a crash.
+func repairRecordings(c *conf.Conf) error {
+	failed := false
+
+	for _, pathName := range recordstore.FindAllPathsWithSegments(c.Paths) {
+		pathConf, _, err := conf.FindPathConf(c.Paths, pathName)
+		if err != nil {
+			continue
+		}
+
+		segments, err := recordstore.FindUnfinalizedSegments(pathConf, pathName)
+		if err != nil {
+			fmt.Printf("ERR: [path %s] %v\n", pathName, err)
+			failed = true
+			continue
+		}
+
+		for _, seg := range segments {
+			res, err := recordstore.RepairSegment(pathConf, pathName, seg)
+			if err != nil {
+				fmt.Printf("ERR: [path %s] unable to repair segment %s: %v\n", pathName, seg.Fpath, err)
+				failed = true
+				continue
+			}
+
+			switch {
+			case !res.Repaired:
+
+			case res.PartsKept == 0:
+				fmt.Printf("[path %s] segment %s contained no complete parts and has been deleted\n",
+					pathName, seg.Fpath)
+
+			default:
+				fmt.Printf("[path %s] segment %s repaired: salvaged %d parts (%v), dropped %d bytes\n",
+					pathName, seg.Fpath, res.PartsKept, res.Duration, res.BytesDropped)
+			}
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("some segments could not be repaired")
+	}
+
+	return nil
+}
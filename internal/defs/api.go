@@ -1,6 +1,7 @@
 package defs
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,6 +13,7 @@ import (
 type APIPathManager interface {
 	APIPathsList() (*APIPathList, error)
 	APIPathsGet(string) (*APIPath, error)
+	APIPathsSwitch(string, string) (*APIPathSwitcher, error)
 }
 
 // APIHLSServer contains methods used by the API and Metrics server.
@@ -74,20 +76,120 @@ type APIPathSourceOrReader struct {
 	ID   string `json:"id"`
 }
 
+// APIPathReader is a reader of a path.
+type APIPathReader struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Skips uint64 `json:"skips"`
+}
+
 // APIPath is a path.
 type APIPath struct {
-	Name          string                  `json:"name"`
-	ConfName      string                  `json:"confName"`
-	Source        *APIPathSourceOrReader  `json:"source"`
-	Ready         bool                    `json:"ready"`
-	ReadyTime     *time.Time              `json:"readyTime"`
-	Tracks        []string                `json:"tracks"`
-	BytesReceived uint64                  `json:"bytesReceived"`
-	BytesSent     uint64                  `json:"bytesSent"`
-	Readers       []APIPathSourceOrReader `json:"readers"`
-	PTZ           bool                    `json:"ptz"`     // PTZ enabled/disabled
-	PTZType       string                  `json:"ptzType"` // Exposed protocol only (e.g., onvif, isapi, hikvision)
-	PTZSource     string                  `json:"-"`       // Internal use only; hidden from API JSON
+	Name           string                 `json:"name"`
+	ConfName       string                 `json:"confName"`
+	Source         *APIPathSourceOrReader `json:"source"`
+	SourceFailover *APIPathSourceFailover `json:"sourceFailover"`
+	Switcher       *APIPathSwitcher       `json:"switcher"`
+	Health         *APIPathHealth         `json:"health"`
+	Ready          bool                   `json:"ready"`
+	ReadyTime      *time.Time             `json:"readyTime"`
+	Tracks         []string               `json:"tracks"`
+	TrackStats     []APIPathTrackStats    `json:"trackStats"`
+	BytesReceived  uint64                 `json:"bytesReceived"`
+	BytesSent      uint64                 `json:"bytesSent"`
+	ReaderSkips    uint64                 `json:"readerSkips"`
+	Readers        []APIPathReader        `json:"readers"`
+	Recording      bool                   `json:"recording"`
+	RecordSchedule *APIPathRecordSchedule `json:"recordSchedule"`
+	PTZ            bool                   `json:"ptz"`     // PTZ enabled/disabled
+	PTZType        string                 `json:"ptzType"` // Exposed protocol only (e.g., onvif, isapi, hikvision)
+	PTZSource      string                 `json:"-"`       // Internal use only; hidden from API JSON
+}
+
+// APIPathTrackStats are statistics of a track.
+type APIPathTrackStats struct {
+	Codec            string   `json:"codec"`
+	Width            *int     `json:"width"`
+	Height           *int     `json:"height"`
+	Profile          *string  `json:"profile"`
+	Level            *string  `json:"level"`
+	ChromaFormat     *string  `json:"chromaFormat"`
+	FPS              *float64 `json:"fps"`
+	KeyFrameInterval *float64 `json:"keyFrameInterval"`
+	BFrames          *bool    `json:"bFrames"`
+	SampleRate       *int     `json:"sampleRate"`
+	ChannelCount     *int     `json:"channelCount"`
+	Bitrate          uint64   `json:"bitrate"`
+	AverageBitrate   uint64   `json:"averageBitrate"`
+}
+
+// APIPathRecordSchedule is the state of the recording schedule of a path.
+type APIPathRecordSchedule struct {
+	Active     bool      `json:"active"`
+	NextChange time.Time `json:"nextChange"`
+}
+
+// APIPathSourceFailover is the state of sources of a path with multiple sources.
+type APIPathSourceFailover struct {
+	ActiveSource int                     `json:"activeSource"`
+	Sources      []APIPathFailoverSource `json:"sources"`
+	History      []APIPathFailoverEvent  `json:"history"`
+}
+
+// APIPathFailoverSource is a source of a path with multiple sources.
+type APIPathFailoverSource struct {
+	URL           string     `json:"url"`
+	Healthy       bool       `json:"healthy"`
+	Failures      uint64     `json:"failures"`
+	LastError     *string    `json:"lastError"`
+	LastErrorTime *time.Time `json:"lastErrorTime"`
+	LastReadyTime *time.Time `json:"lastReadyTime"`
+}
+
+// APIPathFailoverEvent is a switch between sources of a path.
+type APIPathFailoverEvent struct {
+	Time   time.Time `json:"time"`
+	From   int       `json:"from"`
+	To     int       `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+// APIPathSwitcher is the state of a path whose source is a switcher.
+type APIPathSwitcher struct {
+	Inputs       []string `json:"inputs"`
+	ActiveInput  string   `json:"activeInput"`
+	PendingInput *string  `json:"pendingInput"`
+}
+
+// APIPathHealthState is the health state of a path.
+type APIPathHealthState string
+
+// states.
+const (
+	APIPathHealthStateHealthy   APIPathHealthState = "healthy"
+	APIPathHealthStateUnhealthy APIPathHealthState = "unhealthy"
+)
+
+// APIPathHealth is the health of the stream of a path.
+type APIPathHealth struct {
+	State  APIPathHealthState   `json:"state"`
+	Since  time.Time            `json:"since"`
+	Issues []string             `json:"issues"`
+	Tracks []APIPathHealthTrack `json:"tracks"`
+}
+
+// APIPathHealthTrack is the health of a track.
+type APIPathHealthTrack struct {
+	Codec                string     `json:"codec"`
+	LastFrameTime        *time.Time `json:"lastFrameTime"`
+	LastKeyFrameTime     *time.Time `json:"lastKeyFrameTime"`
+	Bitrate              uint64     `json:"bitrate"`
+	TimestampRegressions uint64     `json:"timestampRegressions"`
+}
+
+// APIPathSwitch is the request body of a change of the active input of a switcher.
+type APIPathSwitch struct {
+	Input string `json:"input"`
 }
 
 // APIPathList is a list of paths.
@@ -99,10 +201,11 @@ type APIPathList struct {
 
 // APIHLSMuxer is an HLS muxer.
 type APIHLSMuxer struct {
-	Path        string    `json:"path"`
-	Created     time.Time `json:"created"`
-	LastRequest time.Time `json:"lastRequest"`
-	BytesSent   uint64    `json:"bytesSent"`
+	Path        string        `json:"path"`
+	Delay       conf.Duration `json:"delay"`
+	Created     time.Time     `json:"created"`
+	LastRequest time.Time     `json:"lastRequest"`
+	BytesSent   uint64        `json:"bytesSent"`
 }
 
 // APIHLSMuxerList is a list of HLS muxers.
@@ -385,13 +488,98 @@ type APIWebRTCSessionList struct {
 
 // APIRecordingSegment is a recording segment.
 type APIRecordingSegment struct {
-	Start time.Time `json:"start"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	Size     int64     `json:"size"`
+	Codecs   []string  `json:"codecs"`
+}
+
+// APIRecordingGap is a time range of a recording that has not been recorded.
+type APIRecordingGap struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
+}
+
+// APIRecordingLock is a time range of a recording that is excluded from deletion.
+type APIRecordingLock struct {
+	ID      uuid.UUID `json:"id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+}
+
+// APIRecordingLockAdd is the request body of a lock creation.
+type APIRecordingLockAdd struct {
+	Path   string    `json:"path"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
+}
+
+// APIRecordingBookmark is an annotated moment, or time range, of a recording.
+type APIRecordingBookmark struct {
+	ID      uuid.UUID  `json:"id"`
+	Path    string     `json:"path"`
+	Time    time.Time  `json:"time"`
+	End     *time.Time `json:"end"`
+	Title   string     `json:"title"`
+	Tags    []string   `json:"tags"`
+	Author  string     `json:"author"`
+	Created time.Time  `json:"created"`
+	Updated time.Time  `json:"updated"`
+}
+
+// APIRecordingBookmarkList is a list of bookmarks.
+type APIRecordingBookmarkList struct {
+	ItemCount int                     `json:"itemCount"`
+	PageCount int                     `json:"pageCount"`
+	Items     []*APIRecordingBookmark `json:"items"`
+}
+
+// APIRecordingBookmarkAdd is the request body of a bookmark creation.
+type APIRecordingBookmarkAdd struct {
+	Path  string     `json:"path"`
+	Time  time.Time  `json:"time"`
+	End   *time.Time `json:"end"`
+	Title string     `json:"title"`
+	Tags  []string   `json:"tags"`
+}
+
+// APIRecordingBookmarkPatch is the request body of a bookmark update.
+// End is kept raw in order to distinguish a missing field from null, that removes the end.
+type APIRecordingBookmarkPatch struct {
+	Time  *time.Time      `json:"time"`
+	End   json.RawMessage `json:"end"`
+	Title *string         `json:"title"`
+	Tags  *[]string       `json:"tags"`
 }
 
 // APIRecording is a recording.
 type APIRecording struct {
-	Name     string                 `json:"name"`
-	Segments []*APIRecordingSegment `json:"segments"`
+	Name             string                  `json:"name"`
+	SegmentCount     int                     `json:"segmentCount"`
+	SegmentPageCount int                     `json:"segmentPageCount"`
+	Segments         []*APIRecordingSegment  `json:"segments"`
+	Gaps             []*APIRecordingGap      `json:"gaps"`
+	Locks            []*APIRecordingLock     `json:"locks"`
+	Bookmarks        []*APIRecordingBookmark `json:"bookmarks"`
+}
+
+// APIRecordingVerificationSegment is the verification result of a recording segment.
+type APIRecordingVerificationSegment struct {
+	Start  time.Time `json:"start"`
+	Status string    `json:"status"`
+	Hash   string    `json:"hash"`
+	Chain  string    `json:"chain"`
+}
+
+// APIRecordingVerification is the verification result of a recording.
+type APIRecordingVerification struct {
+	Name     string                             `json:"name"`
+	Valid    bool                               `json:"valid"`
+	Segments []*APIRecordingVerificationSegment `json:"segments"`
 }
 
 // APIRecordingList is a list of recordings.
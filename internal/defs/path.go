@@ -1,6 +1,7 @@
 package defs
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
@@ -20,6 +21,12 @@ func (e PathNoStreamAvailableError) Error() string {
 	return fmt.Sprintf("no stream is available on path '%s'", e.PathName)
 }
 
+// ErrPathNotSwitcher is returned when the source of a path is not a switcher.
+var ErrPathNotSwitcher = errors.New("source of path is not a switcher")
+
+// ErrSwitcherInputNotFound is returned when an input is not among the inputs of a switcher.
+var ErrSwitcherInputNotFound = errors.New("input not found")
+
 // Path is a path.
 type Path interface {
 	Name() string
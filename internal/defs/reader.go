@@ -1,7 +1,47 @@
 package defs
 
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
 // Reader is an entity that can read a stream.
 type Reader interface {
 	Close()
 	APIReaderDescribe() APIPathSourceOrReader
 }
+
+// ReaderWithSkips is a Reader that counts the times it skipped to the next key frame.
+type ReaderWithSkips interface {
+	APIReaderSkips() uint64
+}
+
+// ReaderDelay returns the time-shift delay requested by a reader through the "delay" query parameter.
+// The delay can be a duration (30s, 1m) or a number of seconds.
+func ReaderDelay(query string) (time.Duration, error) {
+	v, _ := url.ParseQuery(query)
+
+	str := v.Get("delay")
+	if str == "" {
+		return 0, nil
+	}
+
+	var d time.Duration
+
+	if secs, err := strconv.ParseFloat(str, 64); err == nil {
+		d = time.Duration(secs * float64(time.Second))
+	} else {
+		d, err = time.ParseDuration(str)
+		if err != nil {
+			return 0, fmt.Errorf("invalid delay: %w", err)
+		}
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("invalid delay: must be positive")
+	}
+
+	return d, nil
+}
@@ -0,0 +1,132 @@
+package defs
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+func trackSelectorsMatch(selectors []conf.TrackSelector, media *description.Media) bool {
+	for _, s := range selectors {
+		for _, forma := range media.Formats {
+			if s.Matches(string(media.Type), forma.Codec()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SelectMedias returns a description that contains the medias selected by selectors.
+// Medias are sorted in the order of the selectors that include them,
+// or in their original order when there are only selectors that exclude medias.
+// Medias selected by a selector with a name are replaced by copies with the name as ID,
+// that share formats with the original ones.
+func SelectMedias(desc *description.Session, selectors []conf.TrackSelector) (*description.Session, error) {
+	if len(selectors) == 0 {
+		return desc, nil
+	}
+
+	var include []conf.TrackSelector
+	var exclude []conf.TrackSelector
+
+	for _, s := range selectors {
+		if s.Exclude {
+			exclude = append(exclude, s)
+		} else {
+			include = append(include, s)
+		}
+	}
+
+	var medias []*description.Media
+
+	names := make(map[*description.Media]string)
+
+	if len(include) != 0 {
+		for _, s := range include {
+			for _, media := range desc.Medias {
+				if !slices.Contains(medias, media) && trackSelectorsMatch([]conf.TrackSelector{s}, media) {
+					medias = append(medias, media)
+					if s.Name != "" {
+						names[media] = s.Name
+					}
+				}
+			}
+		}
+	} else {
+		medias = desc.Medias
+	}
+
+	medias = slices.DeleteFunc(slices.Clone(medias), func(media *description.Media) bool {
+		return trackSelectorsMatch(exclude, media)
+	})
+
+	if len(medias) == 0 {
+		return nil, fmt.Errorf("no track is selected among %s", MediasInfo(desc.Medias))
+	}
+
+	used := make(map[string]struct{})
+
+	for _, media := range medias {
+		if _, ok := names[media]; !ok && media.ID != "" {
+			used[media.ID] = struct{}{}
+		}
+	}
+
+	for i, media := range medias {
+		if name, ok := names[media]; ok {
+			// IDs must be unique
+			if _, ok = used[name]; ok {
+				return nil, fmt.Errorf("name '%s' is given to multiple tracks", name)
+			}
+			used[name] = struct{}{}
+
+			renamed := *media
+			renamed.ID = name
+			medias[i] = &renamed
+		}
+	}
+
+	return &description.Session{
+		Title:  desc.Title,
+		Medias: medias,
+	}, nil
+}
+
+// ReaderDesc returns the description of the medias that are sent to a reader.
+// Medias are selected through the "tracks" query parameter (video,aac,!klv),
+// or through the 'readTracks' setting of the path.
+func ReaderDesc(desc *description.Session, query string, pathConf *conf.Path) (*description.Session, error) {
+	v, _ := url.ParseQuery(query)
+
+	entries := pathConf.ReadTracks
+	if str := v.Get("tracks"); str != "" {
+		entries = strings.Split(str, ",")
+	}
+
+	selectors, err := conf.ParseTrackSelectors(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return SelectMedias(desc, selectors)
+}
+
+// MediaNames returns the names given by track selectors to the medias of a reader description,
+// that are the IDs of the medias that have been copied from the description of the stream.
+func MediaNames(streamDesc *description.Session, desc *description.Session) map[*description.Media]string {
+	names := make(map[*description.Media]string)
+
+	for _, media := range desc.Medias {
+		if !slices.Contains(streamDesc.Medias, media) {
+			names[media] = media.ID
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,56 @@
+package hooks
+
+import (
+	"strings"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/externalcmd"
+	"github.com/bluenviron/mediamtx/internal/logger"
+)
+
+// OnUnhealthyParams are the parameters of OnUnhealthy.
+type OnUnhealthyParams struct {
+	Logger          logger.Writer
+	ExternalCmdPool *externalcmd.Pool
+	Conf            *conf.Path
+	ExternalCmdEnv  externalcmd.Environment
+	Desc            defs.APIPathSourceOrReader
+	Query           string
+	Issues          []string
+}
+
+// OnUnhealthy is the OnUnhealthy hook.
+func OnUnhealthy(params OnUnhealthyParams) func() {
+	var env externalcmd.Environment
+
+	if params.Conf.RunOnUnhealthy != "" || params.Conf.RunOnHealthy != "" {
+		env = params.ExternalCmdEnv
+		env["MTX_QUERY"] = params.Query
+		env["MTX_SOURCE_TYPE"] = params.Desc.Type
+		env["MTX_SOURCE_ID"] = params.Desc.ID
+		env["MTX_HEALTH_ISSUES"] = strings.Join(params.Issues, "; ")
+	}
+
+	if params.Conf.RunOnUnhealthy != "" {
+		params.Logger.Log(logger.Info, "runOnUnhealthy command launched")
+		externalcmd.NewCmd(
+			params.ExternalCmdPool,
+			params.Conf.RunOnUnhealthy,
+			false,
+			env,
+			nil)
+	}
+
+	return func() {
+		if params.Conf.RunOnHealthy != "" {
+			params.Logger.Log(logger.Info, "runOnHealthy command launched")
+			externalcmd.NewCmd(
+				params.ExternalCmdPool,
+				params.Conf.RunOnHealthy,
+				false,
+				env,
+				nil)
+		}
+	}
+}
@@ -217,6 +217,31 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 					out += metric("paths_bytes_received", ta, int64(i.BytesReceived))
 					out += metric("paths_bytes_sent", ta, int64(i.BytesSent))
 					out += metric("paths_readers", ta, int64(len(i.Readers)))
+
+					for j, st := range i.TrackStats {
+						ta2 := tags(map[string]string{
+							"name":  i.Name,
+							"state": state,
+							"track": strconv.FormatInt(int64(j), 10),
+							"codec": st.Codec,
+						})
+						out += metric("paths_tracks_bitrate", ta2, int64(st.Bitrate))
+						out += metric("paths_tracks_average_bitrate", ta2, int64(st.AverageBitrate))
+						if st.FPS != nil {
+							out += metricFloat("paths_tracks_fps", ta2, *st.FPS)
+						}
+						if st.KeyFrameInterval != nil {
+							out += metricFloat("paths_tracks_key_frame_interval", ta2, *st.KeyFrameInterval)
+						}
+						if st.Width != nil {
+							out += metric("paths_tracks_width", ta2, int64(*st.Width))
+							out += metric("paths_tracks_height", ta2, int64(*st.Height))
+						}
+						if st.SampleRate != nil {
+							out += metric("paths_tracks_sample_rate", ta2, int64(*st.SampleRate))
+							out += metric("paths_tracks_channel_count", ta2, int64(*st.ChannelCount))
+						}
+					}
 				}
 			}
 		} else if pathFilter == "" {
@@ -235,9 +260,13 @@ func (m *Metrics) onMetrics(ctx *gin.Context) {
 		if err == nil && len(data.Items) != 0 {
 			for _, i := range data.Items {
 				if hlsMuxerFilter == "" || hlsMuxerFilter == i.Path {
-					ta := tags(map[string]string{
+					t := map[string]string{
 						"name": i.Path,
-					})
+					}
+					if i.Delay != 0 {
+						t["delay"] = time.Duration(i.Delay).String()
+					}
+					ta := tags(t)
 					out += metric("hls_muxers", ta, 1)
 					out += metric("hls_muxers_bytes_sent", ta, int64(i.BytesSent))
 				}
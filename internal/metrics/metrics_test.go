@@ -33,12 +33,30 @@ func (dummyPathManager) APIPathsList() (*defs.APIPathList, error) {
 				Type: "testing",
 				ID:   "123324354",
 			},
-			Ready:         true,
-			ReadyTime:     ptrOf(time.Date(2003, 11, 4, 23, 15, 7, 0, time.UTC)),
-			Tracks:        []string{"H264", "H265"},
+			Ready:     true,
+			ReadyTime: ptrOf(time.Date(2003, 11, 4, 23, 15, 7, 0, time.UTC)),
+			Tracks:    []string{"H264", "Opus"},
+			TrackStats: []defs.APIPathTrackStats{
+				{
+					Codec:            "H264",
+					Width:            ptrOf(1920),
+					Height:           ptrOf(1080),
+					FPS:              ptrOf(29.97),
+					KeyFrameInterval: ptrOf(2.0),
+					Bitrate:          4000000,
+					AverageBitrate:   3500000,
+				},
+				{
+					Codec:          "Opus",
+					SampleRate:     ptrOf(48000),
+					ChannelCount:   ptrOf(2),
+					Bitrate:        64000,
+					AverageBitrate: 64000,
+				},
+			},
 			BytesReceived: 123,
 			BytesSent:     456,
-			Readers: []defs.APIPathSourceOrReader{
+			Readers: []defs.APIPathReader{
 				{
 					Type: "testing",
 					ID:   "345234423",
@@ -52,6 +70,10 @@ func (dummyPathManager) APIPathsGet(string) (*defs.APIPath, error) {
 	panic("unused")
 }
 
+func (dummyPathManager) APIPathsSwitch(string, string) (*defs.APIPathSwitcher, error) {
+	panic("unused")
+}
+
 type dummyHLSServer struct{}
 
 func (dummyHLSServer) APIMuxersList() (*defs.APIHLSMuxerList, error) {
@@ -276,6 +298,16 @@ func TestMetrics(t *testing.T) {
 			`paths_bytes_received{name="mypath",state="ready"} 123`+"\n"+
 			`paths_bytes_sent{name="mypath",state="ready"} 456`+"\n"+
 			`paths_readers{name="mypath",state="ready"} 1`+"\n"+
+			`paths_tracks_bitrate{codec="H264",name="mypath",state="ready",track="0"} 4000000`+"\n"+
+			`paths_tracks_average_bitrate{codec="H264",name="mypath",state="ready",track="0"} 3500000`+"\n"+
+			`paths_tracks_fps{codec="H264",name="mypath",state="ready",track="0"} 29.97`+"\n"+
+			`paths_tracks_key_frame_interval{codec="H264",name="mypath",state="ready",track="0"} 2`+"\n"+
+			`paths_tracks_width{codec="H264",name="mypath",state="ready",track="0"} 1920`+"\n"+
+			`paths_tracks_height{codec="H264",name="mypath",state="ready",track="0"} 1080`+"\n"+
+			`paths_tracks_bitrate{codec="Opus",name="mypath",state="ready",track="1"} 64000`+"\n"+
+			`paths_tracks_average_bitrate{codec="Opus",name="mypath",state="ready",track="1"} 64000`+"\n"+
+			`paths_tracks_sample_rate{codec="Opus",name="mypath",state="ready",track="1"} 48000`+"\n"+
+			`paths_tracks_channel_count{codec="Opus",name="mypath",state="ready",track="1"} 2`+"\n"+
 			`hls_muxers{name="mypath"} 1`+"\n"+
 			`hls_muxers_bytes_sent{name="mypath"} 789`+"\n"+
 			`rtsp_conns{id="18294761-f9d1-4ea9-9a35-fe265b62eb41"} 1`+"\n"+
@@ -473,7 +505,17 @@ func TestFilter(t *testing.T) {
 					`paths{name="mypath",state="ready"} 1`+"\n"+
 						`paths_bytes_received{name="mypath",state="ready"} 123`+"\n"+
 						`paths_bytes_sent{name="mypath",state="ready"} 456`+"\n"+
-						`paths_readers{name="mypath",state="ready"} 1`+"\n",
+						`paths_readers{name="mypath",state="ready"} 1`+"\n"+
+						`paths_tracks_bitrate{codec="H264",name="mypath",state="ready",track="0"} 4000000`+"\n"+
+						`paths_tracks_average_bitrate{codec="H264",name="mypath",state="ready",track="0"} 3500000`+"\n"+
+						`paths_tracks_fps{codec="H264",name="mypath",state="ready",track="0"} 29.97`+"\n"+
+						`paths_tracks_key_frame_interval{codec="H264",name="mypath",state="ready",track="0"} 2`+"\n"+
+						`paths_tracks_width{codec="H264",name="mypath",state="ready",track="0"} 1920`+"\n"+
+						`paths_tracks_height{codec="H264",name="mypath",state="ready",track="0"} 1080`+"\n"+
+						`paths_tracks_bitrate{codec="Opus",name="mypath",state="ready",track="1"} 64000`+"\n"+
+						`paths_tracks_average_bitrate{codec="Opus",name="mypath",state="ready",track="1"} 64000`+"\n"+
+						`paths_tracks_sample_rate{codec="Opus",name="mypath",state="ready",track="1"} 48000`+"\n"+
+						`paths_tracks_channel_count{codec="Opus",name="mypath",state="ready",track="1"} 2`+"\n",
 					string(byts))
 
 			case "hls_muxer":
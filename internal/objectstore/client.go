@@ -0,0 +1,207 @@
+// Package objectstore contains a client for S3-compatible object storages.
+package objectstore
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeNow = time.Now
+
+// ErrNotFound is returned when an object doesn't exist.
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo contains informations about an object.
+type ObjectInfo struct {
+	Size int64
+	ETag string
+}
+
+// Client is a client for S3-compatible object storages.
+// Objects are addressed with path-style URLs (endpoint/bucket/key)
+// and requests are signed with AWS Signature Version 4.
+type Client struct {
+	Endpoint   string
+	Bucket     string
+	Region     string
+	AccessKey  string
+	SecretKey  string
+	HTTPClient *http.Client
+}
+
+func (c *Client) objectURL(key string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	u.Path += "/" + c.Bucket + "/" + key
+	u.RawPath = ""
+
+	return u, nil
+}
+
+func (c *Client) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	sign(req, c.AccessKey, c.SecretKey, c.Region, payloadHash, timeNow())
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	return hc.Do(req)
+}
+
+func responseError(res *http.Response) error {
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	buf, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if len(buf) != 0 {
+		return fmt.Errorf("bad status code: %d (%s)", res.StatusCode, strings.TrimSpace(string(buf)))
+	}
+	return fmt.Errorf("bad status code: %d", res.StatusCode)
+}
+
+// Put uploads an object.
+// The body is read twice, the first time in order to compute its checksums,
+// that are then verified by the server.
+// The MD5 sum of the body is returned.
+func (c *Client) Put(ctx context.Context, key string, body io.ReadSeeker, size int64) (string, error) {
+	h256 := sha256.New()
+	h5 := md5.New() //nolint:gosec
+
+	_, err := io.Copy(io.MultiWriter(h256, h5), body)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = body.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := c.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), io.NopCloser(body))
+	if err != nil {
+		return "", err
+	}
+
+	md5Sum := h5.Sum(nil)
+
+	req.ContentLength = size
+	req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(md5Sum))
+
+	res, err := c.do(req, hex.EncodeToString(h256.Sum(nil)))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", responseError(res)
+	}
+
+	return hex.EncodeToString(md5Sum), nil
+}
+
+// Head returns informations about an object.
+func (c *Client) Head(ctx context.Context, key string) (*ObjectInfo, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.do(req, emptyPayloadSum)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res)
+	}
+
+	return &ObjectInfo{
+		Size: res.ContentLength,
+		ETag: strings.Trim(res.Header.Get("ETag"), `"`),
+	}, nil
+}
+
+// Get downloads an object.
+// When length is greater than zero, only the first length bytes are downloaded.
+func (c *Client) Get(ctx context.Context, key string, length int64) (io.ReadCloser, error) {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if length > 0 {
+		req.Header.Set("Range", "bytes=0-"+strconv.FormatInt(length-1, 10))
+	}
+
+	res, err := c.do(req, emptyPayloadSum)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusPartialContent {
+		defer res.Body.Close()
+		return nil, responseError(res)
+	}
+
+	return res.Body, nil
+}
+
+// Delete deletes an object. Objects that don't exist are not reported as errors.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	u, err := c.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(req, emptyPayloadSum)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusNoContent &&
+		res.StatusCode != http.StatusOK &&
+		res.StatusCode != http.StatusNotFound {
+		return responseError(res)
+	}
+
+	return nil
+}
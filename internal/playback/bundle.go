@@ -0,0 +1,139 @@
+package playback
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+func loadSigningKey(fpath string) (ed25519.PrivateKey, error) {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(byts)
+	if block == nil {
+		return nil, fmt.Errorf("PEM block not found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("only Ed25519 keys are supported")
+	}
+
+	return edKey, nil
+}
+
+type bundleMedia struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type bundleManifest struct {
+	Path     string                       `json:"path"`
+	Start    time.Time                    `json:"start"`
+	Duration float64                      `json:"duration"`
+	Created  time.Time                    `json:"created"`
+	Media    bundleMedia                  `json:"media"`
+	Segments []*recordstore.ManifestEntry `json:"segments"`
+}
+
+// bundleWriter writes an export bundle, that is a ZIP archive containing
+// the media, a manifest with hashes of the media and of the source segments,
+// and a detached signature of the manifest.
+type bundleWriter struct {
+	ctx      *gin.Context
+	fileName string
+
+	zw      *zip.Writer
+	media   io.Writer
+	hash    hash.Hash
+	size    int64
+	written bool
+}
+
+func (w *bundleWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		w.ctx.Header("Content-Type", "application/zip")
+		w.ctx.Header("Content-Disposition", `attachment; filename="export.zip"`)
+
+		w.zw = zip.NewWriter(w.ctx.Writer)
+		w.hash = sha256.New()
+
+		var err error
+		w.media, err = w.zw.Create(w.fileName)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.media.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *bundleWriter) close(manifest *bundleManifest, signingKey ed25519.PrivateKey) error {
+	manifest.Media = bundleMedia{
+		File:   w.fileName,
+		Size:   w.size,
+		SHA256: hex.EncodeToString(w.hash.Sum(nil)),
+	}
+
+	byts, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = w.writeFile("manifest.json", byts)
+	if err != nil {
+		return err
+	}
+
+	err = w.writeFile("manifest.sig", ed25519.Sign(signingKey, byts))
+	if err != nil {
+		return err
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(signingKey.Public())
+	if err != nil {
+		return err
+	}
+
+	err = w.writeFile("signing-key.pub", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	if err != nil {
+		return err
+	}
+
+	return w.zw.Close()
+}
+
+func (w *bundleWriter) writeFile(name string, byts []byte) error {
+	f, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(byts)
+	return err
+}
@@ -0,0 +1,498 @@
+package playback
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/google/uuid"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/objectstore"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	exportCleanupPeriod = 1 * time.Minute
+	exportJobExt        = ".json"
+)
+
+var errExportNotFound = errors.New("export not found")
+
+type exportStatus string
+
+const (
+	exportStatusPending exportStatus = "pending"
+	exportStatusRunning exportStatus = "running"
+	exportStatusDone    exportStatus = "done"
+	exportStatusFailed  exportStatus = "failed"
+)
+
+// exportJob is an export job.
+// It is stored as JSON next to the exported file, in order to survive restarts.
+type exportJob struct {
+	ID        uuid.UUID    `json:"id"`
+	Path      string       `json:"path"`
+	Start     time.Time    `json:"start"`
+	Duration  float64      `json:"duration"`
+	Format    string       `json:"format"`
+	Tracks    []int        `json:"tracks"`
+	Interval  float64      `json:"interval"`
+	FPS       float64      `json:"fps"`
+	Status    exportStatus `json:"status"`
+	Progress  float64      `json:"progress"`
+	Error     string       `json:"error"`
+	Size      int64        `json:"size"`
+	Created   time.Time    `json:"created"`
+	Completed *time.Time   `json:"completed"`
+}
+
+// exportMuxer wraps a muxer in order to filter tracks, report progress and stop on cancellation.
+type exportMuxer struct {
+	ctx        context.Context
+	m          muxer
+	tracks     []int
+	duration   time.Duration
+	onProgress func(float64)
+
+	timeScales   map[int]uint32
+	curTimeScale uint32
+	skip         bool
+	progress     float64
+}
+
+func (w *exportMuxer) writeInit(init *fmp4.Init) {
+	w.timeScales = make(map[int]uint32)
+	var tracks []*fmp4.InitTrack
+
+	for _, track := range init.Tracks {
+		if len(w.tracks) == 0 || slices.Contains(w.tracks, track.ID) {
+			tracks = append(tracks, track)
+			w.timeScales[track.ID] = track.TimeScale
+		}
+	}
+
+	w.m.writeInit(&fmp4.Init{
+		Tracks: tracks,
+	})
+}
+
+func (w *exportMuxer) setTrack(trackID int) {
+	w.curTimeScale, w.skip = w.timeScales[trackID]
+	w.skip = !w.skip
+
+	if !w.skip {
+		w.m.setTrack(trackID)
+	}
+}
+
+func (w *exportMuxer) writeSample(
+	dts int64,
+	ptsOffset int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	err := w.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	if w.skip {
+		return nil
+	}
+
+	err = w.m.writeSample(dts, ptsOffset, isNonSyncSample, payloadSize, getPayload)
+	if err != nil {
+		return err
+	}
+
+	progress := min(float64(durationMp4ToGo(dts, w.curTimeScale))/float64(w.duration), 1)
+	if progress >= (w.progress + 0.01) {
+		w.progress = progress
+		w.onProgress(progress)
+	}
+
+	return nil
+}
+
+func (w *exportMuxer) writeFinalDTS(dts int64) {
+	if !w.skip {
+		w.m.writeFinalDTS(dts)
+	}
+}
+
+func (w *exportMuxer) flush() error {
+	return w.m.flush()
+}
+
+// segmentEnd implements timelapseSegmentEnder.
+func (w *exportMuxer) segmentEnd() error {
+	if se, ok := w.m.(timelapseSegmentEnder); ok {
+		return se.segmentEnd()
+	}
+	return nil
+}
+
+type exportManager struct {
+	path         string
+	workerCount  int
+	retention    time.Duration
+	objectStore  *objectstore.Client
+	findPathConf func(name string) (*conf.Path, error)
+	parent       logger.Writer
+
+	ctx       context.Context
+	ctxCancel func()
+	wg        sync.WaitGroup
+	mutex     sync.Mutex
+	jobs      map[uuid.UUID]*exportJob
+	queue     []*exportJob
+
+	chQueue chan struct{}
+}
+
+func (m *exportManager) initialize() error {
+	m.ctx, m.ctxCancel = context.WithCancel(context.Background())
+	m.jobs = make(map[uuid.UUID]*exportJob)
+	m.chQueue = make(chan struct{}, m.workerCount)
+
+	err := m.load()
+	if err != nil {
+		m.ctxCancel()
+		return err
+	}
+
+	for range m.workerCount {
+		m.wg.Add(1)
+		go m.runWorker()
+	}
+
+	m.wg.Add(1)
+	go m.runCleaner()
+
+	return nil
+}
+
+func (m *exportManager) close() {
+	m.ctxCancel()
+	m.wg.Wait()
+}
+
+// load loads jobs from disk. Jobs that were not completed are queued again.
+func (m *exportManager) load() error {
+	entries, err := os.ReadDir(m.path)
+	if err != nil {
+		// the directory is created when the first job is added
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), exportJobExt) {
+			continue
+		}
+
+		var byts []byte
+		byts, err = os.ReadFile(filepath.Join(m.path, entry.Name()))
+		if err != nil {
+			return err
+		}
+
+		var job exportJob
+		err = json.Unmarshal(byts, &job)
+		if err != nil {
+			m.parent.Log(logger.Warn, "invalid export job %s: %v", entry.Name(), err)
+			continue
+		}
+
+		m.jobs[job.ID] = &job
+
+		if job.Status == exportStatusPending || job.Status == exportStatusRunning {
+			job.Status = exportStatusPending
+			job.Progress = 0
+			m.queue = append(m.queue, &job)
+		}
+	}
+
+	sort.Slice(m.queue, func(i, j int) bool {
+		return m.queue[i].Created.Before(m.queue[j].Created)
+	})
+
+	for range min(len(m.queue), m.workerCount) {
+		m.chQueue <- struct{}{}
+	}
+
+	return nil
+}
+
+func (m *exportManager) jobPath(job *exportJob) string {
+	return filepath.Join(m.path, job.ID.String()+exportJobExt)
+}
+
+func (m *exportManager) filePath(job *exportJob) string {
+	return filepath.Join(m.path, job.ID.String()+".mp4")
+}
+
+// save writes a job to disk. It must be called with the mutex locked.
+func (m *exportManager) save(job *exportJob) error {
+	byts, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return recordstore.WriteFileAtomic(m.jobPath(job), byts)
+}
+
+func (m *exportManager) add(job *exportJob) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job.ID = uuid.New()
+	job.Status = exportStatusPending
+	job.Created = time.Now()
+
+	err := os.MkdirAll(m.path, 0o755)
+	if err != nil {
+		return err
+	}
+
+	err = m.save(job)
+	if err != nil {
+		return err
+	}
+
+	m.jobs[job.ID] = job
+	m.queue = append(m.queue, job)
+
+	select {
+	case m.chQueue <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// get returns a copy of a job.
+func (m *exportManager) get(id uuid.UUID) (*exportJob, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, errExportNotFound
+	}
+
+	cpy := *job
+	return &cpy, nil
+}
+
+func (m *exportManager) next() *exportJob {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.queue) == 0 {
+		return nil
+	}
+
+	job := m.queue[0]
+	m.queue = m.queue[1:]
+
+	job.Status = exportStatusRunning
+
+	err := m.save(job)
+	if err != nil {
+		m.parent.Log(logger.Warn, "unable to save export job %s: %v", job.ID, err)
+	}
+
+	return job
+}
+
+func (m *exportManager) runWorker() {
+	defer m.wg.Done()
+
+	for {
+		if m.ctx.Err() != nil {
+			return
+		}
+
+		job := m.next()
+		if job == nil {
+			select {
+			case <-m.chQueue:
+				continue
+			case <-m.ctx.Done():
+				return
+			}
+		}
+
+		m.runJob(job)
+	}
+}
+
+func (m *exportManager) runJob(job *exportJob) {
+	m.parent.Log(logger.Info, "export %s started", job.ID)
+
+	size, err := m.export(job)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch {
+	// job has been interrupted by a shutdown or a reload: it will be resumed at next startup
+	case m.ctx.Err() != nil:
+		os.Remove(m.filePath(job))
+		job.Status = exportStatusPending
+		job.Progress = 0
+
+	case err != nil:
+		os.Remove(m.filePath(job))
+		m.parent.Log(logger.Warn, "export %s failed: %v", job.ID, err)
+		now := time.Now()
+		job.Status = exportStatusFailed
+		job.Error = err.Error()
+		job.Completed = &now
+
+	default:
+		m.parent.Log(logger.Info, "export %s completed", job.ID)
+		now := time.Now()
+		job.Status = exportStatusDone
+		job.Progress = 1
+		job.Size = size
+		job.Completed = &now
+	}
+
+	err = m.save(job)
+	if err != nil {
+		m.parent.Log(logger.Warn, "unable to save export job %s: %v", job.ID, err)
+	}
+}
+
+func (m *exportManager) export(job *exportJob) (int64, error) {
+	pathConf, err := m.findPathConf(job.Path)
+	if err != nil {
+		return 0, err
+	}
+
+	duration := time.Duration(job.Duration * float64(time.Second))
+	end := job.Start.Add(duration)
+
+	segments, err := recordstore.FindSegments(pathConf, job.Path, &job.Start, &end)
+	if err != nil {
+		return 0, err
+	}
+
+	cleanup, err := fetchRemoteSegments(m.ctx, m.objectStore, segments, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	f, err := os.Create(m.filePath(job))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	bw := bufio.NewWriter(f)
+
+	var mux muxer
+	if job.Format == "mp4" {
+		mux = &muxerMP4{w: bw}
+	} else {
+		mux = &muxerFMP4{w: bw}
+	}
+
+	em := &exportMuxer{
+		ctx:      m.ctx,
+		m:        mux,
+		tracks:   job.Tracks,
+		duration: duration,
+		onProgress: func(progress float64) {
+			m.mutex.Lock()
+			job.Progress = progress
+			m.mutex.Unlock()
+		},
+	}
+
+	if job.Interval != 0 {
+		// the timelapse muxer is placed after the export muxer, in order to
+		// pick the video track among selected tracks and to report progress of source samples.
+		em.m = &timelapseMuxer{
+			m:        mux,
+			interval: time.Duration(job.Interval * float64(time.Second)),
+			fps:      job.FPS,
+			duration: duration,
+		}
+		err = timelapseMux(pathConf, segments, job.Start, duration, em)
+	} else {
+		err = seekAndMux(pathConf, segments, job.Start, duration, em)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if len(em.timeScales) == 0 {
+		return 0, fmt.Errorf("none of the selected tracks is present")
+	}
+
+	err = bw.Flush()
+	if err != nil {
+		return 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return fi.Size(), nil
+}
+
+func (m *exportManager) runCleaner() {
+	defer m.wg.Done()
+
+	m.removeExpired()
+
+	t := time.NewTicker(exportCleanupPeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			m.removeExpired()
+
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *exportManager) removeExpired() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+
+	for id, job := range m.jobs {
+		if job.Completed != nil && now.Sub(*job.Completed) > m.retention {
+			m.parent.Log(logger.Debug, "removing expired export %s", job.ID)
+			os.Remove(m.filePath(job))
+			os.Remove(m.jobPath(job))
+			delete(m.jobs, id)
+		}
+	}
+}
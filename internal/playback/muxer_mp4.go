@@ -89,12 +89,13 @@ func (w *muxerMP4) writeFinalDTS(dts int64) {
 	}
 }
 
-func (w *muxerMP4) flush() error {
+// presentation returns the MP4 presentation, whose samples are read on demand.
+func (w *muxerMP4) presentation() (*pmp4.Presentation, error) {
 	if len(w.curTrack.Samples) == 0 || w.curTrack.lastDTS < 0 {
-		return recordstore.ErrNoSegmentsFound
+		return nil, recordstore.ErrNoSegmentsFound
 	}
 
-	h := pmp4.Presentation{
+	h := &pmp4.Presentation{
 		Tracks: make([]*pmp4.Track, len(w.tracks)),
 	}
 
@@ -102,5 +103,14 @@ func (w *muxerMP4) flush() error {
 		h.Tracks[i] = &track.Track
 	}
 
+	return h, nil
+}
+
+func (w *muxerMP4) flush() error {
+	h, err := w.presentation()
+	if err != nil {
+		return err
+	}
+
 	return h.Marshal(w.w)
 }
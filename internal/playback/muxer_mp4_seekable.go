@@ -0,0 +1,173 @@
+package playback
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/pmp4"
+)
+
+// seekableMP4 is a MP4 file that is generated on demand.
+// The header (ftyp, moov and the header of mdat) is computed in advance from sample tables,
+// while payloads of samples are read from segments when the related byte range is requested.
+type seekableMP4 struct {
+	header  []byte
+	samples []*pmp4.Sample // in the same order of mdat
+	offsets []int64        // position of samples in the file
+	size    int64
+
+	pos           int64
+	cachedSample  int
+	cachedPayload []byte
+	err           error
+}
+
+func newSeekableMP4(p *pmp4.Presentation) (*seekableMP4, error) {
+	f := &seekableMP4{
+		cachedSample: -1,
+	}
+
+	// marshal the presentation without payloads, obtaining the header
+	// and the order in which samples are placed into mdat.
+	var getPayloads [][]func() ([]byte, error)
+
+	for _, track := range p.Tracks {
+		trackGetPayloads := make([]func() ([]byte, error), len(track.Samples))
+
+		for i, sample := range track.Samples {
+			trackGetPayloads[i] = sample.GetPayload
+
+			sample.GetPayload = func() ([]byte, error) {
+				f.samples = append(f.samples, sample)
+				return nil, nil
+			}
+		}
+
+		getPayloads = append(getPayloads, trackGetPayloads)
+	}
+
+	var buf bytes.Buffer
+	err := p.Marshal(&buf)
+
+	for i, track := range p.Tracks {
+		for j, sample := range track.Samples {
+			sample.GetPayload = getPayloads[i][j]
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	f.header = buf.Bytes()
+	f.offsets = make([]int64, len(f.samples))
+	f.size = int64(len(f.header))
+
+	for i, sample := range f.samples {
+		f.offsets[i] = f.size
+		f.size += int64(sample.PayloadSize)
+	}
+
+	return f, nil
+}
+
+// etag returns an identifier of the file, that changes when samples change.
+func (f *seekableMP4) etag() string {
+	h := sha256.Sum256(f.header)
+	return `"` + hex.EncodeToString(h[:16]) + `"`
+}
+
+func (f *seekableMP4) payload(i int) ([]byte, error) {
+	if i == f.cachedSample {
+		return f.cachedPayload, nil
+	}
+
+	payload, err := f.samples[i].GetPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) != int(f.samples[i].PayloadSize) {
+		return nil, fmt.Errorf("unexpected payload size")
+	}
+
+	f.cachedSample = i
+	f.cachedPayload = payload
+
+	return payload, nil
+}
+
+// Read implements io.Reader.
+func (f *seekableMP4) Read(p []byte) (int, error) {
+	if f.pos >= f.size {
+		return 0, io.EOF
+	}
+
+	if f.pos < int64(len(f.header)) {
+		n := copy(p, f.header[f.pos:])
+		f.pos += int64(n)
+		return n, nil
+	}
+
+	// find the sample that contains the current position
+	i := sort.Search(len(f.offsets), func(i int) bool {
+		return f.offsets[i] > f.pos
+	}) - 1
+
+	payload, err := f.payload(i)
+	if err != nil {
+		f.err = err
+		return 0, err
+	}
+
+	n := copy(p, payload[f.pos-f.offsets[i]:])
+	f.pos += int64(n)
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (f *seekableMP4) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	f.pos = offset
+	return offset, nil
+}
+
+// muxerMP4Seekable is a muxerMP4 that, instead of writing the file,
+// passes a seekableMP4 to onReady, in order to allow serving byte ranges.
+type muxerMP4Seekable struct {
+	muxerMP4
+
+	onReady func(f *seekableMP4) error
+}
+
+func (w *muxerMP4Seekable) flush() error {
+	p, err := w.presentation()
+	if err != nil {
+		return err
+	}
+
+	f, err := newSeekableMP4(p)
+	if err != nil {
+		return err
+	}
+
+	return w.onReady(f)
+}
@@ -0,0 +1,158 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type exportAddReq struct {
+	Path     string    `json:"path"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration"`
+	Format   string    `json:"format"`
+	Tracks   []int     `json:"tracks"`
+	Interval float64   `json:"interval"`
+	FPS      float64   `json:"fps"`
+}
+
+func (s *Server) onExportsAdd(ctx *gin.Context) {
+	var req exportAddReq
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	if !s.doAuth(ctx, req.Path) {
+		return
+	}
+
+	if req.Duration <= 0 {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration"))
+		return
+	}
+
+	if req.Interval != 0 {
+		if req.FPS == 0 {
+			req.FPS = timelapseDefaultFPS
+		}
+
+		err = checkTimelapseParams(time.Duration(req.Interval*float64(time.Second)), req.FPS)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	switch req.Format {
+	case "":
+		req.Format = "fmp4"
+
+	case "fmp4", "mp4":
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", req.Format))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(req.Path)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := req.Start.Add(time.Duration(req.Duration * float64(time.Second)))
+	_, err = recordstore.FindSegments(pathConf, req.Path, &req.Start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	job := &exportJob{
+		Path:     req.Path,
+		Start:    req.Start,
+		Duration: req.Duration,
+		Format:   req.Format,
+		Tracks:   req.Tracks,
+		Interval: req.Interval,
+		FPS:      req.FPS,
+	}
+
+	err = s.exports.add(job)
+	if err != nil {
+		s.writeError(ctx, http.StatusInternalServerError, err)
+		return
+	}
+
+	job, _ = s.exports.get(job.ID)
+
+	ctx.JSON(http.StatusCreated, job)
+}
+
+// findExport finds an export and checks that the user is allowed to access it.
+func (s *Server) findExport(ctx *gin.Context) (*exportJob, bool) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid id"))
+		return nil, false
+	}
+
+	job, err := s.exports.get(id)
+	if err != nil {
+		s.writeError(ctx, http.StatusNotFound, err)
+		return nil, false
+	}
+
+	if !s.doAuth(ctx, job.Path) {
+		return nil, false
+	}
+
+	return job, true
+}
+
+func (s *Server) onExportsGet(ctx *gin.Context) {
+	job, ok := s.findExport(ctx)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, job)
+}
+
+func (s *Server) onExportsDownload(ctx *gin.Context) {
+	job, ok := s.findExport(ctx)
+	if !ok {
+		return
+	}
+
+	if job.Status != exportStatusDone {
+		s.writeError(ctx, http.StatusConflict, fmt.Errorf("export is not completed"))
+		return
+	}
+
+	f, err := os.Open(s.exports.filePath(job))
+	if err != nil {
+		s.writeError(ctx, http.StatusNotFound, err)
+		return
+	}
+	defer f.Close()
+
+	fileName := job.ID.String() + ".mp4"
+
+	// http.ServeContent supports Range requests
+	ctx.Header("Content-Type", "video/mp4")
+	ctx.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	http.ServeContent(ctx.Writer, ctx.Request, fileName, *job.Completed, f)
+}
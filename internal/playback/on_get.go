@@ -3,6 +3,7 @@ package playback
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -40,89 +41,130 @@ func parseDuration(raw string) (time.Duration, error) {
 	return time.ParseDuration(raw)
 }
 
+func segmentReadHeader(format conf.RecordFormat, r io.ReadSeeker) (*fmp4.Init, time.Duration, error) {
+	if format == conf.RecordFormatMKV {
+		return segmentMKVReadHeader(r)
+	}
+	return segmentFMP4ReadHeader(r)
+}
+
+func segmentMuxParts(
+	format conf.RecordFormat,
+	r readSeekerAt,
+	startDTS time.Duration,
+	duration time.Duration,
+	tracks []*fmp4.InitTrack,
+	cipher *recordstore.SegmentCipher,
+	m muxer,
+) (time.Duration, error) {
+	if format == conf.RecordFormatMKV {
+		return segmentMKVMuxParts(r, startDTS, duration, tracks, m)
+	}
+	return segmentFMP4MuxParts(r, startDTS, duration, tracks, cipher, m)
+}
+
 func seekAndMux(
-	recordFormat conf.RecordFormat,
+	pathConf *conf.Path,
 	segments []*recordstore.Segment,
 	start time.Time,
 	duration time.Duration,
 	m muxer,
 ) error {
-	if recordFormat == conf.RecordFormatFMP4 {
-		f, err := os.Open(segments[0].Fpath)
+	if pathConf.RecordFormat == conf.RecordFormatMPEGTS {
+		return fmt.Errorf("MPEG-TS format is not supported yet")
+	}
+
+	keys, err := recordstore.LoadEncryptionKeys(pathConf)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(segments[0].Fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	firstInit, _, err := segmentReadHeader(pathConf.RecordFormat, f)
+	if err != nil {
+		return err
+	}
+
+	m.writeInit(&fmp4.Init{
+		Tracks: firstInit.Tracks,
+	})
+
+	firstMtxi := findMtxi(firstInit.UserData)
+	startOffset := segments[0].Start.Sub(start) // this is negative
+	dts := startOffset
+	prevInit := firstInit
+
+	cipher, err := recordstore.OpenSegmentCipher(firstInit.UserData, keys)
+	if err != nil {
+		return err
+	}
+
+	segmentDuration, err := segmentMuxParts(pathConf.RecordFormat, f, dts, duration, firstInit.Tracks, cipher, m)
+	if err != nil {
+		return err
+	}
+
+	segmentEnd := segments[0].Start.Add(segmentDuration)
+
+	for _, seg := range segments[1:] {
+		f, err = os.Open(seg.Fpath)
 		if err != nil {
 			return err
 		}
 		defer f.Close()
 
-		firstInit, _, err := segmentFMP4ReadHeader(f)
+		var init *fmp4.Init
+		init, _, err = segmentReadHeader(pathConf.RecordFormat, f)
 		if err != nil {
 			return err
 		}
 
-		m.writeInit(&fmp4.Init{
-			Tracks: firstInit.Tracks,
-		})
+		if !segmentFMP4CanBeConcatenated(prevInit, segmentEnd, init, seg.Start) {
+			break
+		}
 
-		firstMtxi := findMtxi(firstInit.UserData)
-		startOffset := segments[0].Start.Sub(start) // this is negative
-		dts := startOffset
-		prevInit := firstInit
+		if firstMtxi != nil {
+			mtxi := findMtxi(init.UserData)
+			dts = time.Duration(mtxi.DTS-firstMtxi.DTS) + startOffset
+		} else { // legacy method
+			dts = seg.Start.Sub(start) // this is positive
+		}
 
-		segmentDuration, err := segmentFMP4MuxParts(f, dts, duration, firstInit.Tracks, m)
+		cipher, err = recordstore.OpenSegmentCipher(init.UserData, keys)
 		if err != nil {
 			return err
 		}
 
-		segmentEnd := segments[0].Start.Add(segmentDuration)
-
-		for _, seg := range segments[1:] {
-			f, err = os.Open(seg.Fpath)
-			if err != nil {
-				return err
-			}
-			defer f.Close()
-
-			var init *fmp4.Init
-			init, _, err = segmentFMP4ReadHeader(f)
-			if err != nil {
-				return err
-			}
-
-			if !segmentFMP4CanBeConcatenated(prevInit, segmentEnd, init, seg.Start) {
-				break
-			}
-
-			if firstMtxi != nil {
-				mtxi := findMtxi(init.UserData)
-				dts = time.Duration(mtxi.DTS-firstMtxi.DTS) + startOffset
-			} else { // legacy method
-				dts = seg.Start.Sub(start) // this is positive
-			}
-
-			segmentDuration, err = segmentFMP4MuxParts(f, dts, duration, firstInit.Tracks, m)
-			if err != nil {
-				return err
-			}
-
-			segmentEnd = seg.Start.Add(segmentDuration)
-			prevInit = init
-		}
-
-		err = m.flush()
+		segmentDuration, err = segmentMuxParts(pathConf.RecordFormat, f, dts, duration, firstInit.Tracks, cipher, m)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		segmentEnd = seg.Start.Add(segmentDuration)
+		prevInit = init
 	}
 
-	return fmt.Errorf("MPEG-TS format is not supported yet")
+	err = m.flush()
+	if err != nil {
+		return err
+	}
+
+	return nil
 }
 
 func (s *Server) onGet(ctx *gin.Context) {
 	pathName := ctx.Query("path")
 
-	if !s.doAuth(ctx, pathName) {
+	// clips of multiple paths are exported together
+	pathNames := ctx.QueryArray("path")
+	multi := len(pathNames) > 1
+
+	if !multi && !s.doAuth(ctx, pathName) {
 		return
 	}
 
@@ -138,16 +180,70 @@ func (s *Server) onGet(ctx *gin.Context) {
 		return
 	}
 
+	bundle := false
+	if rawBundle := ctx.Query("bundle"); rawBundle != "" {
+		bundle, err = strconv.ParseBool(rawBundle)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid bundle: %w", err))
+			return
+		}
+	}
+
+	if bundle && s.signingKey == nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("bundles require 'playbackSigningKey' to be set"))
+		return
+	}
+
+	if multi {
+		if bundle {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("bundles of multiple paths are not supported"))
+			return
+		}
+
+		format := ctx.Query("format")
+		if format != "" && format != "fmp4" && format != "mp4" {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
+			return
+		}
+
+		s.onGetMulti(ctx, pathNames, start, duration, format)
+		return
+	}
+
 	ww := &writerWrapper{ctx: ctx}
+	bw := &bundleWriter{ctx: ctx, fileName: "recording.mp4"}
+
+	var w io.Writer = ww
+	written := func() bool { return ww.written }
+
+	if bundle {
+		w = bw
+		written = func() bool { return bw.written }
+	}
+
 	var m muxer
 
 	format := ctx.Query("format")
 	switch format {
 	case "", "fmp4":
-		m = &muxerFMP4{w: ww}
+		m = &muxerFMP4{w: w}
 
 	case "mp4":
-		m = &muxerMP4{w: ww}
+		if bundle {
+			m = &muxerMP4{w: w}
+		} else {
+			// standalone MP4 files are served with support for byte ranges,
+			// allowing players to seek and downloads to be resumed.
+			m = &muxerMP4Seekable{
+				onReady: func(f *seekableMP4) error {
+					ww.written = true
+					ctx.Header("Content-Type", "video/mp4")
+					ctx.Header("ETag", f.etag())
+					http.ServeContent(ctx.Writer, ctx.Request, "", time.Time{}, f)
+					return f.err
+				},
+			}
+		}
 
 	default:
 		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
@@ -171,7 +267,37 @@ func (s *Server) onGet(ctx *gin.Context) {
 		return
 	}
 
-	err = seekAndMux(pathConf.RecordFormat, segments, start, duration, m)
+	var manifest *bundleManifest
+
+	if bundle {
+		var entries []*recordstore.ManifestEntry
+		entries, err = recordstore.ManifestEntriesOfSegments(pathConf, pathName, segments)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, err)
+			return
+		}
+
+		manifest = &bundleManifest{
+			Path:     pathName,
+			Start:    start,
+			Duration: duration.Seconds(),
+			Created:  time.Now(),
+			Segments: entries,
+		}
+	}
+
+	// manifest entries are found by local path, therefore remote segments are fetched afterwards.
+	cleanup, err := fetchRemoteSegments(ctx.Request.Context(), s.ObjectStore, segments, 0)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadGateway, err)
+		return
+	}
+	defer cleanup()
+
+	err = seekAndMux(pathConf, segments, start, duration, m)
+	if err == nil && bundle {
+		err = bw.close(manifest, s.signingKey)
+	}
 	if err != nil {
 		// user aborted the download
 		var neterr *net.OpError
@@ -180,7 +306,7 @@ func (s *Server) onGet(ctx *gin.Context) {
 		}
 
 		// nothing has been written yet; send back JSON
-		if !ww.written {
+		if !written() {
 			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
 				s.writeError(ctx, http.StatusNotFound, err)
 			} else {
@@ -0,0 +1,257 @@
+package playback
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+type syncManifestFile struct {
+	Path   string  `json:"path"`
+	File   string  `json:"file"`
+	Offset float64 `json:"offset"`
+}
+
+// syncManifest describes clips of a synchronized export.
+// All clips start at the same instant, that is Start. Offset is the time
+// between Start and the beginning of the recording of each path,
+// computed with the NTP timestamps stored in segments.
+type syncManifest struct {
+	Start    time.Time          `json:"start"`
+	Duration float64            `json:"duration"`
+	Files    []syncManifestFile `json:"files"`
+	Missing  []string           `json:"missing"`
+}
+
+// syncWriter writes a ZIP archive that contains a clip for each path.
+// Archive and files are created on first write, in order to allow sending back errors.
+type syncWriter struct {
+	ctx      *gin.Context
+	fileName string
+
+	zw      *zip.Writer
+	file    io.Writer
+	written bool
+}
+
+func (w *syncWriter) Write(p []byte) (int, error) {
+	if !w.written {
+		w.written = true
+		w.ctx.Header("Content-Type", "application/zip")
+		w.ctx.Header("Content-Disposition", `attachment; filename="export.zip"`)
+
+		w.zw = zip.NewWriter(w.ctx.Writer)
+	}
+
+	if w.file == nil {
+		var err error
+		w.file, err = w.zw.Create(w.fileName)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return w.file.Write(p)
+}
+
+func (w *syncWriter) nextFile(fileName string) {
+	w.fileName = fileName
+	w.file = nil
+}
+
+func (w *syncWriter) close(manifest *syncManifest) error {
+	byts, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := w.zw.Create("sync.json")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(byts)
+	if err != nil {
+		return err
+	}
+
+	return w.zw.Close()
+}
+
+// syncFileNames returns names of clips of paths, avoiding collisions between them.
+func syncFileNames(pathNames []string) []string {
+	ret := make([]string, len(pathNames))
+	used := make(map[string]struct{})
+
+	for i, pathName := range pathNames {
+		base := strings.ReplaceAll(pathName, "/", "_")
+		name := base + ".mp4"
+
+		for n := 2; ; n++ {
+			if _, ok := used[name]; !ok {
+				break
+			}
+			name = fmt.Sprintf("%s-%d.mp4", base, n)
+		}
+
+		used[name] = struct{}{}
+		ret[i] = name
+	}
+
+	return ret
+}
+
+// muxPath writes the clip of a path into the synchronized export.
+// It returns the offset between start and the beginning of the recording.
+func (s *Server) muxPath(
+	ctx *gin.Context,
+	pathName string,
+	start time.Time,
+	duration time.Duration,
+	m muxer,
+) (time.Duration, error) {
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		return 0, err
+	}
+
+	end := start.Add(duration)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		return 0, err
+	}
+
+	cleanup, err := fetchRemoteSegments(ctx.Request.Context(), s.ObjectStore, segments, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	err = seekAndMux(pathConf, segments, start, duration, m)
+	if err != nil {
+		return 0, err
+	}
+
+	return max(recordingStart(pathConf, segments[0]).Sub(start), 0), nil
+}
+
+// recordingStart returns the NTP timestamp of the beginning of a segment, stored in its mtxi box.
+// Start times of segments, that are decoded from file names, are used as fallback,
+// although their precision depends on the record path.
+func recordingStart(pathConf *conf.Path, seg *recordstore.Segment) time.Time {
+	f, err := os.Open(seg.Fpath)
+	if err != nil {
+		return seg.Start
+	}
+	defer f.Close()
+
+	init, _, err := segmentReadHeader(pathConf.RecordFormat, f)
+	if err != nil {
+		return seg.Start
+	}
+
+	mtxi := findMtxi(init.UserData)
+	if mtxi == nil || mtxi.NTP == 0 {
+		return seg.Start
+	}
+
+	return time.Unix(0, mtxi.NTP)
+}
+
+func (s *Server) onGetMulti(
+	ctx *gin.Context,
+	pathNames []string,
+	start time.Time,
+	duration time.Duration,
+	format string,
+) {
+	for i, pathName := range pathNames {
+		for _, other := range pathNames[:i] {
+			if other == pathName {
+				s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("duplicate path: %s", pathName))
+				return
+			}
+		}
+	}
+
+	for _, pathName := range pathNames {
+		if !s.doAuth(ctx, pathName) {
+			return
+		}
+	}
+
+	w := &syncWriter{ctx: ctx}
+
+	manifest := &syncManifest{
+		Start:    start,
+		Duration: duration.Seconds(),
+		Files:    []syncManifestFile{},
+		Missing:  []string{},
+	}
+
+	fileNames := syncFileNames(pathNames)
+
+	for i, pathName := range pathNames {
+		fileName := fileNames[i]
+		w.nextFile(fileName)
+
+		var m muxer
+		if format == "mp4" {
+			m = &muxerMP4{w: w}
+		} else {
+			m = &muxerFMP4{w: w}
+		}
+
+		offset, err := s.muxPath(ctx, pathName, start, duration, m)
+		if err != nil {
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				manifest.Missing = append(manifest.Missing, pathName)
+				continue
+			}
+
+			// user aborted the download
+			var neterr *net.OpError
+			if errors.As(err, &neterr) {
+				return
+			}
+
+			// nothing has been written yet; send back JSON
+			if !w.written {
+				s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("%s: %w", pathName, err))
+				return
+			}
+
+			// something has already been written: abort and write logs only
+			s.Log(logger.Error, "%s: %v", pathName, err)
+			return
+		}
+
+		manifest.Files = append(manifest.Files, syncManifestFile{
+			Path:   pathName,
+			File:   fileName,
+			Offset: offset.Seconds(),
+		})
+	}
+
+	if !w.written {
+		s.writeError(ctx, http.StatusNotFound, recordstore.ErrNoSegmentsFound)
+		return
+	}
+
+	err := w.close(manifest)
+	if err != nil {
+		s.Log(logger.Error, err.Error())
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type listEntryDuration time.Duration
@@ -22,20 +23,34 @@ func (d listEntryDuration) MarshalJSON() ([]byte, error) {
 	return json.Marshal(time.Duration(d).Seconds())
 }
 
+type listEntryTier string
+
+const (
+	listEntryTierFull      listEntryTier = "full"
+	listEntryTierKeyframes listEntryTier = "keyframes"
+)
+
 type parsedSegment struct {
 	start    time.Time
 	init     *fmp4.Init
 	duration time.Duration
 }
 
-func parseSegment(seg *recordstore.Segment) (*parsedSegment, error) {
+func (p *parsedSegment) tier() listEntryTier {
+	if recordstore.IsSparse(p.init) {
+		return listEntryTierKeyframes
+	}
+	return listEntryTierFull
+}
+
+func parseSegment(recordFormat conf.RecordFormat, seg *recordstore.Segment) (*parsedSegment, error) {
 	f, err := os.Open(seg.Fpath)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	init, duration, err := segmentFMP4ReadHeader(f)
+	init, duration, err := segmentReadHeader(recordFormat, f)
 	if err != nil {
 		return nil, err
 	}
@@ -43,7 +58,11 @@ func parseSegment(seg *recordstore.Segment) (*parsedSegment, error) {
 	// if duration is not present in the header, compute it
 	// by parsing each part
 	if duration == 0 {
-		duration, err = segmentFMP4ReadDurationFromParts(f, init)
+		if recordFormat == conf.RecordFormatMKV {
+			duration, err = segmentMKVReadDurationFromBlocks(f)
+		} else {
+			duration, err = segmentFMP4ReadDurationFromParts(f, init)
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -56,7 +75,7 @@ func parseSegment(seg *recordstore.Segment) (*parsedSegment, error) {
 	}, nil
 }
 
-func parseSegments(segments []*recordstore.Segment) ([]*parsedSegment, error) {
+func parseSegments(recordFormat conf.RecordFormat, segments []*recordstore.Segment) ([]*parsedSegment, error) {
 	parsed := make([]*parsedSegment, len(segments))
 	ch := make(chan error)
 
@@ -66,7 +85,7 @@ func parseSegments(segments []*recordstore.Segment) ([]*parsedSegment, error) {
 	for i, seg := range segments {
 		go func(i int, seg *recordstore.Segment) {
 			var err error
-			parsed[i], err = parseSegment(seg)
+			parsed[i], err = parseSegment(recordFormat, seg)
 			ch <- err
 		}(i, seg)
 	}
@@ -83,10 +102,20 @@ func parseSegments(segments []*recordstore.Segment) ([]*parsedSegment, error) {
 	return parsed, err
 }
 
+type listEntryBookmark struct {
+	ID    uuid.UUID  `json:"id"`
+	Time  time.Time  `json:"time"`
+	End   *time.Time `json:"end"`
+	Title string     `json:"title"`
+	Tags  []string   `json:"tags"`
+}
+
 type listEntry struct {
-	Start    time.Time         `json:"start"`
-	Duration listEntryDuration `json:"duration"`
-	URL      string            `json:"url"`
+	Start     time.Time           `json:"start"`
+	Duration  listEntryDuration   `json:"duration"`
+	Tier      listEntryTier       `json:"tier"`
+	URL       string              `json:"url"`
+	Bookmarks []listEntryBookmark `json:"bookmarks,omitempty"`
 }
 
 func concatenateSegments(parsed []*parsedSegment) []listEntry {
@@ -106,6 +135,7 @@ func concatenateSegments(parsed []*parsedSegment) []listEntry {
 			out = append(out, listEntry{
 				Start:    parsed.start,
 				Duration: listEntryDuration(parsed.duration),
+				Tier:     parsed.tier(),
 			})
 		}
 
@@ -119,17 +149,17 @@ func parseAndConcatenate(
 	recordFormat conf.RecordFormat,
 	segments []*recordstore.Segment,
 ) ([]listEntry, error) {
-	if recordFormat == conf.RecordFormatFMP4 {
-		parsed, err := parseSegments(segments)
-		if err != nil {
-			return nil, err
-		}
+	if recordFormat == conf.RecordFormatMPEGTS {
+		return nil, fmt.Errorf("MPEG-TS format is not supported yet")
+	}
 
-		out := concatenateSegments(parsed)
-		return out, nil
+	parsed, err := parseSegments(recordFormat, segments)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("MPEG-TS format is not supported yet")
+	out := concatenateSegments(parsed)
+	return out, nil
 }
 
 func (s *Server) onList(ctx *gin.Context) {
@@ -179,6 +209,15 @@ func (s *Server) onList(ctx *gin.Context) {
 		return
 	}
 
+	// headers of remote segments are enough to compute durations,
+	// since segments are uploaded after they have been finalized.
+	cleanup, err := fetchRemoteSegments(ctx.Request.Context(), s.ObjectStore, segments, remoteSegmentHeaderSize)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadGateway, err)
+		return
+	}
+	defer cleanup()
+
 	entries, err := parseAndConcatenate(pathConf.RecordFormat, segments)
 	if err != nil {
 		s.writeError(ctx, http.StatusInternalServerError, err)
@@ -212,6 +251,23 @@ func (s *Server) onList(ctx *gin.Context) {
 		}
 	}
 
+	// bookmarks are attached to the entries they overlap with
+	bookmarks, _ := recordstore.FindBookmarks(pathConf, pathName, start, end)
+
+	for _, b := range bookmarks {
+		for i := range entries {
+			if b.Overlaps(entries[i].Start, entries[i].Start.Add(time.Duration(entries[i].Duration))) {
+				entries[i].Bookmarks = append(entries[i].Bookmarks, listEntryBookmark{
+					ID:    b.ID,
+					Time:  b.Time,
+					End:   b.End,
+					Title: b.Title,
+					Tags:  b.Tags,
+				})
+			}
+		}
+	}
+
 	var scheme string
 	if s.Encryption {
 		scheme = "https"
@@ -0,0 +1,136 @@
+package playback
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+	"github.com/gin-gonic/gin"
+)
+
+func checkTimelapseParams(interval time.Duration, fps float64) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid interval")
+	}
+
+	if fps <= 0 || fps > timelapseMaxFPS {
+		return fmt.Errorf("fps must be between 0 and %d", timelapseMaxFPS)
+	}
+
+	return nil
+}
+
+func (s *Server) onTimelapse(ctx *gin.Context) {
+	pathName := ctx.Query("path")
+
+	if !s.doAuth(ctx, pathName) {
+		return
+	}
+
+	start, err := time.Parse(time.RFC3339, ctx.Query("start"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid start: %w", err))
+		return
+	}
+
+	duration, err := parseDuration(ctx.Query("duration"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid duration: %w", err))
+		return
+	}
+
+	interval, err := parseDuration(ctx.Query("interval"))
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid interval: %w", err))
+		return
+	}
+
+	fps := float64(timelapseDefaultFPS)
+	if rawFPS := ctx.Query("fps"); rawFPS != "" {
+		fps, err = strconv.ParseFloat(rawFPS, 64)
+		if err != nil {
+			s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid fps: %w", err))
+			return
+		}
+	}
+
+	err = checkTimelapseParams(interval, fps)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	ww := &writerWrapper{ctx: ctx}
+
+	var m muxer
+
+	format := ctx.Query("format")
+	switch format {
+	case "", "mp4":
+		m = &muxerMP4{w: ww}
+
+	case "fmp4":
+		m = &muxerFMP4{w: ww}
+
+	default:
+		s.writeError(ctx, http.StatusBadRequest, fmt.Errorf("invalid format: %s", format))
+		return
+	}
+
+	pathConf, err := s.safeFindPathConf(pathName)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	end := start.Add(duration)
+	segments, err := recordstore.FindSegments(pathConf, pathName, &start, &end)
+	if err != nil {
+		if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+			s.writeError(ctx, http.StatusNotFound, err)
+		} else {
+			s.writeError(ctx, http.StatusBadRequest, err)
+		}
+		return
+	}
+
+	cleanup, err := fetchRemoteSegments(ctx.Request.Context(), s.ObjectStore, segments, 0)
+	if err != nil {
+		s.writeError(ctx, http.StatusBadGateway, err)
+		return
+	}
+	defer cleanup()
+
+	err = timelapseMux(pathConf, segments, start, duration, &timelapseMuxer{
+		m:        m,
+		interval: interval,
+		fps:      fps,
+		duration: duration,
+	})
+	if err != nil {
+		// user aborted the download
+		var neterr *net.OpError
+		if errors.As(err, &neterr) {
+			return
+		}
+
+		// nothing has been written yet; send back JSON
+		if !ww.written {
+			if errors.Is(err, recordstore.ErrNoSegmentsFound) {
+				s.writeError(ctx, http.StatusNotFound, err)
+			} else {
+				s.writeError(ctx, http.StatusBadRequest, err)
+			}
+			return
+		}
+
+		// something has already been written: abort and write logs only
+		s.Log(logger.Error, err.Error())
+		return
+	}
+}
@@ -59,6 +59,11 @@ func findMtxi(userData []amp4.IBox) *recordstore.Mtxi {
 }
 
 func segmentFMP4AreConsecutive(init1 *fmp4.Init, init2 *fmp4.Init) bool {
+	// downsampled segments contain a subset of tracks
+	if recordstore.IsSparse(init1) != recordstore.IsSparse(init2) {
+		return false
+	}
+
 	mtxi1 := findMtxi(init1.UserData)
 	mtxi2 := findMtxi(init2.UserData)
 
@@ -420,6 +425,7 @@ func segmentFMP4MuxParts(
 	startDTS time.Duration,
 	duration time.Duration,
 	tracks []*fmp4.InitTrack,
+	cipher *recordstore.SegmentCipher,
 	m muxer,
 ) (time.Duration, error) {
 	var startDTSMP4 int64
@@ -483,11 +489,19 @@ func segmentFMP4MuxParts(
 				sampleOffset := dataOffset
 				sampleSize := e.SampleSize
 
+				payloadSize := sampleSize
+				if cipher != nil {
+					if payloadSize < recordstore.SegmentCipherIVSize {
+						return nil, fmt.Errorf("encrypted sample is too short")
+					}
+					payloadSize -= recordstore.SegmentCipherIVSize
+				}
+
 				err = m.writeSample(
 					dts,
 					e.SampleCompositionTimeOffsetV1,
 					(e.SampleFlags&sampleFlagIsNonSyncSample) != 0,
-					e.SampleSize,
+					payloadSize,
 					func() ([]byte, error) {
 						payload := make([]byte, sampleSize)
 						n, err2 := r.ReadAt(payload, int64(sampleOffset))
@@ -498,6 +512,10 @@ func segmentFMP4MuxParts(
 							return nil, fmt.Errorf("partial read")
 						}
 
+						if cipher != nil {
+							return cipher.DecryptSample(payload)
+						}
+
 						return payload, nil
 					},
 				)
@@ -0,0 +1,175 @@
+package playback
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+func segmentMKVReadHeader(r io.ReadSeeker) (*fmp4.Init, time.Duration, error) {
+	h, err := recordstore.ReadMKVSegmentHeader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return h.Init, h.Duration, nil
+}
+
+func segmentMKVReadDurationFromBlocks(r io.ReadSeeker) (time.Duration, error) {
+	h, err := recordstore.ReadMKVSegmentHeader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return h.ReadDuration(r)
+}
+
+// segmentMKVDTS computes decode timestamps of blocks of a track.
+// Matroska stores presentation timestamps only, therefore decode timestamps are
+// obtained by sorting presentation timestamps and by applying the minimum delay
+// that keeps every presentation timestamp greater or equal than its decode timestamp.
+func segmentMKVDTS(blocks []*recordstore.MKVBlockInfo) []time.Duration {
+	sorted := make([]time.Duration, len(blocks))
+	for i, block := range blocks {
+		sorted[i] = block.PTS
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	var delay time.Duration
+	for i, block := range blocks {
+		delay = max(delay, sorted[i]-block.PTS)
+	}
+
+	for i := range sorted {
+		sorted[i] -= delay
+	}
+
+	return sorted
+}
+
+func segmentMKVMuxParts(
+	r readSeekerAt,
+	startDTS time.Duration,
+	duration time.Duration,
+	tracks []*fmp4.InitTrack,
+	m muxer,
+) (time.Duration, error) {
+	h, err := recordstore.ReadMKVSegmentHeader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	blocks, err := h.ReadBlocks(r)
+	if err != nil {
+		return 0, err
+	}
+
+	segmentDuration, err := h.ReadDuration(r)
+	if err != nil {
+		return 0, err
+	}
+
+	blocksByTrack := make(map[int][]*recordstore.MKVBlockInfo)
+	for _, block := range blocks {
+		blocksByTrack[block.TrackID] = append(blocksByTrack[block.TrackID], block)
+	}
+
+	dtsByBlock := make(map[*recordstore.MKVBlockInfo]time.Duration, len(blocks))
+	for _, trackBlocks := range blocksByTrack {
+		for i, dts := range segmentMKVDTS(trackBlocks) {
+			dtsByBlock[trackBlocks[i]] = dts
+		}
+	}
+
+	type trackState struct {
+		track      *fmp4.InitTrack
+		finalDTS   int64
+		terminated bool
+	}
+
+	states := make(map[int]*trackState)
+	curTrackID := 0
+
+	for _, block := range blocks {
+		state, ok := states[block.TrackID]
+		if !ok {
+			track := findInitTrack(tracks, block.TrackID)
+			if track == nil {
+				return 0, fmt.Errorf("invalid track ID: %v", block.TrackID)
+			}
+
+			state = &trackState{
+				track:    track,
+				finalDTS: durationGoToMp4(startDTS+segmentDuration, track.TimeScale),
+			}
+			states[block.TrackID] = state
+		}
+
+		if state.terminated {
+			continue
+		}
+
+		dts := durationGoToMp4(startDTS+dtsByBlock[block], state.track.TimeScale)
+
+		if dts >= durationGoToMp4(duration, state.track.TimeScale) {
+			state.terminated = true
+			state.finalDTS = dts
+			continue
+		}
+
+		if block.TrackID != curTrackID {
+			m.setTrack(block.TrackID)
+			curTrackID = block.TrackID
+		}
+
+		pts := durationGoToMp4(startDTS+block.PTS, state.track.TimeScale)
+		offset := block.Offset
+		size := block.Size
+
+		err = m.writeSample(
+			dts,
+			int32(pts-dts),
+			!block.IsKeyFrame,
+			uint32(size),
+			func() ([]byte, error) {
+				payload := make([]byte, size)
+				n, err2 := r.ReadAt(payload, offset)
+				if err2 != nil {
+					return nil, err2
+				}
+				if n != int(size) {
+					return nil, fmt.Errorf("partial read")
+				}
+				return payload, nil
+			},
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		state.finalDTS = max(state.finalDTS, dts)
+	}
+
+	var segmentElapsed time.Duration
+
+	for _, track := range tracks {
+		state, ok := states[track.ID]
+		if !ok {
+			continue
+		}
+
+		m.setTrack(track.ID)
+		m.writeFinalDTS(state.finalDTS)
+
+		elapsed := durationMp4ToGo(state.finalDTS, state.track.TimeScale) - startDTS
+		segmentElapsed = max(segmentElapsed, elapsed)
+	}
+
+	return segmentElapsed, nil
+}
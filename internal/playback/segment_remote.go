@@ -0,0 +1,80 @@
+package playback
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/bluenviron/mediamtx/internal/objectstore"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+// amount of bytes that is downloaded in order to read the header of a remote segment.
+// It must be enough to contain the ftyp and moov boxes.
+const remoteSegmentHeaderSize = 64 * 1024
+
+// fetchRemoteSegments downloads segments that have been uploaded to an object storage
+// and whose local copy has been deleted, into temporary files that replace them.
+// When maxSize is greater than zero, only the first maxSize bytes of each segment are downloaded.
+// The returned function removes the temporary files.
+func fetchRemoteSegments(
+	ctx context.Context,
+	client *objectstore.Client,
+	segments []*recordstore.Segment,
+	maxSize int64,
+) (func(), error) {
+	var tmpFiles []string
+
+	cleanup := func() {
+		for _, fpath := range tmpFiles {
+			os.Remove(fpath)
+		}
+	}
+
+	if client == nil {
+		return cleanup, nil
+	}
+
+	for _, seg := range segments {
+		if seg.Remote == "" {
+			continue
+		}
+
+		if _, err := os.Stat(seg.Fpath); err == nil {
+			continue
+		}
+
+		fpath, err := fetchRemoteSegment(ctx, client, seg.Remote, maxSize)
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+
+		tmpFiles = append(tmpFiles, fpath)
+		seg.Fpath = fpath
+	}
+
+	return cleanup, nil
+}
+
+func fetchRemoteSegment(ctx context.Context, client *objectstore.Client, key string, maxSize int64) (string, error) {
+	r, err := client.Get(ctx, key, maxSize)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp("", "mediamtx-segment-*.mp4")
+	if err != nil {
+		return "", err
+	}
+
+	_, err = io.Copy(f, r)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
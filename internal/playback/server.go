@@ -2,6 +2,8 @@
 package playback
 
 import (
+	"crypto/ed25519"
+	"fmt"
 	"net"
 	"net/http"
 	"sync"
@@ -10,6 +12,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/auth"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/objectstore"
 	"github.com/bluenviron/mediamtx/internal/protocols/httpp"
 	"github.com/gin-gonic/gin"
 )
@@ -20,24 +23,39 @@ type serverAuthManager interface {
 
 // Server is the playback server.
 type Server struct {
-	Address        string
-	Encryption     bool
-	ServerKey      string
-	ServerCert     string
-	AllowOrigins   []string
-	TrustedProxies conf.IPNetworks
-	ReadTimeout    conf.Duration
-	WriteTimeout   conf.Duration
-	PathConfs      map[string]*conf.Path
-	AuthManager    serverAuthManager
-	Parent         logger.Writer
-
+	Address         string
+	Encryption      bool
+	ServerKey       string
+	ServerCert      string
+	AllowOrigins    []string
+	TrustedProxies  conf.IPNetworks
+	SigningKey      string
+	ExportPath      string
+	ExportWorkers   int
+	ExportRetention conf.Duration
+	ObjectStore     *objectstore.Client
+	ReadTimeout     conf.Duration
+	WriteTimeout    conf.Duration
+	PathConfs       map[string]*conf.Path
+	AuthManager     serverAuthManager
+	Parent          logger.Writer
+
+	signingKey ed25519.PrivateKey
+	exports    *exportManager
 	httpServer *httpp.Server
 	mutex      sync.RWMutex
 }
 
 // Initialize initializes Server.
 func (s *Server) Initialize() error {
+	if s.SigningKey != "" {
+		var err error
+		s.signingKey, err = loadSigningKey(s.SigningKey)
+		if err != nil {
+			return fmt.Errorf("unable to load signing key: %w", err)
+		}
+	}
+
 	router := gin.New()
 	router.SetTrustedProxies(s.TrustedProxies.ToTrustedProxies()) //nolint:errcheck
 
@@ -45,6 +63,26 @@ func (s *Server) Initialize() error {
 
 	router.GET("/list", s.onList)
 	router.GET("/get", s.onGet)
+	router.GET("/timelapse", s.onTimelapse)
+
+	if s.ExportPath != "" {
+		s.exports = &exportManager{
+			path:         s.ExportPath,
+			workerCount:  max(s.ExportWorkers, 1),
+			retention:    time.Duration(s.ExportRetention),
+			objectStore:  s.ObjectStore,
+			findPathConf: s.safeFindPathConf,
+			parent:       s,
+		}
+		err := s.exports.initialize()
+		if err != nil {
+			return fmt.Errorf("unable to initialize exports: %w", err)
+		}
+
+		router.POST("/exports", s.onExportsAdd)
+		router.GET("/exports/:id", s.onExportsGet)
+		router.GET("/exports/:id/download", s.onExportsDownload)
+	}
 
 	s.httpServer = &httpp.Server{
 		Address:      s.Address,
@@ -59,6 +97,9 @@ func (s *Server) Initialize() error {
 	}
 	err := s.httpServer.Initialize()
 	if err != nil {
+		if s.exports != nil {
+			s.exports.close()
+		}
 		return err
 	}
 
@@ -71,6 +112,10 @@ func (s *Server) Initialize() error {
 func (s *Server) Close() {
 	s.Log(logger.Info, "listener is closing")
 	s.httpServer.Close()
+
+	if s.exports != nil {
+		s.exports.close()
+	}
 }
 
 // Log implements logger.Writer.
@@ -104,8 +149,8 @@ func (s *Server) safeFindPathConf(name string) (*conf.Path, error) {
 func (s *Server) middlewarePreflightRequests(ctx *gin.Context) {
 	if ctx.Request.Method == http.MethodOptions &&
 		ctx.Request.Header.Get("Access-Control-Request-Method") != "" {
-		ctx.Header("Access-Control-Allow-Methods", "OPTIONS, GET")
-		ctx.Header("Access-Control-Allow-Headers", "Authorization")
+		ctx.Header("Access-Control-Allow-Methods", "OPTIONS, GET, POST")
+		ctx.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
 		ctx.AbortWithStatus(http.StatusNoContent)
 		return
 	}
@@ -0,0 +1,273 @@
+package playback
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+const (
+	timelapseDefaultFPS = 25
+	timelapseMaxFPS     = 120
+)
+
+type timelapseFrame struct {
+	dts         time.Duration
+	payloadSize uint32
+	getPayload  func() ([]byte, error)
+	payload     []byte
+}
+
+// load reads the payload of the frame into memory,
+// in order to allow closing the segment that contains it.
+func (f *timelapseFrame) load() error {
+	if f.payload != nil {
+		return nil
+	}
+
+	var err error
+	f.payload, err = f.getPayload()
+	return err
+}
+
+// timelapseSegmentEnder is implemented by muxers that have to be notified
+// before the segment that is being read is closed.
+type timelapseSegmentEnder interface {
+	segmentEnd() error
+}
+
+// timelapseMuxer wraps a muxer in order to produce a timelapse.
+// It keeps the first video track only, picks the keyframe that is nearest to
+// each multiple of interval and writes picked keyframes with a fixed frame rate.
+type timelapseMuxer struct {
+	m        muxer
+	interval time.Duration
+	fps      float64
+	duration time.Duration
+
+	track      *fmp4.InitTrack
+	curTrack   bool
+	nextTarget time.Duration
+	candidate  *timelapseFrame
+	lastPicked *timelapseFrame
+	frameCount int64
+}
+
+func timelapseTrack(tracks []*fmp4.InitTrack) *fmp4.InitTrack {
+	for _, track := range tracks {
+		if track.Codec.IsVideo() {
+			return track
+		}
+	}
+	return nil
+}
+
+func (w *timelapseMuxer) writeInit(init *fmp4.Init) {
+	w.track = timelapseTrack(init.Tracks)
+
+	if w.track != nil {
+		w.m.writeInit(&fmp4.Init{
+			Tracks: []*fmp4.InitTrack{w.track},
+		})
+		w.m.setTrack(w.track.ID)
+	}
+}
+
+func (w *timelapseMuxer) setTrack(trackID int) {
+	w.curTrack = w.track != nil && trackID == w.track.ID
+}
+
+func (w *timelapseMuxer) frameDTS(n int64) int64 {
+	return int64(float64(n) * float64(w.track.TimeScale) / w.fps)
+}
+
+func (w *timelapseMuxer) pick(frame *timelapseFrame) error {
+	// a keyframe can be the nearest one to multiple targets, write it once
+	if frame == w.lastPicked {
+		return nil
+	}
+	w.lastPicked = frame
+
+	err := frame.load()
+	if err != nil {
+		return err
+	}
+
+	err = w.m.writeSample(
+		w.frameDTS(w.frameCount),
+		0,
+		false,
+		frame.payloadSize,
+		func() ([]byte, error) {
+			return frame.payload, nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	w.frameCount++
+	return nil
+}
+
+func (w *timelapseMuxer) writeSample(
+	dts int64,
+	_ int32,
+	isNonSyncSample bool,
+	payloadSize uint32,
+	getPayload func() ([]byte, error),
+) error {
+	if !w.curTrack || isNonSyncSample {
+		return nil
+	}
+
+	frame := &timelapseFrame{
+		dts:         durationMp4ToGo(dts, w.track.TimeScale),
+		payloadSize: payloadSize,
+		getPayload:  getPayload,
+	}
+
+	for w.nextTarget < w.duration && frame.dts >= w.nextTarget {
+		if w.candidate != nil && (w.nextTarget-w.candidate.dts) <= (frame.dts-w.nextTarget) {
+			err := w.pick(w.candidate)
+			if err != nil {
+				return err
+			}
+		} else {
+			err := w.pick(frame)
+			if err != nil {
+				return err
+			}
+		}
+
+		w.nextTarget += w.interval
+	}
+
+	w.candidate = frame
+	return nil
+}
+
+func (w *timelapseMuxer) writeFinalDTS(_ int64) {
+}
+
+// segmentEnd implements timelapseSegmentEnder.
+// The candidate keyframe may be picked when reading the following segment.
+func (w *timelapseMuxer) segmentEnd() error {
+	if w.candidate == nil {
+		return nil
+	}
+	return w.candidate.load()
+}
+
+func (w *timelapseMuxer) flush() error {
+	if w.track == nil {
+		return fmt.Errorf("recordings do not contain any video track")
+	}
+
+	// the last keyframe is the nearest one to the remaining target
+	if w.nextTarget < w.duration && w.candidate != nil {
+		err := w.pick(w.candidate)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.m.setTrack(w.track.ID)
+	w.m.writeFinalDTS(w.frameDTS(w.frameCount))
+
+	return w.m.flush()
+}
+
+// timelapseMux writes samples of segments into a timelapseMuxer.
+// Unlike seekAndMux, gaps between segments are allowed,
+// and segments are placed on the timeline by their start time.
+func timelapseMux(
+	pathConf *conf.Path,
+	segments []*recordstore.Segment,
+	start time.Time,
+	duration time.Duration,
+	m muxer,
+) error {
+	if pathConf.RecordFormat == conf.RecordFormatMPEGTS {
+		return fmt.Errorf("MPEG-TS format is not supported yet")
+	}
+
+	keys, err := recordstore.LoadEncryptionKeys(pathConf)
+	if err != nil {
+		return err
+	}
+
+	var firstTrack *fmp4.InitTrack
+
+	for i, seg := range segments {
+		var ok bool
+		ok, err = timelapseMuxSegment(pathConf, seg, i == 0, &firstTrack, keys, start, duration, m)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	return m.flush()
+}
+
+// timelapseMuxSegment writes samples of a segment into a timelapseMuxer.
+// Payloads of picked keyframes are read before the segment is closed,
+// therefore a single segment is open at once.
+func timelapseMuxSegment(
+	pathConf *conf.Path,
+	seg *recordstore.Segment,
+	first bool,
+	firstTrack **fmp4.InitTrack,
+	keys []recordstore.EncryptionKey,
+	start time.Time,
+	duration time.Duration,
+	m muxer,
+) (bool, error) {
+	f, err := os.Open(seg.Fpath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	init, _, err := segmentReadHeader(pathConf.RecordFormat, f)
+	if err != nil {
+		return false, err
+	}
+
+	if first {
+		*firstTrack = timelapseTrack(init.Tracks)
+		m.writeInit(&fmp4.Init{
+			Tracks: init.Tracks,
+		})
+	} else if !reflect.DeepEqual(timelapseTrack(init.Tracks), *firstTrack) {
+		// video track can't change within a MP4 file
+		return false, nil
+	}
+
+	cipher, err := recordstore.OpenSegmentCipher(init.UserData, keys)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = segmentMuxParts(pathConf.RecordFormat, f, seg.Start.Sub(start), duration, init.Tracks, cipher, m)
+	if err != nil {
+		return false, err
+	}
+
+	if se, ok := m.(timelapseSegmentEnder); ok {
+		err = se.segmentEnd()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
@@ -179,6 +179,7 @@ func setupVideoTrack(
 
 func setupAudioTracks(
 	desc *description.Session,
+	names map[*description.Media]string,
 	r *stream.Reader,
 	muxer *gohlslib.Muxer,
 ) {
@@ -201,6 +202,7 @@ func setupAudioTracks(
 						ChannelCount: forma.ChannelCount,
 					},
 					ClockRate: forma.ClockRate(),
+					Name:      names[media],
 				}
 
 				addTrack(
@@ -226,6 +228,7 @@ func setupAudioTracks(
 						Config: *forma.Config,
 					},
 					ClockRate: forma.ClockRate(),
+					Name:      names[media],
 				}
 
 				addTrack(
@@ -256,6 +259,7 @@ func setupAudioTracks(
 							Config: *forma.StreamMuxConfig.Programs[0].Layers[0].AudioSpecificConfig,
 						},
 						ClockRate: forma.ClockRate(),
+						Name:      names[media],
 					}
 
 					addTrack(
@@ -287,8 +291,10 @@ func setupAudioTracks(
 }
 
 // FromStream maps a MediaMTX stream to a HLS muxer.
+// names are optional names of medias, used as names of audio renditions.
 func FromStream(
 	desc *description.Session,
+	names map[*description.Media]string,
 	r *stream.Reader,
 	muxer *gohlslib.Muxer,
 ) error {
@@ -300,6 +306,7 @@ func FromStream(
 
 	setupAudioTracks(
 		desc,
+		names,
 		r,
 		muxer,
 	)
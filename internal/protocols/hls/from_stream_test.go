@@ -27,7 +27,7 @@ func TestFromStreamNoSupportedCodecs(t *testing.T) {
 
 	m := &gohlslib.Muxer{}
 
-	err := FromStream(desc, r, m)
+	err := FromStream(desc, nil, r, m)
 	require.Equal(t, ErrNoSupportedCodecs, err)
 }
 
@@ -64,8 +64,38 @@ func TestFromStreamSkipUnsupportedTracks(t *testing.T) {
 		}),
 	}
 
-	err := FromStream(desc, r, m)
+	err := FromStream(desc, nil, r, m)
 	require.NoError(t, err)
 
 	require.Equal(t, 2, n)
 }
+
+func TestFromStreamNames(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{test.FormatH264},
+		},
+		{
+			Type:    description.MediaTypeAudio,
+			Formats: []format.Format{test.FormatMPEG4Audio},
+		},
+		{
+			Type:    description.MediaTypeAudio,
+			Formats: []format.Format{&format.Opus{PayloadTyp: 97, ChannelCount: 2}},
+		},
+	}}
+
+	r := &stream.Reader{
+		Parent: test.NilLogger,
+	}
+
+	m := &gohlslib.Muxer{}
+
+	err := FromStream(desc, map[*description.Media]string{desc.Medias[2]: "English"}, r, m)
+	require.NoError(t, err)
+
+	require.Len(t, m.Tracks, 3)
+	require.Equal(t, "", m.Tracks[1].Name)
+	require.Equal(t, "English", m.Tracks[2].Name)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
@@ -646,9 +647,21 @@ func setupAudioTrack(
 	return nil, nil
 }
 
-// FromStream maps a MediaMTX stream to a WebRTC connection
+// trackID returns the name given to the media that contains a format.
+func trackID(desc *description.Session, names map[*description.Media]string, forma format.Format) string {
+	for _, media := range desc.Medias {
+		if slices.Contains(media.Formats, forma) {
+			return names[media]
+		}
+	}
+	return ""
+}
+
+// FromStream maps a MediaMTX stream to a WebRTC connection.
+// names are optional names of medias, used as track IDs.
 func FromStream(
 	desc *description.Session,
+	names map[*description.Media]string,
 	r *stream.Reader,
 	pc *PeerConnection,
 ) error {
@@ -657,11 +670,19 @@ func FromStream(
 		return err
 	}
 
+	if videoFormat != nil {
+		pc.OutgoingTracks[len(pc.OutgoingTracks)-1].ID = trackID(desc, names, videoFormat)
+	}
+
 	audioFormat, err := setupAudioTrack(desc, r, pc)
 	if err != nil {
 		return err
 	}
 
+	if audioFormat != nil {
+		pc.OutgoingTracks[len(pc.OutgoingTracks)-1].ID = trackID(desc, names, audioFormat)
+	}
+
 	if videoFormat == nil && audioFormat == nil {
 		return errNoSupportedCodecsFrom
 	}
@@ -22,6 +22,7 @@ var rtcpBufferPool = sync.Pool{
 // OutgoingTrack is a WebRTC outgoing track
 type OutgoingTrack struct {
 	Caps webrtc.RTPCodecCapability
+	ID   string // optional
 
 	track      *webrtc.TrackLocalStaticRTP
 	ssrc       uint32
@@ -33,11 +34,13 @@ func (t *OutgoingTrack) isVideo() bool {
 }
 
 func (t *OutgoingTrack) setup(p *PeerConnection) error {
-	var trackID string
-	if t.isVideo() {
-		trackID = "video"
-	} else {
-		trackID = "audio"
+	trackID := t.ID
+	if trackID == "" {
+		if t.isVideo() {
+			trackID = "video"
+		} else {
+			trackID = "audio"
+		}
 	}
 
 	var err error
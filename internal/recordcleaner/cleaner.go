@@ -3,6 +3,7 @@ package recordcleaner
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -11,15 +12,18 @@ import (
 
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/objectstore"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
 var timeNow = time.Now
 
-// Cleaner removes expired recording segments from disk.
+// Cleaner removes expired recording segments from disk and from the object storage
+// and downsamples segments that are older than recordDownsampleAfter.
 type Cleaner struct {
-	PathConfs map[string]*conf.Path
-	Parent    logger.Writer
+	PathConfs   map[string]*conf.Path
+	ObjectStore *objectstore.Client
+	Parent      logger.Writer
 
 	ctx       context.Context
 	ctxCancel func()
@@ -83,6 +87,11 @@ func (c *Cleaner) cleanInterval() time.Duration {
 			interval > (time.Duration(e.RecordDeleteAfter)/2) {
 			interval = time.Duration(e.RecordDeleteAfter) / 2
 		}
+
+		if e.RecordDownsampleAfter != 0 &&
+			interval > (time.Duration(e.RecordDownsampleAfter)/2) {
+			interval = time.Duration(e.RecordDownsampleAfter) / 2
+		}
 	}
 
 	return interval
@@ -104,6 +113,13 @@ func (c *Cleaner) processPath(now time.Time, pathName string) error {
 		return err
 	}
 
+	if pathConf.RecordDownsampleAfter != 0 {
+		err = c.downsampleSegments(now, pathName, pathConf)
+		if err != nil {
+			return err
+		}
+	}
+
 	if pathConf.RecordDeleteAfter == 0 {
 		return nil
 	}
@@ -113,6 +129,11 @@ func (c *Cleaner) processPath(now time.Time, pathName string) error {
 		return err
 	}
 
+	err = recordstore.PruneRecordingStops(pathConf, pathName)
+	if err != nil {
+		c.Log(logger.Warn, "unable to prune recording stops of path '%s': %v", pathName, err)
+	}
+
 	c.deleteEmptyDirs(pathConf)
 
 	return nil
@@ -120,14 +141,116 @@ func (c *Cleaner) processPath(now time.Time, pathName string) error {
 
 func (c *Cleaner) deleteExpiredSegments(now time.Time, pathName string, pathConf *conf.Path) error {
 	end := now.Add(-time.Duration(pathConf.RecordDeleteAfter))
-	segments, err := recordstore.FindSegments(pathConf, pathName, nil, &end)
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	locks, err := recordstore.FindRetentionLocks(pathConf, pathName)
 	if err != nil {
+		c.Log(logger.Warn, "unable to read locks of path '%s', skipping: %v", pathName, err)
 		return err
 	}
 
-	for _, seg := range segments {
+	for i, seg := range segments {
+		if end.Before(seg.Start) {
+			break
+		}
+
+		var next *recordstore.Segment
+		if i < (len(segments) - 1) {
+			next = segments[i+1]
+		}
+
+		if recordstore.SegmentIsLocked(locks, seg, next) {
+			c.Log(logger.Debug, "skipping %s since it is locked", seg.Fpath)
+			continue
+		}
+
+		// uploaded segments are removed from the index only after the object has been deleted,
+		// in order to retry at the next pass in case of errors.
+		if seg.Remote != "" && c.ObjectStore != nil {
+			c.Log(logger.Debug, "removing object %s", seg.Remote)
+			err = c.ObjectStore.Delete(c.ctx, seg.Remote)
+			if err != nil {
+				c.Log(logger.Warn, "unable to remove object %s: %v", seg.Remote, err)
+				continue
+			}
+		}
+
 		c.Log(logger.Debug, "removing %s", seg.Fpath)
-		os.Remove(seg.Fpath)
+		err = recordstore.RemoveSegment(pathConf, pathName, seg.Fpath)
+		if err != nil {
+			c.Log(logger.Warn, "unable to remove %s: %v", seg.Fpath, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Cleaner) downsampleSegments(now time.Time, pathName string, pathConf *conf.Path) error {
+	end := now.Add(-time.Duration(pathConf.RecordDownsampleAfter))
+	segments, err := recordstore.FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	locks, err := recordstore.FindRetentionLocks(pathConf, pathName)
+	if err != nil {
+		c.Log(logger.Warn, "unable to read locks of path '%s', skipping: %v", pathName, err)
+		return err
+	}
+
+	for i, seg := range segments {
+		// the whole segment must be older than the threshold.
+		// Segments without a duration are still being written or have not been finalized;
+		// their end is the start of the following one.
+		var segEnd time.Time
+		var next *recordstore.Segment
+
+		if i != len(segments)-1 {
+			next = segments[i+1]
+		}
+
+		switch {
+		case seg.Duration != 0:
+			segEnd = seg.Start.Add(seg.Duration)
+		case next != nil:
+			segEnd = next.Start
+		default:
+			continue
+		}
+
+		if end.Before(segEnd) {
+			break
+		}
+
+		// segments that have already been downsampled are skipped without opening them
+		if seg.Sparse {
+			continue
+		}
+
+		if recordstore.SegmentIsLocked(locks, seg, next) {
+			continue
+		}
+
+		// local copy has been deleted after an upload
+		if _, err = os.Stat(seg.Fpath); err != nil {
+			continue
+		}
+
+		var res *recordstore.DownsampleResult
+		res, err = recordstore.DownsampleSegment(pathConf, pathName, seg)
+		if err != nil {
+			if !errors.Is(err, recordstore.ErrSegmentNotDownsampleable) {
+				c.Log(logger.Warn, "unable to downsample %s: %v", seg.Fpath, err)
+			}
+			continue
+		}
+
+		if res.Downsampled {
+			c.Log(logger.Debug, "downsampled %s (%d -> %d bytes)", seg.Fpath, res.SizeBefore, res.SizeAfter)
+		}
 	}
 
 	return nil
@@ -1,13 +1,20 @@
 package recordcleaner
 
 import (
+	"bytes"
+	"context"
 	"os"
 	"path/filepath"
 	"regexp"
 	"testing"
 	"time"
 
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/objectstore"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/test"
 	"github.com/stretchr/testify/require"
 )
@@ -108,3 +115,203 @@ func TestCleanerMultipleEntriesSamePath(t *testing.T) {
 	_, err = os.Stat(filepath.Join(dir, "path2", "2009-05-19_22-15-25-000427.mp4"))
 	require.NoError(t, err)
 }
+
+func TestCleanerLockedSegments(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000.mp4"), []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:              "mypath",
+		RecordPath:        filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:      conf.RecordFormatFMP4,
+		RecordDeleteAfter: conf.Duration(10 * time.Second),
+	}
+
+	err = recordstore.AddLock(pathConf, &recordstore.Lock{
+		Path:  "mypath",
+		Start: time.Date(2008, 5, 20, 22, 15, 30, 0, time.Local),
+		End:   time.Date(2008, 5, 20, 22, 15, 40, 0, time.Local),
+	})
+	require.NoError(t, err)
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": pathConf,
+		},
+		Parent: test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, "mypath", "2008-05-20_22-16-25-000000.mp4"))
+	require.Error(t, err)
+}
+
+func TestCleanerUploadedSegments(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	segmentPath := filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4")
+	err = os.WriteFile(segmentPath, []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	store := test.NewObjectStore()
+	defer store.Close()
+
+	client := &objectstore.Client{
+		Endpoint:  store.URL(),
+		Bucket:    "mybucket",
+		Region:    "us-east-1",
+		AccessKey: "myaccess",
+		SecretKey: "mysecret",
+	}
+
+	_, err = client.Put(context.Background(), "mypath/2008-05-20_22-15-25-000000.mp4", bytes.NewReader([]byte{1}), 1)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:              "mypath",
+		RecordPath:        filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat:      conf.RecordFormatFMP4,
+		RecordDeleteAfter: conf.Duration(10 * time.Second),
+	}
+
+	err = recordstore.IndexSegmentUpload(pathConf, "mypath", segmentPath, "mypath/2008-05-20_22-15-25-000000.mp4")
+	require.NoError(t, err)
+
+	// local copy has been deleted after the upload
+	err = os.Remove(segmentPath)
+	require.NoError(t, err)
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": pathConf,
+		},
+		ObjectStore: client,
+		Parent:      test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	_, ok := store.Object("mybucket/mypath/2008-05-20_22-15-25-000000.mp4")
+	require.False(t, ok)
+
+	_, err = recordstore.FindSegments(pathConf, "mypath", nil, nil)
+	require.Equal(t, recordstore.ErrNoSegmentsFound, err)
+}
+
+func writeSegment(t *testing.T, fpath string) {
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &mp4.CodecH264{
+				SPS: test.FormatH264.SPS,
+				PPS: test.FormatH264.PPS,
+			},
+		}},
+	}
+
+	var buf seekablebuffer.Buffer
+	err := init.Marshal(&buf)
+	require.NoError(t, err)
+
+	part := &fmp4.Part{
+		Tracks: []*fmp4.PartTrack{{
+			ID: 1,
+			Samples: []*fmp4.Sample{
+				{Duration: 90000, Payload: []byte{1}},
+				{Duration: 90000, Payload: []byte{2}, IsNonSyncSample: true},
+			},
+		}},
+	}
+
+	var partBuf seekablebuffer.Buffer
+	err = part.Marshal(&partBuf)
+	require.NoError(t, err)
+
+	_, err = buf.Write(partBuf.Bytes())
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	// write duration
+	_, err = recordstore.RepairSegmentFMP4(fpath)
+	require.NoError(t, err)
+}
+
+func TestCleanerDownsample(t *testing.T) {
+	timeNow = func() time.Time {
+		return time.Date(2009, 5, 20, 22, 15, 25, 427000, time.Local)
+	}
+
+	dir, err := os.MkdirTemp("", "mediamtx-cleaner")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	writeSegment(t, filepath.Join(dir, "mypath", "2009-05-10_22-15-25-000427.mp4"))
+	writeSegment(t, filepath.Join(dir, "mypath", "2009-05-20_22-15-20-000427.mp4"))
+
+	c := &Cleaner{
+		PathConfs: map[string]*conf.Path{
+			"mypath": {
+				Name:                  "mypath",
+				RecordPath:            filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+				RecordFormat:          conf.RecordFormatFMP4,
+				RecordDownsampleAfter: conf.Duration(24 * time.Hour),
+			},
+		},
+		Parent: test.NilLogger,
+	}
+	c.Initialize()
+	defer c.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	byts, err := os.ReadFile(filepath.Join(dir, "mypath", "2009-05-10_22-15-25-000427.mp4"))
+	require.NoError(t, err)
+	require.True(t, bytes.Contains(byts, []byte("mtxs")))
+
+	byts, err = os.ReadFile(filepath.Join(dir, "mypath", "2009-05-20_22-15-20-000427.mp4"))
+	require.NoError(t, err)
+	require.False(t, bytes.Contains(byts, []byte("mtxs")))
+
+	// downsampled segments are marked in the index, in order to be skipped
+	segments, err := recordstore.FindSegments(c.PathConfs["mypath"], "mypath", nil, nil)
+	require.NoError(t, err)
+	require.True(t, segments[0].Sparse)
+	require.False(t, segments[1].Sparse)
+}
@@ -8,16 +8,39 @@ import (
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
 	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
 
+// encryptPartTrack returns a copy of the part track with encrypted samples.
+// Payloads can't be encrypted in place since they are shared with other readers.
+func encryptPartTrack(partTrack *fmp4.PartTrack, cipher *recordstore.SegmentCipher) *fmp4.PartTrack {
+	ret := &fmp4.PartTrack{
+		ID:       partTrack.ID,
+		BaseTime: partTrack.BaseTime,
+		Samples:  make([]*fmp4.Sample, len(partTrack.Samples)),
+	}
+
+	for i, sample := range partTrack.Samples {
+		encSample := *sample
+		encSample.Payload = cipher.EncryptSample(sample.Payload)
+		ret.Samples[i] = &encSample
+	}
+
+	return ret
+}
+
 func writePart(
 	f io.Writer,
 	sequenceNumber uint32,
 	partTracks map[*formatFMP4Track]*fmp4.PartTrack,
+	cipher *recordstore.SegmentCipher,
 ) error {
 	fmp4PartTracks := make([]*fmp4.PartTrack, len(partTracks))
 	i := 0
 	for _, partTrack := range partTracks {
+		if cipher != nil {
+			partTrack = encryptPartTrack(partTrack, cipher)
+		}
 		fmp4PartTracks[i] = partTrack
 		i++
 	}
@@ -52,8 +75,31 @@ func (p *formatFMP4Part) initialize() {
 	p.partTracks = make(map[*formatFMP4Track]*fmp4.PartTrack)
 }
 
-func (p *formatFMP4Part) close(w io.Writer) error {
-	return writePart(w, p.number, p.partTracks)
+func (p *formatFMP4Part) close(w io.Writer, cipher *recordstore.SegmentCipher) error {
+	return writePart(w, p.number, p.partTracks, cipher)
+}
+
+// closeMKV writes the part as a Matroska cluster.
+func (p *formatFMP4Part) closeMKV(w *recordstore.MKVSegmentWriter) error {
+	var blocks []*recordstore.MKVBlock
+
+	for track, partTrack := range p.partTracks {
+		timeScale := int64(track.initTrack.TimeScale)
+		dts := int64(partTrack.BaseTime)
+
+		for _, sample := range partTrack.Samples {
+			blocks = append(blocks, &recordstore.MKVBlock{
+				TrackID:    partTrack.ID,
+				DTS:        timestampToDuration(dts, int(timeScale)),
+				PTS:        timestampToDuration(dts+int64(sample.PTSOffset), int(timeScale)),
+				IsKeyFrame: !sample.IsNonSyncSample,
+				Payload:    sample.Payload,
+			})
+			dts += int64(sample.Duration)
+		}
+	}
+
+	return w.WriteCluster(blocks)
 }
 
 func (p *formatFMP4Part) write(track *formatFMP4Track, sample *sample, dts time.Duration) error {
@@ -13,6 +13,7 @@ import (
 
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/recordstore"
 )
@@ -24,6 +25,7 @@ func writeInit(
 	dts time.Duration,
 	ntp time.Time,
 	tracks []*formatFMP4Track,
+	mtxe *recordstore.Mtxe,
 ) error {
 	fmp4Tracks := make([]*fmp4.InitTrack, len(tracks))
 	for i, track := range tracks {
@@ -45,6 +47,10 @@ func writeInit(
 		},
 	}
 
+	if mtxe != nil {
+		init.UserData = append(init.UserData, mtxe)
+	}
+
 	var buf seekablebuffer.Buffer
 	err := init.Marshal(&buf)
 	if err != nil {
@@ -55,6 +61,29 @@ func writeInit(
 	return err
 }
 
+func writeMKVHeader(
+	w *recordstore.MKVSegmentWriter,
+	streamID uuid.UUID,
+	segmentNumber uint64,
+	dts time.Duration,
+	ntp time.Time,
+	tracks []*formatFMP4Track,
+) error {
+	mkvTracks := make([]*fmp4.InitTrack, len(tracks))
+	for i, track := range tracks {
+		mkvTracks[i] = track.initTrack
+	}
+
+	return w.WriteHeader(
+		&fmp4.Init{Tracks: mkvTracks},
+		&recordstore.Mtxi{
+			StreamID:      streamID,
+			SegmentNumber: segmentNumber,
+			DTS:           int64(dts),
+			NTP:           ntp.UnixNano(),
+		})
+}
+
 func writeDuration(f io.ReadWriteSeeker, d time.Duration) error {
 	_, err := f.Seek(0, io.SeekStart)
 	if err != nil {
@@ -127,6 +156,8 @@ type formatFMP4Segment struct {
 
 	path           string
 	fi             *os.File
+	cipher         *recordstore.SegmentCipher
+	mkv            *recordstore.MKVSegmentWriter
 	curPart        *formatFMP4Part
 	endDTS         time.Duration
 	nextPartNumber uint32
@@ -148,7 +179,12 @@ func (s *formatFMP4Segment) close() error {
 
 		// write overall duration in the header to speed up the playback server
 		duration := s.endDTS - s.startDTS
-		err2 := writeDuration(s.fi, duration)
+		var err2 error
+		if s.mkv != nil {
+			err2 = s.mkv.Finalize(duration)
+		} else {
+			err2 = writeDuration(s.fi, duration)
+		}
 		if err == nil {
 			err = err2
 		}
@@ -183,13 +219,43 @@ func (s *formatFMP4Segment) closeCurPart() error {
 
 		s.f.ri.onSegmentCreate(s.path)
 
+		if s.f.ri.format == conf.RecordFormatMKV {
+			s.mkv = &recordstore.MKVSegmentWriter{W: fi}
+
+			err = writeMKVHeader(
+				s.mkv,
+				s.f.ri.streamID,
+				s.number,
+				s.startDTS,
+				s.startNTP,
+				s.f.tracks)
+			if err != nil {
+				fi.Close()
+				return err
+			}
+
+			s.fi = fi
+			return s.curPart.closeMKV(s.mkv)
+		}
+
+		var mtxe *recordstore.Mtxe
+
+		if s.f.ri.encryptionKey != nil {
+			s.cipher, mtxe, err = recordstore.NewSegmentCipher(s.f.ri.encryptionKey)
+			if err != nil {
+				fi.Close()
+				return err
+			}
+		}
+
 		err = writeInit(
 			fi,
 			s.f.ri.streamID,
 			s.number,
 			s.startDTS,
 			s.startNTP,
-			s.f.tracks)
+			s.f.tracks,
+			mtxe)
 		if err != nil {
 			fi.Close()
 			return err
@@ -198,7 +264,11 @@ func (s *formatFMP4Segment) closeCurPart() error {
 		s.fi = fi
 	}
 
-	return s.curPart.close(s.fi)
+	if s.mkv != nil {
+		return s.curPart.closeMKV(s.mkv)
+	}
+
+	return s.curPart.close(s.fi, s.cipher)
 }
 
 func (s *formatFMP4Segment) write(track *formatFMP4Track, sample *sample, dts time.Duration) error {
@@ -0,0 +1,68 @@
+package recorder
+
+import (
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+// hasher appends completed segments to the hash chain of the path.
+// Hashes are computed in a dedicated routine since segments have to be read again from disk.
+type hasher struct {
+	recordPath string
+	pathFormat string
+	pathName   string
+	parent     logger.Writer
+
+	writer *recordstore.ManifestWriter
+
+	queue chan string
+	done  chan struct{}
+}
+
+func (h *hasher) initialize() error {
+	h.writer = &recordstore.ManifestWriter{
+		RecordPath: h.recordPath,
+		PathName:   h.pathName,
+	}
+	err := h.writer.Initialize()
+	if err != nil {
+		return err
+	}
+
+	h.queue = make(chan string, 64)
+	h.done = make(chan struct{})
+
+	go h.run()
+
+	return nil
+}
+
+func (h *hasher) close() {
+	close(h.queue)
+	<-h.done
+}
+
+func (h *hasher) push(segmentPath string) {
+	select {
+	case h.queue <- segmentPath:
+	default:
+		h.parent.Log(logger.Warn, "hash queue is full, segment %s is not added to hash chain", segmentPath)
+	}
+}
+
+func (h *hasher) run() {
+	defer close(h.done)
+
+	for segmentPath := range h.queue {
+		var pa recordstore.Path
+		pa.Decode(h.pathFormat, segmentPath)
+
+		e, err := h.writer.Append(segmentPath, pa.Start)
+		if err != nil {
+			h.parent.Log(logger.Error, "unable to hash segment %s: %v", segmentPath, err)
+			continue
+		}
+
+		h.parent.Log(logger.Debug, "segment %s added to hash chain (%s)", segmentPath, e.Chain)
+	}
+}
@@ -0,0 +1,33 @@
+package recorder
+
+import (
+	"os"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+func (r *Recorder) indexSegmentCreate(segmentPath string) {
+	var pa recordstore.Path
+	pa.Decode(r.pathFormat, segmentPath)
+
+	err := recordstore.IndexSegmentCreate(r.PathFormat, r.Format, r.PathName, segmentPath, pa.Start)
+	if err != nil {
+		r.Log(logger.Warn, "unable to add segment %s to the index: %v", segmentPath, err)
+	}
+}
+
+func (r *Recorder) indexSegmentComplete(segmentPath string, duration time.Duration) {
+	var size int64
+	if fi, err := os.Stat(segmentPath); err == nil {
+		size = fi.Size()
+	}
+
+	err := recordstore.IndexSegmentComplete(r.PathFormat, r.Format, r.PathName, segmentPath,
+		duration, size, defs.MediasToCodecs(r.Desc.Medias))
+	if err != nil {
+		r.Log(logger.Warn, "unable to update segment %s in the index: %v", segmentPath, err)
+	}
+}
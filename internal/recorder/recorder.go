@@ -2,10 +2,13 @@
 package recorder
 
 import (
+	"strings"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
 
@@ -22,14 +25,20 @@ type Recorder struct {
 	PartDuration      time.Duration
 	MaxPartSize       conf.StringSize
 	SegmentDuration   time.Duration
+	HashChain         bool
+	EncryptionKey     recordstore.EncryptionKey
 	PathName          string
 	Stream            *stream.Stream
+	Desc              *description.Session // medias to record. When nil, all medias of Stream are recorded.
 	OnSegmentCreate   OnSegmentCreateFunc
 	OnSegmentComplete OnSegmentCompleteFunc
 	Parent            logger.Writer
 
 	restartPause time.Duration
 
+	pathFormat      string
+	repairer        *repairer
+	hasher          *hasher
 	currentInstance *recorderInstance
 
 	terminate chan struct{}
@@ -49,6 +58,59 @@ func (r *Recorder) Initialize() {
 	if r.restartPause == 0 {
 		r.restartPause = 2 * time.Second
 	}
+	if r.Desc == nil {
+		r.Desc = r.Stream.Desc
+	}
+
+	r.pathFormat = recordstore.PathAddExtension(
+		strings.ReplaceAll(r.PathFormat, "%path", r.PathName),
+		r.Format,
+	)
+
+	// segments are repaired once, the first time the path is recorded
+	if claimRepair(r.pathFormat) {
+		r.repairer = &repairer{
+			pathConf: &conf.Path{
+				RecordPath:   r.PathFormat,
+				RecordFormat: r.Format,
+			},
+			pathName: r.PathName,
+			parent:   r,
+		}
+		r.repairer.initialize()
+	}
+
+	onSegmentCreate := r.OnSegmentCreate
+	r.OnSegmentCreate = func(path string) {
+		r.indexSegmentCreate(path)
+		onSegmentCreate(path)
+	}
+
+	onSegmentComplete := r.OnSegmentComplete
+	r.OnSegmentComplete = func(path string, duration time.Duration) {
+		r.indexSegmentComplete(path, duration)
+		onSegmentComplete(path, duration)
+	}
+
+	if r.HashChain {
+		r.hasher = &hasher{
+			recordPath: r.PathFormat,
+			pathFormat: r.pathFormat,
+			pathName:   r.PathName,
+			parent:     r,
+		}
+		err := r.hasher.initialize()
+		if err != nil {
+			r.Log(logger.Error, "unable to initialize hash chain: %v", err)
+			r.hasher = nil
+		} else {
+			onSegmentComplete := r.OnSegmentComplete
+			r.OnSegmentComplete = func(path string, duration time.Duration) {
+				r.hasher.push(path)
+				onSegmentComplete(path, duration)
+			}
+		}
+	}
 
 	r.terminate = make(chan struct{})
 	r.done = make(chan struct{})
@@ -59,8 +121,10 @@ func (r *Recorder) Initialize() {
 		partDuration:      r.PartDuration,
 		maxPartSize:       r.MaxPartSize,
 		segmentDuration:   r.SegmentDuration,
+		encryptionKey:     r.EncryptionKey,
 		pathName:          r.PathName,
 		stream:            r.Stream,
+		desc:              r.Desc,
 		onSegmentCreate:   r.OnSegmentCreate,
 		onSegmentComplete: r.OnSegmentComplete,
 		parent:            r,
@@ -80,6 +144,14 @@ func (r *Recorder) Close() {
 	r.Log(logger.Info, "recording stopped")
 	close(r.terminate)
 	<-r.done
+
+	if r.repairer != nil {
+		r.repairer.close()
+	}
+
+	if r.hasher != nil {
+		r.hasher.close()
+	}
 }
 
 func (r *Recorder) run() {
@@ -106,8 +178,10 @@ func (r *Recorder) run() {
 			partDuration:      r.PartDuration,
 			maxPartSize:       r.MaxPartSize,
 			segmentDuration:   r.SegmentDuration,
+			encryptionKey:     r.EncryptionKey,
 			pathName:          r.PathName,
 			stream:            r.Stream,
+			desc:              r.Desc,
 			onSegmentCreate:   r.OnSegmentCreate,
 			onSegmentComplete: r.OnSegmentComplete,
 			parent:            r,
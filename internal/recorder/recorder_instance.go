@@ -4,6 +4,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
 	"github.com/google/uuid"
 
@@ -25,8 +26,10 @@ type recorderInstance struct {
 	partDuration      time.Duration
 	maxPartSize       conf.StringSize
 	segmentDuration   time.Duration
+	encryptionKey     recordstore.EncryptionKey
 	pathName          string
 	stream            *stream.Stream
+	desc              *description.Session
 	onSegmentCreate   OnSegmentCreateFunc
 	onSegmentComplete OnSegmentCompleteFunc
 	parent            logger.Writer
@@ -69,6 +72,7 @@ func (ri *recorderInstance) initialize() {
 		ok := ri.format2.initialize()
 		ri.skip = !ok
 
+	// fMP4 and Matroska share the same pipeline, and differ only in how segments are serialized.
 	default:
 		ri.format2 = &formatFMP4{
 			ri: ri,
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	rtspformat "github.com/bluenviron/gortsplib/v5/pkg/format"
 	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
 	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
 	"github.com/stretchr/testify/require"
 
@@ -127,7 +129,7 @@ func TestRecorder(t *testing.T) {
 		}
 	}
 
-	for _, ca := range []string{"fmp4", "mpegts"} {
+	for _, ca := range []string{"fmp4", "mpegts", "mkv"} {
 		t.Run(ca, func(t *testing.T) {
 			strm := &stream.Stream{
 				WriteQueueSize:     512,
@@ -150,17 +152,20 @@ func TestRecorder(t *testing.T) {
 			segDone := make(chan struct{}, 4)
 
 			var f conf.RecordFormat
-			if ca == "fmp4" {
-				f = conf.RecordFormatFMP4
-			} else {
-				f = conf.RecordFormatMPEGTS
-			}
-
 			var ext string
-			if ca == "fmp4" {
+
+			switch ca {
+			case "fmp4":
+				f = conf.RecordFormatFMP4
 				ext = "mp4"
-			} else {
+
+			case "mpegts":
+				f = conf.RecordFormatMPEGTS
 				ext = "ts"
+
+			default:
+				f = conf.RecordFormatMKV
+				ext = "mkv"
 			}
 
 			n := 0
@@ -225,7 +230,7 @@ func TestRecorder(t *testing.T) {
 				<-segDone
 			}
 
-			if ca == "fmp4" {
+			if ca != "mpegts" {
 				var init fmp4.Init
 
 				func() {
@@ -233,8 +238,16 @@ func TestRecorder(t *testing.T) {
 					require.NoError(t, err2)
 					defer f.Close()
 
-					err2 = init.Unmarshal(f)
-					require.NoError(t, err2)
+					if ca == "mkv" {
+						var h *recordstore.MKVSegmentHeader
+						h, err2 = recordstore.ReadMKVSegmentHeader(f)
+						require.NoError(t, err2)
+						require.Equal(t, 2*time.Second, h.Duration)
+						init = *h.Init
+					} else {
+						err2 = init.Unmarshal(f)
+						require.NoError(t, err2)
+					}
 				}()
 
 				require.Equal(t, fmp4.Init{
@@ -388,6 +401,35 @@ func TestRecorder(t *testing.T) {
 					},
 				}, parts)
 			}
+
+			if ca == "mkv" {
+				var f *os.File
+				f, err = os.Open(filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mkv"))
+				require.NoError(t, err)
+				defer f.Close()
+
+				var h *recordstore.MKVSegmentHeader
+				h, err = recordstore.ReadMKVSegmentHeader(f)
+				require.NoError(t, err)
+
+				var blocks []*recordstore.MKVBlockInfo
+				blocks, err = h.ReadBlocks(f)
+				require.NoError(t, err)
+
+				for _, block := range blocks {
+					block.Offset = 0
+					block.Size = 0
+				}
+
+				require.Equal(t, []*recordstore.MKVBlockInfo{
+					{TrackID: 1, IsKeyFrame: true},
+					{TrackID: 2, IsKeyFrame: true},
+					{TrackID: 3, IsKeyFrame: true},
+					{TrackID: 4, IsKeyFrame: true},
+					{TrackID: 5, IsKeyFrame: true},
+					{TrackID: 1, PTS: 100 * time.Millisecond, IsKeyFrame: true},
+				}, blocks)
+			}
 		})
 	}
 }
@@ -586,6 +628,108 @@ func TestRecorderFMP4NegativeDTSDiff(t *testing.T) {
 	}}, parts)
 }
 
+func TestRecorderFMP4Encryption(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeAudio,
+			Formats: []rtspformat.Format{&rtspformat.MPEG4Audio{
+				PayloadTyp: 96,
+				Config: &mpeg4audio.AudioSpecificConfig{
+					Type:         2,
+					SampleRate:   44100,
+					ChannelCount: 2,
+				},
+				SizeLength:       13,
+				IndexLength:      3,
+				IndexDeltaLength: 3,
+			}},
+		},
+	}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	recordPath := filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f")
+
+	key, err := recordstore.ParseEncryptionKey(strings.Repeat("01", 32))
+	require.NoError(t, err)
+
+	w := &Recorder{
+		PathFormat:      recordPath,
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		MaxPartSize:     50 * 1024 * 1024,
+		SegmentDuration: 1 * time.Second,
+		EncryptionKey:   key,
+		PathName:        "mypath",
+		Stream:          strm,
+		Parent:          test.NilLogger,
+	}
+	w.Initialize()
+
+	for i := range 3 {
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+			PTS:     int64(i) * 200 * 44100 / 1000,
+			NTP:     time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC),
+			Payload: unit.PayloadMPEG4Audio{{1, 2, 3, byte(i)}},
+		})
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	w.Close()
+
+	fpath := filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4")
+
+	f, err := os.Open(fpath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var init fmp4.Init
+	err = init.Unmarshal(f)
+	require.NoError(t, err)
+
+	cipher, err := recordstore.OpenSegmentCipher(init.UserData, []recordstore.EncryptionKey{key})
+	require.NoError(t, err)
+	require.NotNil(t, cipher)
+
+	byts, err := os.ReadFile(fpath)
+	require.NoError(t, err)
+
+	var parts fmp4.Parts
+	err = parts.Unmarshal(byts)
+	require.NoError(t, err)
+
+	var payloads [][]byte
+
+	for _, part := range parts {
+		for _, track := range part.Tracks {
+			for _, sample := range track.Samples {
+				require.NotContains(t, string(sample.Payload), string([]byte{1, 2, 3}))
+
+				var payload []byte
+				payload, err = cipher.DecryptSample(sample.Payload)
+				require.NoError(t, err)
+				payloads = append(payloads, payload)
+			}
+		}
+	}
+
+	require.Equal(t, [][]byte{{1, 2, 3, 0}, {1, 2, 3, 1}}, payloads)
+}
+
 func TestRecorderSkipTracksPartial(t *testing.T) {
 	for _, ca := range []string{"fmp4", "mpegts"} {
 		t.Run(ca, func(t *testing.T) {
@@ -821,3 +965,92 @@ func TestRecorderFMP4SegmentSwitch(t *testing.T) {
 
 	require.Equal(t, 2, n)
 }
+
+func TestRecorderRepairWhileRecording(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{{
+		Type:    description.MediaTypeVideo,
+		Formats: []rtspformat.Format{test.FormatH264},
+	}}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	dir, err := os.MkdirTemp("", "mediamtx-agent")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	// segment left unfinalized by a crash, without any part
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &mp4.CodecH264{
+				SPS: test.FormatH264.SPS,
+				PPS: test.FormatH264.PPS,
+			},
+		}},
+	}
+	var buf seekablebuffer.Buffer
+	err = init.Marshal(&buf)
+	require.NoError(t, err)
+
+	crashedPath := filepath.Join(dir, "mypath", "2008-05-20_22-15-20-000000.mp4")
+	err = os.WriteFile(crashedPath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	var livePath string
+
+	w := &Recorder{
+		PathFormat:      filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		Format:          conf.RecordFormatFMP4,
+		PartDuration:    100 * time.Millisecond,
+		MaxPartSize:     50 * 1024 * 1024,
+		SegmentDuration: 1 * time.Hour,
+		PathName:        "mypath",
+		Stream:          strm,
+		Parent:          test.NilLogger,
+		OnSegmentCreate: func(segPath string) {
+			livePath = segPath
+		},
+	}
+	w.Initialize()
+
+	pts := 50 * time.Second
+	ntp := time.Date(2008, 5, 20, 22, 15, 25, 0, time.UTC)
+
+	for range 3 {
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+			PTS: int64(pts) * 90000 / int64(time.Second),
+			NTP: ntp,
+			Payload: unit.PayloadH264{
+				{5}, // IDR
+			},
+		})
+
+		pts += 500 * time.Millisecond
+		ntp = ntp.Add(500 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	w.Close()
+
+	require.Equal(t, filepath.Join(dir, "mypath", "2008-05-20_22-15-25-000000.mp4"), livePath)
+
+	_, err = os.Stat(livePath)
+	require.NoError(t, err)
+
+	_, err = os.Stat(crashedPath)
+	require.True(t, os.IsNotExist(err))
+}
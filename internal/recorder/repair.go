@@ -0,0 +1,80 @@
+package recorder
+
+import (
+	"sync"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+var (
+	repairedPathsMutex sync.Mutex
+	repairedPaths      = make(map[string]struct{})
+)
+
+// claimRepair returns true the first time it is called with a given path format.
+// This allows to repair segments of a path once, instead of every time recording restarts.
+func claimRepair(pathFormat string) bool {
+	repairedPathsMutex.Lock()
+	defer repairedPathsMutex.Unlock()
+
+	if _, ok := repairedPaths[pathFormat]; ok {
+		return false
+	}
+
+	repairedPaths[pathFormat] = struct{}{}
+	return true
+}
+
+// repairer repairs segments left unfinalized by a crash.
+// Segments are found before the recorder starts writing, in order not to pick
+// the ones that are being written, then they are repaired in a dedicated routine.
+type repairer struct {
+	pathConf *conf.Path
+	pathName string
+	parent   logger.Writer
+
+	segments []*recordstore.Segment
+
+	done chan struct{}
+}
+
+func (p *repairer) initialize() {
+	var err error
+	p.segments, err = recordstore.FindUnfinalizedSegments(p.pathConf, p.pathName)
+	if err != nil {
+		p.parent.Log(logger.Error, "unable to find segments to repair: %v", err)
+	}
+
+	p.done = make(chan struct{})
+
+	go p.run()
+}
+
+func (p *repairer) close() {
+	<-p.done
+}
+
+func (p *repairer) run() {
+	defer close(p.done)
+
+	for _, seg := range p.segments {
+		res, err := recordstore.RepairSegment(p.pathConf, p.pathName, seg)
+		if err != nil {
+			p.parent.Log(logger.Warn, "unable to repair segment %s: %v", seg.Fpath, err)
+			continue
+		}
+
+		switch {
+		case !res.Repaired:
+
+		case res.PartsKept == 0:
+			p.parent.Log(logger.Warn, "segment %s contained no complete parts and has been deleted", seg.Fpath)
+
+		default:
+			p.parent.Log(logger.Warn, "segment %s repaired: salvaged %d parts (%v), dropped %d bytes",
+				seg.Fpath, res.PartsKept, res.Duration, res.BytesDropped)
+		}
+	}
+}
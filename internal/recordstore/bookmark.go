@@ -0,0 +1,225 @@
+package recordstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const bookmarksFileName = ".mediamtx-bookmarks.json"
+
+// ErrBookmarkNotFound is returned when a bookmark is not found.
+var ErrBookmarkNotFound = errors.New("bookmark not found")
+
+// bookmarks of different paths may share the same sidecar file.
+var bookmarksMutex sync.Mutex
+
+// Bookmark is an annotated moment, or time range, of a recording.
+// Bookmarked recordings are excluded from deletion.
+type Bookmark struct {
+	ID      uuid.UUID  `json:"id"`
+	Path    string     `json:"path"`
+	Time    time.Time  `json:"time"`
+	End     *time.Time `json:"end"`
+	Title   string     `json:"title"`
+	Tags    []string   `json:"tags"`
+	Author  string     `json:"author"`
+	Created time.Time  `json:"created"`
+	Updated time.Time  `json:"updated"`
+}
+
+// Overlaps checks whether the bookmark overlaps with the given time range.
+// A zero end means that the range is open.
+func (b *Bookmark) Overlaps(start time.Time, end time.Time) bool {
+	return b.lock().Overlaps(start, end)
+}
+
+// lock returns the time range that is excluded from deletion.
+func (b *Bookmark) lock() *Lock {
+	l := &Lock{
+		Path:   b.Path,
+		Start:  b.Time,
+		End:    b.Time.Add(1),
+		Reason: "bookmark: " + b.Title,
+	}
+
+	if b.End != nil {
+		l.End = *b.End
+	}
+
+	return l
+}
+
+func (b *Bookmark) validate() error {
+	if b.Time.IsZero() {
+		return fmt.Errorf("time is missing")
+	}
+
+	if b.End != nil && !b.End.After(b.Time) {
+		return fmt.Errorf("end must be after time")
+	}
+
+	if b.Title == "" {
+		return fmt.Errorf("title is missing")
+	}
+
+	return nil
+}
+
+// BookmarksPath returns the path of the sidecar file that contains bookmarks of a path.
+func BookmarksPath(pathConf *conf.Path, pathName string) string {
+	return sidecarPath(pathConf.RecordPath, pathName, bookmarksFileName)
+}
+
+func readBookmarksFile(fpath string) ([]*Bookmark, error) {
+	var bookmarks []*Bookmark
+	err := readSidecarFile(fpath, &bookmarks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bookmarks file %s: %w", fpath, err)
+	}
+	return bookmarks, nil
+}
+
+func writeBookmarksFile(fpath string, bookmarks []*Bookmark) error {
+	return writeSidecarFile(fpath, bookmarks, len(bookmarks) == 0)
+}
+
+// FindBookmarks returns bookmarks of a path that overlap with the given time range.
+func FindBookmarks(pathConf *conf.Path, pathName string, start *time.Time, end *time.Time) ([]*Bookmark, error) {
+	bookmarksMutex.Lock()
+	defer bookmarksMutex.Unlock()
+
+	all, err := readBookmarksFile(BookmarksPath(pathConf, pathName))
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks := []*Bookmark{}
+	for _, b := range all {
+		if b.Path != pathName {
+			continue
+		}
+
+		if start != nil && end != nil {
+			if !b.Overlaps(*start, *end) {
+				continue
+			}
+		} else if start != nil {
+			if !b.Overlaps(*start, time.Time{}) {
+				continue
+			}
+		} else if end != nil {
+			if !b.Time.Before(*end) {
+				continue
+			}
+		}
+
+		bookmarks = append(bookmarks, b)
+	}
+
+	sort.Slice(bookmarks, func(i, j int) bool {
+		return bookmarks[i].Time.Before(bookmarks[j].Time)
+	})
+
+	return bookmarks, nil
+}
+
+// GetBookmark returns a bookmark of a path.
+func GetBookmark(pathConf *conf.Path, pathName string, id uuid.UUID) (*Bookmark, error) {
+	bookmarks, err := FindBookmarks(pathConf, pathName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bookmarks {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+
+	return nil, ErrBookmarkNotFound
+}
+
+// AddBookmark adds a bookmark to a path.
+func AddBookmark(pathConf *conf.Path, b *Bookmark) error {
+	err := b.validate()
+	if err != nil {
+		return err
+	}
+
+	bookmarksMutex.Lock()
+	defer bookmarksMutex.Unlock()
+
+	fpath := BookmarksPath(pathConf, b.Path)
+
+	bookmarks, err := readBookmarksFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	b.ID = uuid.New()
+	b.Created = time.Now()
+	b.Updated = b.Created
+	bookmarks = append(bookmarks, b)
+
+	return writeBookmarksFile(fpath, bookmarks)
+}
+
+// UpdateBookmark replaces a bookmark of a path.
+// ID, path, author and creation time of the bookmark are preserved.
+func UpdateBookmark(pathConf *conf.Path, b *Bookmark) error {
+	err := b.validate()
+	if err != nil {
+		return err
+	}
+
+	bookmarksMutex.Lock()
+	defer bookmarksMutex.Unlock()
+
+	fpath := BookmarksPath(pathConf, b.Path)
+
+	bookmarks, err := readBookmarksFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	for i, cur := range bookmarks {
+		if cur.Path == b.Path && cur.ID == b.ID {
+			b.Author = cur.Author
+			b.Created = cur.Created
+			b.Updated = time.Now()
+			bookmarks[i] = b
+			return writeBookmarksFile(fpath, bookmarks)
+		}
+	}
+
+	return ErrBookmarkNotFound
+}
+
+// RemoveBookmark removes a bookmark from a path.
+func RemoveBookmark(pathConf *conf.Path, pathName string, id uuid.UUID) error {
+	bookmarksMutex.Lock()
+	defer bookmarksMutex.Unlock()
+
+	fpath := BookmarksPath(pathConf, pathName)
+
+	bookmarks, err := readBookmarksFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range bookmarks {
+		if b.Path == pathName && b.ID == id {
+			bookmarks = append(bookmarks[:i], bookmarks[i+1:]...)
+			return writeBookmarksFile(fpath, bookmarks)
+		}
+	}
+
+	return ErrBookmarkNotFound
+}
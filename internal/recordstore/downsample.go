@@ -0,0 +1,303 @@
+package recordstore
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	amp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// ErrSegmentNotDownsampleable is returned when a segment can't be downsampled,
+// since it has not been finalized or it doesn't contain any video track.
+var ErrSegmentNotDownsampleable = errors.New("segment can't be downsampled")
+
+// DownsampleResult is the result of the downsampling of a segment.
+type DownsampleResult struct {
+	// whether the segment has been modified.
+	Downsampled bool
+
+	// whether the segment is sparse, since it has been downsampled now or previously.
+	Sparse bool
+
+	// size of the segment before and after the downsampling.
+	SizeBefore int64
+	SizeAfter  int64
+
+	// duration of the segment.
+	Duration time.Duration
+}
+
+// IsSparse checks whether a segment has been downsampled.
+func IsSparse(init *fmp4.Init) bool {
+	for _, box := range init.UserData {
+		if _, ok := box.(*Mtxs); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseTrack is a video track of a segment that is being downsampled.
+// Each key frame is kept in memory until the following one is found,
+// in order to extend its duration until it.
+type sparseTrack struct {
+	id      int
+	pending *fmp4.Sample
+	pendDTS uint64
+	endDTS  uint64
+}
+
+type sparseWriter struct {
+	f      *os.File
+	seqNum uint32
+}
+
+func (w *sparseWriter) writeSample(track *sparseTrack, dts uint64, sample *fmp4.Sample) error {
+	part := &fmp4.Part{
+		SequenceNumber: w.seqNum,
+		Tracks: []*fmp4.PartTrack{{
+			ID:       track.id,
+			BaseTime: dts,
+			Samples:  []*fmp4.Sample{sample},
+		}},
+	}
+	w.seqNum++
+
+	var buf seekablebuffer.Buffer
+	err := part.Marshal(&buf)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.f.Write(buf.Bytes())
+	return err
+}
+
+func (w *sparseWriter) flush(track *sparseTrack, nextDTS uint64) error {
+	if track.pending == nil {
+		return nil
+	}
+
+	track.pending.Duration = uint32(nextDTS - track.pendDTS)
+
+	err := w.writeSample(track, track.pendDTS, track.pending)
+	track.pending = nil
+	return err
+}
+
+// DownsampleSegmentFMP4 rewrites a fMP4 segment in order to keep key frames of video tracks only.
+// Non-video tracks are removed and the duration of each key frame is extended until the following one,
+// therefore the overall duration is preserved.
+// The segment is marked as sparse with a mtxs box; sparse segments are left untouched.
+func DownsampleSegmentFMP4(fpath string) (*DownsampleResult, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := readSegmentFMP4Header(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	if IsSparse(&h.init) {
+		return &DownsampleResult{
+			Sparse:     true,
+			SizeBefore: fi.Size(),
+			SizeAfter:  fi.Size(),
+			Duration:   time.Duration(h.mvhd.DurationV0) * time.Second / time.Duration(h.mvhd.Timescale),
+		}, nil
+	}
+
+	if h.mvhd.DurationV0 == 0 {
+		return nil, ErrSegmentNotDownsampleable
+	}
+
+	duration := time.Duration(h.mvhd.DurationV0) * time.Second / time.Duration(h.mvhd.Timescale)
+
+	var initTracks []*fmp4.InitTrack
+	tracks := make(map[int]*sparseTrack)
+
+	for _, track := range h.init.Tracks {
+		if track.Codec.IsVideo() {
+			initTracks = append(initTracks, track)
+			tracks[track.ID] = &sparseTrack{id: track.ID}
+		}
+	}
+
+	if len(initTracks) == 0 {
+		return nil, ErrSegmentNotDownsampleable
+	}
+
+	tmpPath := fpath + ".tmp"
+
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ok := false
+	defer func() {
+		if !ok {
+			out.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	init := &fmp4.Init{
+		Tracks: initTracks,
+		UserData: append(append([]amp4.IBox(nil), h.init.UserData...), &Mtxs{
+			FullBox: amp4.FullBox{
+				Version: 0,
+			},
+		}),
+	}
+
+	var buf seekablebuffer.Buffer
+	err = init.Marshal(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = out.Write(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	w := &sparseWriter{f: out, seqNum: 1}
+	pos := h.moovPos + int64(h.moovSize)
+
+	for {
+		moofSize, typ, err2 := readBoxHeader(f, pos)
+		if err2 != nil || typ != "moof" {
+			break
+		}
+
+		mdatSize, _, err2 := readBoxHeader(f, pos+int64(moofSize))
+		if err2 != nil {
+			return nil, err2
+		}
+
+		pairSize := int64(moofSize) + int64(mdatSize)
+
+		pair := make([]byte, pairSize)
+		_, err = f.ReadAt(pair, pos)
+		if err != nil {
+			return nil, err
+		}
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(pair)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range parts {
+			for _, partTrack := range part.Tracks {
+				track, ok2 := tracks[partTrack.ID]
+				if !ok2 {
+					continue
+				}
+
+				dts := partTrack.BaseTime
+
+				for _, sample := range partTrack.Samples {
+					if !sample.IsNonSyncSample {
+						err = w.flush(track, dts)
+						if err != nil {
+							return nil, err
+						}
+
+						track.pending = sample
+						track.pendDTS = dts
+					}
+
+					dts += uint64(sample.Duration)
+				}
+
+				track.endDTS = dts
+			}
+		}
+
+		pos += pairSize
+	}
+
+	for _, initTrack := range initTracks {
+		track := tracks[initTrack.ID]
+		err = w.flush(track, track.endDTS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// write duration into the new header
+
+	outInfo, err := out.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	h2, err := readSegmentFMP4Header(out, outInfo.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	err = h2.writeDuration(out, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	err = out.Sync()
+	if err != nil {
+		return nil, err
+	}
+
+	err = out.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	ok = true
+
+	err = os.Rename(tmpPath, fpath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	return &DownsampleResult{
+		Downsampled: true,
+		Sparse:      true,
+		SizeBefore:  fi.Size(),
+		SizeAfter:   outInfo.Size(),
+		Duration:    duration,
+	}, nil
+}
+
+// DownsampleSegment downsamples a segment and updates its size in the index.
+func DownsampleSegment(pathConf *conf.Path, pathName string, seg *Segment) (*DownsampleResult, error) {
+	res, err := DownsampleSegmentFMP4(seg.Fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	// segments that were already sparse are marked in the index too,
+	// since the index may have been rebuilt from the file system.
+	if !res.Sparse || (!res.Downsampled && seg.Sparse) {
+		return res, nil
+	}
+
+	err = IndexSegmentDownsample(pathConf, pathName, seg.Fpath, res.Duration, res.SizeAfter)
+	return res, err
+}
@@ -0,0 +1,157 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsampleSegmentFMP4(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{
+			{
+				ID:        1,
+				TimeScale: 90000,
+				Codec: &mp4.CodecH264{
+					SPS: test.FormatH264.SPS,
+					PPS: test.FormatH264.PPS,
+				},
+			},
+			{
+				ID:        2,
+				TimeScale: 48000,
+				Codec: &mp4.CodecMPEG4Audio{
+					Config: mpeg4audio.AudioSpecificConfig{
+						Type:         mpeg4audio.ObjectTypeAACLC,
+						SampleRate:   48000,
+						ChannelCount: 2,
+					},
+				},
+			},
+		},
+	}
+
+	var buf seekablebuffer.Buffer
+	err = init.Marshal(&buf)
+	require.NoError(t, err)
+
+	for _, part := range []*fmp4.Part{
+		{
+			SequenceNumber: 0,
+			Tracks: []*fmp4.PartTrack{
+				{
+					ID:       1,
+					BaseTime: 0,
+					Samples: []*fmp4.Sample{
+						{Duration: 90000, Payload: []byte{1}},
+						{Duration: 90000, Payload: []byte{2}, IsNonSyncSample: true},
+					},
+				},
+				{
+					ID:       2,
+					BaseTime: 0,
+					Samples: []*fmp4.Sample{
+						{Duration: 2 * 48000, Payload: []byte{9, 9, 9}},
+					},
+				},
+			},
+		},
+		{
+			SequenceNumber: 1,
+			Tracks: []*fmp4.PartTrack{{
+				ID:       1,
+				BaseTime: 2 * 90000,
+				Samples: []*fmp4.Sample{
+					{Duration: 90000, Payload: []byte{3}, IsNonSyncSample: true},
+					{Duration: 90000, Payload: []byte{4}},
+					{Duration: 90000, Payload: []byte{5}, IsNonSyncSample: true},
+				},
+			}},
+		},
+	} {
+		var partBuf seekablebuffer.Buffer
+		err = part.Marshal(&partBuf)
+		require.NoError(t, err)
+
+		_, err = buf.Write(partBuf.Bytes())
+		require.NoError(t, err)
+	}
+
+	fpath := filepath.Join(dir, "segment.mp4")
+	err = os.WriteFile(fpath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	_, err = DownsampleSegmentFMP4(fpath)
+	require.Equal(t, ErrSegmentNotDownsampleable, err)
+
+	// write duration
+	_, err = RepairSegmentFMP4(fpath)
+	require.NoError(t, err)
+
+	res, err := DownsampleSegmentFMP4(fpath)
+	require.NoError(t, err)
+	require.True(t, res.Downsampled)
+	require.Equal(t, 5*time.Second, res.Duration)
+	require.Less(t, res.SizeAfter, res.SizeBefore)
+
+	f, err := os.Open(fpath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+	require.Equal(t, res.SizeAfter, fi.Size())
+
+	h, err := readSegmentFMP4Header(f, fi.Size())
+	require.NoError(t, err)
+	require.True(t, IsSparse(&h.init))
+	require.Len(t, h.init.Tracks, 1)
+	require.Equal(t, 1, h.init.Tracks[0].ID)
+
+	d, err := segmentFMP4ReadDuration(fpath)
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, d)
+
+	rest := make([]byte, fi.Size()-h.moovPos-int64(h.moovSize))
+	_, err = f.ReadAt(rest, h.moovPos+int64(h.moovSize))
+	require.NoError(t, err)
+
+	var parts fmp4.Parts
+	err = parts.Unmarshal(rest)
+	require.NoError(t, err)
+
+	var samples []*fmp4.PartTrack
+	for _, part := range parts {
+		samples = append(samples, part.Tracks...)
+	}
+
+	require.Equal(t, []*fmp4.PartTrack{
+		{
+			ID:       1,
+			BaseTime: 0,
+			Samples:  []*fmp4.Sample{{Duration: 3 * 90000, Payload: []byte{1}}},
+		},
+		{
+			ID:       1,
+			BaseTime: 3 * 90000,
+			Samples:  []*fmp4.Sample{{Duration: 2 * 90000, Payload: []byte{4}}},
+		},
+	}, samples)
+
+	// sparse segments are left untouched
+	res, err = DownsampleSegmentFMP4(fpath)
+	require.NoError(t, err)
+	require.False(t, res.Downsampled)
+}
@@ -0,0 +1,219 @@
+package recordstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	amp4 "github.com/abema/go-mp4"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// ErrEncryptionKeyNotFound is returned when the key that encrypted a segment is not available.
+var ErrEncryptionKeyNotFound = errors.New("encryption key of segment not found")
+
+// EncryptionKey is a key-encryption key (KEK).
+// It encrypts the data keys of segments, that in turn encrypt samples.
+type EncryptionKey []byte
+
+// ID returns the identifier of the key, that is stored into segments.
+func (k EncryptionKey) ID() [8]byte {
+	sum := sha256.Sum256(k)
+	var id [8]byte
+	copy(id[:], sum[:8])
+	return id
+}
+
+// ParseEncryptionKey parses a hex-encoded key-encryption key.
+func ParseEncryptionKey(s string) (EncryptionKey, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes encoded in hex")
+	}
+	return key, nil
+}
+
+// LoadEncryptionKeys returns the key-encryption keys of a path.
+// The first key is the one used to encrypt new segments,
+// the others are kept in order to decrypt segments encrypted before a key rotation.
+// It returns nil when encryption is disabled.
+func LoadEncryptionKeys(pathConf *conf.Path) ([]EncryptionKey, error) {
+	var keys []EncryptionKey
+
+	if pathConf.RecordEncryptionKey != "" {
+		key, err := ParseEncryptionKey(pathConf.RecordEncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid 'recordEncryptionKey': %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if pathConf.RecordEncryptionKeyFile != "" {
+		byts, err := os.ReadFile(pathConf.RecordEncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, line := range strings.Split(string(byts), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			key, err := ParseEncryptionKey(line)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key at line %d of %s: %w", i+1, pathConf.RecordEncryptionKeyFile, err)
+			}
+			keys = append(keys, key)
+		}
+
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no keys found in %s", pathConf.RecordEncryptionKeyFile)
+		}
+	}
+
+	return keys, nil
+}
+
+// SegmentCipherIVSize is the size of the IV that is prepended to each encrypted sample.
+const SegmentCipherIVSize = 8
+
+// SegmentCipher encrypts and decrypts samples of a segment.
+//
+// Samples are encrypted with AES-256-CTR, using the data key of the segment
+// and a per-sample IV that is prepended to the encrypted payload. Since box structure is left intact,
+// encrypted segments can be indexed, repaired and downsampled without knowing the key.
+type SegmentCipher struct {
+	block   cipher.Block
+	counter uint64
+}
+
+func newKeyWrapper(kek EncryptionKey) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// NewSegmentCipher generates a random data key, and returns
+// a SegmentCipher that uses it and the box that must be stored into the segment.
+func NewSegmentCipher(kek EncryptionKey) (*SegmentCipher, *Mtxe, error) {
+	dataKey := make([]byte, 32)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gcm, err := newKeyWrapper(kek)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	box := &Mtxe{
+		FullBox: amp4.FullBox{
+			Version: 0,
+		},
+		KeyID: kek.ID(),
+	}
+
+	_, err = rand.Read(box.Nonce[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	copy(box.WrappedKey[:], gcm.Seal(nil, box.Nonce[:], dataKey, box.KeyID[:]))
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &SegmentCipher{block: block}, box, nil
+}
+
+// FindMtxe returns the encryption info of a segment, or nil if the segment is not encrypted.
+func FindMtxe(userData []amp4.IBox) *Mtxe {
+	for _, box := range userData {
+		if e, ok := box.(*Mtxe); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// OpenSegmentCipher returns the SegmentCipher of a segment.
+// It returns nil when the segment is not encrypted.
+func OpenSegmentCipher(userData []amp4.IBox, keys []EncryptionKey) (*SegmentCipher, error) {
+	box := FindMtxe(userData)
+	if box == nil {
+		return nil, nil
+	}
+
+	for _, kek := range keys {
+		id := kek.ID()
+		if !bytes.Equal(id[:], box.KeyID[:]) {
+			continue
+		}
+
+		gcm, err := newKeyWrapper(kek)
+		if err != nil {
+			return nil, err
+		}
+
+		dataKey, err := gcm.Open(nil, box.Nonce[:], box.WrappedKey[:], box.KeyID[:])
+		if err != nil {
+			return nil, fmt.Errorf("unable to unwrap data key: %w", err)
+		}
+
+		block, err := aes.NewCipher(dataKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SegmentCipher{block: block}, nil
+	}
+
+	return nil, ErrEncryptionKeyNotFound
+}
+
+func (c *SegmentCipher) xor(iv []byte, dst []byte, src []byte) {
+	// IV (8 bytes), block counter (8 bytes)
+	var ctr [aes.BlockSize]byte
+	copy(ctr[:], iv)
+	cipher.NewCTR(c.block, ctr[:]).XORKeyStream(dst, src)
+}
+
+// EncryptSample encrypts the payload of a sample.
+// The payload is not modified, and a new slice, that contains the IV and the encrypted payload, is returned.
+func (c *SegmentCipher) EncryptSample(payload []byte) []byte {
+	out := make([]byte, SegmentCipherIVSize+len(payload))
+
+	// the data key is unique to the segment, therefore a counter is enough to obtain unique IVs.
+	binary.BigEndian.PutUint64(out, c.counter)
+	c.counter++
+
+	c.xor(out[:SegmentCipherIVSize], out[SegmentCipherIVSize:], payload)
+	return out
+}
+
+// DecryptSample decrypts the payload of a sample.
+func (c *SegmentCipher) DecryptSample(payload []byte) ([]byte, error) {
+	if len(payload) < SegmentCipherIVSize {
+		return nil, fmt.Errorf("encrypted sample is too short")
+	}
+
+	out := make([]byte, len(payload)-SegmentCipherIVSize)
+	c.xor(payload[:SegmentCipherIVSize], out, payload[SegmentCipherIVSize:])
+	return out, nil
+}
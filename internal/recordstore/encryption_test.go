@@ -0,0 +1,88 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	amp4 "github.com/abema/go-mp4"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+func TestLoadEncryptionKeys(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	key1 := strings.Repeat("01", 32)
+	key2 := strings.Repeat("02", 32)
+
+	keyFile := filepath.Join(dir, "keys")
+	err = os.WriteFile(keyFile, []byte("# current key\n"+key2+"\n\n"+key1+"\n"), 0o600)
+	require.NoError(t, err)
+
+	keys, err := LoadEncryptionKeys(&conf.Path{})
+	require.NoError(t, err)
+	require.Nil(t, keys)
+
+	keys, err = LoadEncryptionKeys(&conf.Path{
+		RecordEncryptionKey:     key1,
+		RecordEncryptionKeyFile: keyFile,
+	})
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	require.Equal(t, EncryptionKey(mustParseEncryptionKey(t, key1)), keys[0])
+	require.Equal(t, EncryptionKey(mustParseEncryptionKey(t, key2)), keys[1])
+
+	err = os.WriteFile(keyFile, []byte("0102\n"), 0o600)
+	require.NoError(t, err)
+
+	_, err = LoadEncryptionKeys(&conf.Path{RecordEncryptionKeyFile: keyFile})
+	require.EqualError(t, err, "invalid key at line 1 of "+keyFile+": key must be 32 bytes encoded in hex")
+}
+
+func mustParseEncryptionKey(t *testing.T, s string) EncryptionKey {
+	key, err := ParseEncryptionKey(s)
+	require.NoError(t, err)
+	return key
+}
+
+func TestSegmentCipher(t *testing.T) {
+	oldKey := mustParseEncryptionKey(t, strings.Repeat("01", 32))
+	newKey := mustParseEncryptionKey(t, strings.Repeat("02", 32))
+
+	enc, box, err := NewSegmentCipher(oldKey)
+	require.NoError(t, err)
+	require.Equal(t, oldKey.ID(), box.KeyID)
+
+	payload := []byte{1, 2, 3, 4, 5}
+
+	sample1 := enc.EncryptSample(payload)
+	sample2 := enc.EncryptSample(payload)
+	require.Len(t, sample1, SegmentCipherIVSize+len(payload))
+	require.NotEqual(t, sample1, sample2)
+	require.Equal(t, []byte{1, 2, 3, 4, 5}, payload)
+
+	userData := []amp4.IBox{&Mtxi{}, box}
+
+	// key rotation
+	dec, err := OpenSegmentCipher(userData, []EncryptionKey{newKey, oldKey})
+	require.NoError(t, err)
+
+	for _, sample := range [][]byte{sample1, sample2} {
+		var dpayload []byte
+		dpayload, err = dec.DecryptSample(sample)
+		require.NoError(t, err)
+		require.Equal(t, payload, dpayload)
+	}
+
+	_, err = OpenSegmentCipher(userData, []EncryptionKey{newKey})
+	require.Equal(t, ErrEncryptionKeyNotFound, err)
+
+	dec, err = OpenSegmentCipher([]amp4.IBox{&Mtxi{}}, nil)
+	require.NoError(t, err)
+	require.Nil(t, dec)
+}
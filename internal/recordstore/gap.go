@@ -0,0 +1,195 @@
+package recordstore
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const (
+	stopsFileName = ".mediamtx-stops.jsonl"
+
+	// maximum distance between the end of a segment and the following one.
+	gapTolerance = 1 * time.Second
+
+	// maximum distance between the end of a segment and the stop of the recording.
+	stopTolerance = 5 * time.Second
+)
+
+// stops of different paths may share the same sidecar file.
+var stopsMutex sync.Mutex
+
+// GapReason is the reason of a gap between segments.
+type GapReason string
+
+// gap reasons.
+const (
+	GapReasonSourceOffline     GapReason = "sourceOffline"
+	GapReasonRecordingDisabled GapReason = "recordingDisabled"
+	GapReasonOutsideSchedule   GapReason = "outsideSchedule"
+	GapReasonUnknown           GapReason = "unknown"
+)
+
+// Gap is a time range of a path that has not been recorded.
+type Gap struct {
+	Start  time.Time
+	End    time.Time
+	Reason GapReason
+}
+
+type recordingStop struct {
+	Path   string    `json:"path"`
+	Time   time.Time `json:"time"`
+	Reason GapReason `json:"reason"`
+}
+
+func stopsPath(recordPath string, pathName string) string {
+	return sidecarPath(recordPath, pathName, stopsFileName)
+}
+
+// AddRecordingStop stores the reason why the recording of a path has been stopped.
+func AddRecordingStop(recordPath string, pathName string, reason GapReason) error {
+	byts, err := json.Marshal(&recordingStop{
+		Path:   pathName,
+		Time:   time.Now(),
+		Reason: reason,
+	})
+	if err != nil {
+		return err
+	}
+	byts = append(byts, '\n')
+
+	fpath := stopsPath(recordPath, pathName)
+
+	stopsMutex.Lock()
+	defer stopsMutex.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(fpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(byts)
+	return err
+}
+
+func findRecordingStops(pathConf *conf.Path, pathName string) ([]*recordingStop, error) {
+	stopsMutex.Lock()
+	defer stopsMutex.Unlock()
+
+	return readRecordingStops(stopsPath(pathConf.RecordPath, pathName), pathName)
+}
+
+func readRecordingStops(fpath string, pathName string) ([]*recordingStop, error) {
+	var stops []*recordingStop
+
+	err := ReadJSONLines(fpath, func(s *recordingStop) {
+		if s.Path == pathName {
+			stops = append(stops, s)
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return stops, nil
+}
+
+// PruneRecordingStops removes stops that happened before the first segment of a path,
+// since they can't describe any gap anymore. It is called after segments have been deleted.
+func PruneRecordingStops(pathConf *conf.Path, pathName string) error {
+	var first time.Time
+
+	segments, err := FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		if !errors.Is(err, ErrNoSegmentsFound) {
+			return err
+		}
+	} else {
+		first = segments[0].Start.Add(-stopTolerance)
+	}
+
+	fpath := stopsPath(pathConf.RecordPath, pathName)
+
+	stopsMutex.Lock()
+	defer stopsMutex.Unlock()
+
+	stops, err := readRecordingStops(fpath, pathName)
+	if err != nil {
+		return err
+	}
+
+	var kept []*recordingStop
+
+	if !first.IsZero() {
+		for _, s := range stops {
+			if !s.Time.Before(first) {
+				kept = append(kept, s)
+			}
+		}
+	}
+
+	if len(kept) == len(stops) {
+		return nil
+	}
+
+	if len(kept) == 0 {
+		err = os.Remove(fpath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return WriteJSONLines(fpath, kept)
+}
+
+// FindGaps returns gaps between the given segments, that must be sorted by start.
+// Segments with unknown duration are not taken into account.
+func FindGaps(pathConf *conf.Path, pathName string, segments []*Segment) ([]*Gap, error) {
+	stops, err := findRecordingStops(pathConf, pathName)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps := []*Gap{}
+
+	for i := 0; i < len(segments)-1; i++ {
+		seg := segments[i]
+		if seg.Duration == 0 {
+			continue
+		}
+
+		gap := &Gap{
+			Start:  seg.Start.Add(seg.Duration),
+			End:    segments[i+1].Start,
+			Reason: GapReasonUnknown,
+		}
+
+		if gap.End.Sub(gap.Start) <= gapTolerance {
+			continue
+		}
+
+		// use the last stop that happened between the end of the segment and the start of the following one
+		for _, s := range stops {
+			if !s.Time.Before(gap.Start.Add(-stopTolerance)) && s.Time.Before(gap.End) {
+				gap.Reason = s.Reason
+			}
+		}
+
+		gaps = append(gaps, gap)
+	}
+
+	return gaps, nil
+}
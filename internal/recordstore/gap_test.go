@@ -0,0 +1,78 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneRecordingStops(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:         "path1",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	now := time.Now()
+
+	err = AddRecordingStop(pathConf.RecordPath, "path1", GapReasonSourceOffline)
+	require.NoError(t, err)
+
+	seg := filepath.Join(dir, "path1", "segment.mp4")
+	err = IndexSegmentCreate(pathConf.RecordPath, pathConf.RecordFormat, "path1", seg, now.Add(time.Minute))
+	require.NoError(t, err)
+
+	err = os.WriteFile(seg, []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	// stops are stored next to the recordings of the path
+	fpath := filepath.Join(dir, "path1", stopsFileName)
+	_, err = os.Stat(fpath)
+	require.NoError(t, err)
+
+	// the stop happened before the first segment
+	err = PruneRecordingStops(pathConf, "path1")
+	require.NoError(t, err)
+
+	_, err = os.Stat(fpath)
+	require.True(t, os.IsNotExist(err))
+
+	seg0 := filepath.Join(dir, "path1", "segment0.mp4")
+	err = IndexSegmentCreate(pathConf.RecordPath, pathConf.RecordFormat, "path1", seg0, now.Add(-time.Minute))
+	require.NoError(t, err)
+
+	err = os.WriteFile(seg0, []byte{1}, 0o644)
+	require.NoError(t, err)
+
+	err = AddRecordingStop(pathConf.RecordPath, "path1", GapReasonRecordingDisabled)
+	require.NoError(t, err)
+
+	err = PruneRecordingStops(pathConf, "path1")
+	require.NoError(t, err)
+
+	stops, err := findRecordingStops(pathConf, "path1")
+	require.NoError(t, err)
+	require.Len(t, stops, 1)
+	require.Equal(t, GapReasonRecordingDisabled, stops[0].Reason)
+
+	// no segments are left
+	err = RemoveSegment(pathConf, "path1", seg0)
+	require.NoError(t, err)
+
+	err = RemoveSegment(pathConf, "path1", seg)
+	require.NoError(t, err)
+
+	err = PruneRecordingStops(pathConf, "path1")
+	require.NoError(t, err)
+
+	_, err = os.Stat(fpath)
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,730 @@
+package recordstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const (
+	indexFilePrefix = ".mediamtx-index-"
+	indexFileSuffix = ".jsonl"
+
+	// the log is compacted when it contains more than this amount of superseded operations.
+	indexMaxStaleOps = 10000
+)
+
+type indexOp string
+
+const (
+	indexOpAdd        indexOp = "add"
+	indexOpComplete   indexOp = "complete"
+	indexOpRemove     indexOp = "remove"
+	indexOpUpload     indexOp = "upload"
+	indexOpDownsample indexOp = "downsample"
+)
+
+// indexRecord is a line of the index log.
+type indexRecord struct {
+	Op       indexOp       `json:"op"`
+	Path     string        `json:"path"`
+	File     string        `json:"file"`
+	Start    time.Time     `json:"start,omitzero"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Size     int64         `json:"size,omitempty"`
+	Codecs   []string      `json:"codecs,omitempty"`
+	Complete bool          `json:"complete,omitempty"`
+	Sparse   bool          `json:"sparse,omitempty"`
+	Key      string        `json:"key,omitempty"`
+}
+
+type indexEntry struct {
+	path     string
+	file     string
+	start    time.Time
+	duration time.Duration
+	size     int64
+	codecs   []string
+	complete bool
+	sparse   bool
+	remote   string
+
+	// number of records of the log that describe the entry.
+	records int
+}
+
+// toRecord returns a single record that describes the entry.
+func (e *indexEntry) toRecord() *indexRecord {
+	return &indexRecord{
+		Op:       indexOpAdd,
+		Path:     e.path,
+		File:     e.file,
+		Start:    e.start,
+		Duration: e.duration,
+		Size:     e.size,
+		Codecs:   e.codecs,
+		Complete: e.complete,
+		Sparse:   e.sparse,
+		Key:      e.remote,
+	}
+}
+
+// index is an on-disk index of the segments that share the same record path.
+// Operations are appended to a log, that is replayed into memory when the index is opened.
+type index struct {
+	recordPath string // absolute, with extension
+	format     conf.RecordFormat
+	fpath      string
+	dir        string
+
+	mutex    sync.RWMutex
+	byFile   map[string]*indexEntry
+	byPath   map[string][]*indexEntry // sorted by start
+	staleOps int                      // records of the log that describe removed entries
+
+	// state of the file system the last time the index was synchronized with it,
+	// used to detect changes that were not performed through the index.
+	logModTime  time.Time
+	logSize     int64
+	dirModTimes map[string]time.Time
+}
+
+var (
+	indexesMutex sync.Mutex
+	indexes      = make(map[string]*index)
+)
+
+func indexRecordPath(recordPath string, format conf.RecordFormat) string {
+	recordPath = PathAddExtension(recordPath, format)
+
+	// we have to convert to absolute paths
+	// otherwise, recordPath and fpath inside Walk() won't have common elements
+	recordPath, _ = filepath.Abs(recordPath)
+
+	return recordPath
+}
+
+// IndexPath returns the path of the index of segments that share the given record path.
+func IndexPath(recordPath string, format conf.RecordFormat) string {
+	recordPath = indexRecordPath(recordPath, format)
+	h := sha256.Sum256([]byte(recordPath))
+	return filepath.Join(CommonPath(recordPath), indexFilePrefix+hex.EncodeToString(h[:4])+indexFileSuffix)
+}
+
+// getIndex returns the index of segments that share the given record path.
+// The index is loaded from disk, or rebuilt from the file system when missing.
+func getIndex(recordPath string, format conf.RecordFormat) (*index, error) {
+	fpath := IndexPath(recordPath, format)
+
+	indexesMutex.Lock()
+	defer indexesMutex.Unlock()
+
+	if idx, ok := indexes[fpath]; ok {
+		err := idx.refresh()
+		if err != nil {
+			return nil, err
+		}
+		return idx, nil
+	}
+
+	idx := &index{
+		recordPath: indexRecordPath(recordPath, format),
+		format:     format,
+		fpath:      fpath,
+		dir:        filepath.Dir(fpath),
+	}
+
+	err := idx.open()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes[fpath] = idx
+
+	return idx, nil
+}
+
+func (idx *index) open() error {
+	idx.byFile = make(map[string]*indexEntry)
+	idx.byPath = make(map[string][]*indexEntry)
+
+	err := ReadJSONLines(idx.fpath, idx.apply)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		err = idx.rebuild()
+		if err != nil {
+			return err
+		}
+
+		idx.stamp()
+		return nil
+	}
+
+	if idx.staleOps > indexMaxStaleOps {
+		err = idx.compact()
+		if err != nil {
+			return err
+		}
+	}
+
+	idx.stamp()
+	return nil
+}
+
+// rebuild fills the index by walking the file system.
+// Durations are read from fMP4 headers, while codecs are not available and are left empty.
+func (idx *index) rebuild() error {
+	idx.walk(func(file string, rec *indexRecord) {
+		idx.apply(rec)
+	})
+
+	// do not create the index until there's something to store
+	if len(idx.byFile) == 0 {
+		return nil
+	}
+
+	return idx.compact()
+}
+
+// walk calls cb with an add record for each segment found on the file system.
+func (idx *index) walk(cb func(file string, rec *indexRecord)) {
+	commonPath := CommonPath(idx.recordPath)
+
+	filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			var pa Path
+			if ok := pa.Decode(idx.recordPath, fpath); ok {
+				var size int64
+				if fi, err2 := info.Info(); err2 == nil {
+					size = fi.Size()
+				}
+
+				var duration time.Duration
+				switch idx.format {
+				case conf.RecordFormatFMP4:
+					duration, _ = segmentFMP4ReadDuration(fpath)
+
+				case conf.RecordFormatMKV:
+					duration, _ = segmentMKVReadDuration(fpath)
+				}
+
+				file, _ := filepath.Rel(idx.dir, fpath)
+				file = filepath.ToSlash(file)
+
+				cb(file, &indexRecord{
+					Op:       indexOpAdd,
+					Path:     pa.Path,
+					File:     file,
+					Start:    pa.Start,
+					Duration: duration,
+					Size:     size,
+				})
+			}
+		}
+
+		return nil
+	})
+}
+
+// stamp saves the current state of the log and of the directories that contain segments.
+// Directories are checked instead of segments since they are less and their modification time
+// changes when a segment is added or removed.
+func (idx *index) stamp() {
+	idx.logModTime, idx.logSize = time.Time{}, 0
+	if fi, err := os.Stat(idx.fpath); err == nil {
+		idx.logModTime, idx.logSize = fi.ModTime(), fi.Size()
+	}
+
+	idx.dirModTimes = make(map[string]time.Time)
+	idx.dirModTimes[idx.dir] = time.Time{}
+
+	for file := range idx.byFile {
+		for dir := filepath.Dir(filepath.FromSlash(file)); dir != "." && dir != ".."; dir = filepath.Dir(dir) {
+			idx.dirModTimes[filepath.Join(idx.dir, dir)] = time.Time{}
+		}
+	}
+
+	for dir := range idx.dirModTimes {
+		if fi, err := os.Stat(dir); err == nil {
+			idx.dirModTimes[dir] = fi.ModTime()
+		}
+	}
+}
+
+// refresh synchronizes the index with changes that have not been performed through it.
+// The log is replayed when it has been modified by another process,
+// while the file system is walked when segments have been added or removed by someone else.
+func (idx *index) refresh() error {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	var logModTime time.Time
+	var logSize int64
+	if fi, err := os.Stat(idx.fpath); err == nil {
+		logModTime, logSize = fi.ModTime(), fi.Size()
+	}
+
+	if !logModTime.Equal(idx.logModTime) || logSize != idx.logSize {
+		return idx.open()
+	}
+
+	for dir, modTime := range idx.dirModTimes {
+		var cur time.Time
+		if fi, err := os.Stat(dir); err == nil {
+			cur = fi.ModTime()
+		}
+
+		if !cur.Equal(modTime) {
+			return idx.reconcile()
+		}
+	}
+
+	return nil
+}
+
+// reconcile adds segments that are on the file system but not in the index
+// and removes local segments that are in the index but not on the file system.
+func (idx *index) reconcile() error {
+	var recs []*indexRecord
+	found := make(map[string]struct{})
+
+	idx.walk(func(file string, rec *indexRecord) {
+		found[file] = struct{}{}
+		if _, ok := idx.byFile[file]; !ok {
+			recs = append(recs, rec)
+		}
+	})
+
+	for file, e := range idx.byFile {
+		if _, ok := found[file]; ok || e.remote != "" {
+			continue
+		}
+
+		_, err := os.Stat(filepath.Join(idx.dir, filepath.FromSlash(file)))
+		if os.IsNotExist(err) {
+			recs = append(recs, &indexRecord{
+				Op:   indexOpRemove,
+				Path: e.path,
+				File: file,
+			})
+		}
+	}
+
+	if len(recs) != 0 {
+		err := idx.writeLocked(recs...)
+		if err != nil {
+			return err
+		}
+	}
+
+	idx.stamp()
+	return nil
+}
+
+// compact rewrites the log with the current state of the index, with a single record for each entry.
+func (idx *index) compact() error {
+	err := os.MkdirAll(idx.dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	var recs []*indexRecord
+	for _, entries := range idx.byPath {
+		for _, e := range entries {
+			recs = append(recs, e.toRecord())
+		}
+	}
+
+	err = WriteJSONLines(idx.fpath, recs)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.byFile {
+		e.records = 1
+	}
+	idx.staleOps = 0
+
+	return nil
+}
+
+func (idx *index) apply(rec *indexRecord) {
+	switch rec.Op {
+	case indexOpAdd:
+		if _, ok := idx.byFile[rec.File]; ok {
+			idx.staleOps++
+			return
+		}
+
+		e := &indexEntry{
+			path:     rec.Path,
+			file:     rec.File,
+			start:    rec.Start.Local(),
+			duration: rec.Duration,
+			size:     rec.Size,
+			codecs:   rec.Codecs,
+			complete: rec.Complete,
+			sparse:   rec.Sparse,
+			remote:   rec.Key,
+			records:  1,
+		}
+		idx.byFile[rec.File] = e
+
+		entries := idx.byPath[rec.Path]
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].start.After(e.start)
+		})
+		entries = append(entries, nil)
+		copy(entries[i+1:], entries[i:])
+		entries[i] = e
+		idx.byPath[rec.Path] = entries
+
+	case indexOpComplete:
+		e, ok := idx.byFile[rec.File]
+		if !ok {
+			idx.staleOps++
+			return
+		}
+
+		e.duration = rec.Duration
+		e.size = rec.Size
+		e.codecs = rec.Codecs
+		e.complete = true
+		e.records++
+
+	case indexOpUpload:
+		e, ok := idx.byFile[rec.File]
+		if !ok {
+			idx.staleOps++
+			return
+		}
+
+		e.remote = rec.Key
+		e.records++
+
+	case indexOpDownsample:
+		e, ok := idx.byFile[rec.File]
+		if !ok {
+			idx.staleOps++
+			return
+		}
+
+		e.duration = rec.Duration
+		e.size = rec.Size
+		e.complete = true
+		e.sparse = true
+		e.records++
+
+	case indexOpRemove:
+		e, ok := idx.byFile[rec.File]
+		if !ok {
+			idx.staleOps++
+			return
+		}
+
+		// the removal record and all records of the entry are not needed anymore
+		idx.staleOps += e.records + 1
+
+		delete(idx.byFile, rec.File)
+
+		entries := idx.byPath[e.path]
+		for i, e2 := range entries {
+			if e2 == e {
+				entries = append(entries[:i], entries[i+1:]...)
+				break
+			}
+		}
+
+		if len(entries) == 0 {
+			delete(idx.byPath, e.path)
+		} else {
+			idx.byPath[e.path] = entries
+		}
+	}
+}
+
+func (idx *index) write(rec *indexRecord) error {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	return idx.writeLocked(rec)
+}
+
+func (idx *index) writeLocked(recs ...*indexRecord) error {
+	var byts []byte
+
+	for _, rec := range recs {
+		idx.apply(rec)
+
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		byts = append(byts, line...)
+		byts = append(byts, '\n')
+	}
+
+	err := os.MkdirAll(idx.dir, 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(idx.fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(byts)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if idx.staleOps > indexMaxStaleOps {
+		err = idx.compact()
+		if err != nil {
+			return err
+		}
+	}
+
+	idx.stamp()
+	return nil
+}
+
+func (idx *index) relFile(fpath string) string {
+	fpath, _ = filepath.Abs(fpath)
+	file, _ := filepath.Rel(idx.dir, fpath)
+	return filepath.ToSlash(file)
+}
+
+// findSegments returns segments of a path.
+// Local segments that do not exist anymore are removed from the index.
+func (idx *index) findSegments(pathName string, start *time.Time, end *time.Time) ([]*Segment, error) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+
+	for {
+		entries := idx.selectEntries(pathName, start, end)
+
+		var recs []*indexRecord
+
+		for _, e := range entries {
+			if e.remote == "" {
+				_, err := os.Stat(filepath.Join(idx.dir, filepath.FromSlash(e.file)))
+				if os.IsNotExist(err) {
+					recs = append(recs, &indexRecord{
+						Op:   indexOpRemove,
+						Path: e.path,
+						File: e.file,
+					})
+				}
+			}
+		}
+
+		if len(recs) == 0 {
+			ret := make([]*Segment, len(entries))
+
+			for i, e := range entries {
+				ret[i] = idx.toSegment(e)
+			}
+
+			return ret, nil
+		}
+
+		// selection depends on the segments in the index, therefore it has to be repeated
+		err := idx.writeLocked(recs...)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (idx *index) selectEntries(pathName string, start *time.Time, end *time.Time) []*indexEntry {
+	entries := idx.byPath[pathName]
+
+	// gather all segments that start before the end of the playback
+	if end != nil {
+		n := sort.Search(len(entries), func(i int) bool {
+			return entries[i].start.After(*end)
+		})
+		entries = entries[:n]
+	}
+
+	// find the segment that may contain the start of the playback and remove all previous ones
+	if start != nil && len(entries) != 0 && !start.Before(entries[0].start) {
+		i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].start.After(*start)
+		})
+		entries = entries[i-1:]
+	}
+
+	return entries
+}
+
+func (idx *index) toSegment(e *indexEntry) *Segment {
+	return &Segment{
+		Fpath:    filepath.Join(idx.dir, filepath.FromSlash(e.file)),
+		Start:    e.start,
+		Duration: e.duration,
+		Size:     e.size,
+		Codecs:   e.codecs,
+		Sparse:   e.sparse,
+		Remote:   e.remote,
+	}
+}
+
+func (idx *index) pathNames() []string {
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	ret := make([]string, 0, len(idx.byPath))
+	for name := range idx.byPath {
+		ret = append(ret, name)
+	}
+
+	return ret
+}
+
+// IndexSegmentCreate adds a segment to the index.
+func IndexSegmentCreate(
+	recordPath string,
+	format conf.RecordFormat,
+	pathName string,
+	fpath string,
+	start time.Time,
+) error {
+	idx, err := getIndex(recordPath, format)
+	if err != nil {
+		return err
+	}
+
+	return idx.write(&indexRecord{
+		Op:    indexOpAdd,
+		Path:  pathName,
+		File:  idx.relFile(fpath),
+		Start: start,
+	})
+}
+
+// IndexSegmentComplete stores duration, size and codecs of a segment into the index.
+func IndexSegmentComplete(
+	recordPath string,
+	format conf.RecordFormat,
+	pathName string,
+	fpath string,
+	duration time.Duration,
+	size int64,
+	codecs []string,
+) error {
+	idx, err := getIndex(recordPath, format)
+	if err != nil {
+		return err
+	}
+
+	return idx.write(&indexRecord{
+		Op:       indexOpComplete,
+		Path:     pathName,
+		File:     idx.relFile(fpath),
+		Duration: duration,
+		Size:     size,
+		Codecs:   codecs,
+	})
+}
+
+// IndexSegmentRemove removes a segment from the index.
+func IndexSegmentRemove(pathConf *conf.Path, pathName string, fpath string) error {
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	if err != nil {
+		return err
+	}
+
+	return idx.write(&indexRecord{
+		Op:   indexOpRemove,
+		Path: pathName,
+		File: idx.relFile(fpath),
+	})
+}
+
+// SegmentObjectKey returns the key of a segment inside an object storage,
+// that is the path of the segment relative to the common path of recordings.
+func SegmentObjectKey(pathConf *conf.Path, fpath string) (string, error) {
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	if err != nil {
+		return "", err
+	}
+
+	return idx.relFile(fpath), nil
+}
+
+// IndexSegmentUpload marks a segment as uploaded to an object storage with the given key.
+func IndexSegmentUpload(pathConf *conf.Path, pathName string, fpath string, key string) error {
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	if err != nil {
+		return err
+	}
+
+	return idx.write(&indexRecord{
+		Op:   indexOpUpload,
+		Path: pathName,
+		File: idx.relFile(fpath),
+		Key:  key,
+	})
+}
+
+// IndexSegmentDownsample marks a segment as downsampled and stores its new duration and size.
+func IndexSegmentDownsample(
+	pathConf *conf.Path,
+	pathName string,
+	fpath string,
+	duration time.Duration,
+	size int64,
+) error {
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	if err != nil {
+		return err
+	}
+
+	return idx.write(&indexRecord{
+		Op:       indexOpDownsample,
+		Path:     pathName,
+		File:     idx.relFile(fpath),
+		Duration: duration,
+		Size:     size,
+	})
+}
+
+// RemoveLocalSegment deletes the local copy of a segment that has been uploaded to an object storage.
+// The segment is kept in the index and can still be fetched from the object storage.
+func RemoveLocalSegment(seg *Segment) error {
+	err := os.Remove(seg.Fpath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// RemoveSegment deletes a segment from disk and from the index.
+// Segments that have already been deleted from disk are removed from the index anyway.
+func RemoveSegment(pathConf *conf.Path, pathName string, fpath string) error {
+	err := os.Remove(fpath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return IndexSegmentRemove(pathConf, pathName, fpath)
+}
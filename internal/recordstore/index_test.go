@@ -0,0 +1,171 @@
+package recordstore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "path1"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"), []byte{1, 2}, 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:         "path1",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	// index is rebuilt from the file system
+	segments, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{{
+		Fpath: filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
+		Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+		Size:  2,
+	}}, segments)
+
+	_, err = os.Stat(IndexPath(pathConf.RecordPath, pathConf.RecordFormat))
+	require.NoError(t, err)
+
+	seg2 := filepath.Join(dir, "path1", "2015-05-19_22-16-25-000427.mp4")
+	start2 := time.Date(2015, 5, 19, 22, 16, 25, 427000, time.Local)
+
+	err = IndexSegmentCreate(pathConf.RecordPath, pathConf.RecordFormat, "path1", seg2, start2)
+	require.NoError(t, err)
+
+	err = IndexSegmentComplete(pathConf.RecordPath, pathConf.RecordFormat, "path1", seg2,
+		60*time.Second, 1234, []string{"H264"})
+	require.NoError(t, err)
+
+	err = IndexSegmentRemove(pathConf, "path1", filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"))
+	require.NoError(t, err)
+
+	err = IndexSegmentUpload(pathConf, "path1", seg2, "path1/2015-05-19_22-16-25-000427.mp4")
+	require.NoError(t, err)
+
+	// index is reloaded from disk
+	indexesMutex.Lock()
+	delete(indexes, IndexPath(pathConf.RecordPath, pathConf.RecordFormat))
+	indexesMutex.Unlock()
+
+	segments, err = FindSegments(pathConf, "path1", ptrOf(start2.Add(10*time.Second)), nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{{
+		Fpath:    seg2,
+		Start:    start2,
+		Duration: 60 * time.Second,
+		Size:     1234,
+		Codecs:   []string{"H264"},
+		Remote:   "path1/2015-05-19_22-16-25-000427.mp4",
+	}}, segments)
+
+	_, err = FindSegments(pathConf, "path1", nil, ptrOf(start2.Add(-time.Second)))
+	require.Equal(t, ErrNoSegmentsFound, err)
+
+	// only records of the removed segment are stale
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	require.NoError(t, err)
+	require.Equal(t, 2, idx.staleOps)
+
+	// compaction leaves a single record for each segment
+	err = idx.compact()
+	require.NoError(t, err)
+
+	byts, err := os.ReadFile(IndexPath(pathConf.RecordPath, pathConf.RecordFormat))
+	require.NoError(t, err)
+	require.Equal(t, 1, bytes.Count(byts, []byte("\n")))
+
+	indexesMutex.Lock()
+	delete(indexes, IndexPath(pathConf.RecordPath, pathConf.RecordFormat))
+	indexesMutex.Unlock()
+
+	segments2, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, segments, segments2)
+
+	// segments already deleted from disk are removed from the index without errors
+	err = RemoveSegment(pathConf, "path1", seg2)
+	require.NoError(t, err)
+
+	_, err = FindSegments(pathConf, "path1", nil, nil)
+	require.Equal(t, ErrNoSegmentsFound, err)
+}
+
+func TestIndexRefresh(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "path1"), 0o755)
+	require.NoError(t, err)
+
+	seg1 := filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4")
+	err = os.WriteFile(seg1, []byte{1, 2}, 0o644)
+	require.NoError(t, err)
+
+	pathConf := &conf.Path{
+		Name:         "path1",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	segments, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	// segments added by someone else are picked up
+	seg2 := filepath.Join(dir, "path1", "2015-05-19_22-16-25-000427.mp4")
+	err = os.WriteFile(seg2, []byte{1, 2, 3}, 0o644)
+	require.NoError(t, err)
+
+	segments, err = FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []*Segment{
+		{
+			Fpath: seg1,
+			Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+			Size:  2,
+		},
+		{
+			Fpath: seg2,
+			Start: time.Date(2015, 5, 19, 22, 16, 25, 427000, time.Local),
+			Size:  3,
+		},
+	}, segments)
+
+	// segments removed by someone else are dropped when they are read
+	err = os.Remove(seg1)
+	require.NoError(t, err)
+
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	require.NoError(t, err)
+
+	segments, err = idx.findSegments("path1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	require.Equal(t, seg2, segments[0].Fpath)
+
+	// the log is replayed when it is modified by another process
+	err = os.WriteFile(IndexPath(pathConf.RecordPath, pathConf.RecordFormat),
+		[]byte(`{"op":"add","path":"path1","file":"path1/2015-05-19_22-16-25-000427.mp4",`+
+			`"start":"2015-05-19T22:16:25.000427Z","duration":60000000000,"size":3,"complete":true}`+"\n"), 0o644)
+	require.NoError(t, err)
+
+	segments, err = FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	require.Equal(t, 60*time.Second, segments[0].Duration)
+}
@@ -0,0 +1,164 @@
+package recordstore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const locksFileName = ".mediamtx-locks.json"
+
+// ErrLockNotFound is returned when a lock is not found.
+var ErrLockNotFound = errors.New("lock not found")
+
+// ErrSegmentLocked is returned when trying to delete a locked segment.
+var ErrSegmentLocked = errors.New("segment is locked")
+
+// locks of different paths may share the same sidecar file.
+var locksMutex sync.Mutex
+
+// Lock is a time range of a path that must be excluded from deletion.
+type Lock struct {
+	ID      uuid.UUID `json:"id"`
+	Path    string    `json:"path"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+}
+
+// Overlaps checks whether the lock overlaps with the given time range.
+// A zero end means that the range is open.
+func (l *Lock) Overlaps(start time.Time, end time.Time) bool {
+	return (end.IsZero() || l.Start.Before(end)) && l.End.After(start)
+}
+
+// LocksPath returns the path of the sidecar file that contains locks of a path.
+func LocksPath(pathConf *conf.Path, pathName string) string {
+	return sidecarPath(pathConf.RecordPath, pathName, locksFileName)
+}
+
+func readLocksFile(fpath string) ([]*Lock, error) {
+	var locks []*Lock
+	err := readSidecarFile(fpath, &locks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid locks file %s: %w", fpath, err)
+	}
+	return locks, nil
+}
+
+func writeLocksFile(fpath string, locks []*Lock) error {
+	return writeSidecarFile(fpath, locks, len(locks) == 0)
+}
+
+// FindLocks returns all locks of a path.
+func FindLocks(pathConf *conf.Path, pathName string) ([]*Lock, error) {
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+
+	all, err := readLocksFile(LocksPath(pathConf, pathName))
+	if err != nil {
+		return nil, err
+	}
+
+	locks := []*Lock{}
+	for _, l := range all {
+		if l.Path == pathName {
+			locks = append(locks, l)
+		}
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Start.Before(locks[j].Start)
+	})
+
+	return locks, nil
+}
+
+// FindRetentionLocks returns locks of a path together with time ranges of its bookmarks,
+// that are both excluded from deletion.
+func FindRetentionLocks(pathConf *conf.Path, pathName string) ([]*Lock, error) {
+	locks, err := FindLocks(pathConf, pathName)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks, err := FindBookmarks(pathConf, pathName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bookmarks {
+		locks = append(locks, b.lock())
+	}
+
+	return locks, nil
+}
+
+// AddLock adds a lock to a path.
+func AddLock(pathConf *conf.Path, l *Lock) error {
+	if !l.End.After(l.Start) {
+		return fmt.Errorf("end must be after start")
+	}
+
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+
+	fpath := LocksPath(pathConf, l.Path)
+
+	locks, err := readLocksFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	l.ID = uuid.New()
+	l.Created = time.Now()
+	locks = append(locks, l)
+
+	return writeLocksFile(fpath, locks)
+}
+
+// RemoveLock removes a lock from a path.
+func RemoveLock(pathConf *conf.Path, pathName string, id uuid.UUID) error {
+	locksMutex.Lock()
+	defer locksMutex.Unlock()
+
+	fpath := LocksPath(pathConf, pathName)
+
+	locks, err := readLocksFile(fpath)
+	if err != nil {
+		return err
+	}
+
+	for i, l := range locks {
+		if l.Path == pathName && l.ID == id {
+			locks = append(locks[:i], locks[i+1:]...)
+			return writeLocksFile(fpath, locks)
+		}
+	}
+
+	return ErrLockNotFound
+}
+
+// SegmentIsLocked checks whether a segment is covered by at least one lock.
+// The end of the segment is the start of the following segment, if any.
+func SegmentIsLocked(locks []*Lock, seg *Segment, next *Segment) bool {
+	var end time.Time
+	if next != nil {
+		end = next.Start
+	}
+
+	for _, l := range locks {
+		if l.Overlaps(seg.Start, end) {
+			return true
+		}
+	}
+
+	return false
+}
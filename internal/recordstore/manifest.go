@@ -0,0 +1,323 @@
+package recordstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+const manifestFileName = ".mediamtx-manifest.jsonl"
+
+// hash of the first entry of every chain.
+var manifestGenesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// manifests of different paths may share the same sidecar file.
+var manifestMutex sync.Mutex
+
+// ManifestEntry is an entry of the hash chain of a path.
+type ManifestEntry struct {
+	Path      string    `json:"path"`
+	File      string    `json:"file"`
+	Start     time.Time `json:"start"`
+	Size      int64     `json:"size"`
+	Hash      string    `json:"hash"`
+	PrevChain string    `json:"prevChain"`
+	Chain     string    `json:"chain"`
+}
+
+func chainHash(prevChain string, hash string) string {
+	h := sha256.New()
+	h.Write([]byte(prevChain))
+	h.Write([]byte(hash))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FileHash computes the SHA-256 hash of a file.
+func FileHash(fpath string) (string, int64, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// ManifestPath returns the path of the sidecar file that contains the hash chain of a path.
+func ManifestPath(recordPath string, pathName string) string {
+	return sidecarPath(recordPath, pathName, manifestFileName)
+}
+
+func readManifest(fpath string, pathName string) ([]*ManifestEntry, error) {
+	var entries []*ManifestEntry
+
+	err := ReadJSONLines(fpath, func(e *ManifestEntry) {
+		if e.Path == pathName {
+			entries = append(entries, e)
+		}
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// FindManifestEntries returns all entries of the hash chain of a path.
+func FindManifestEntries(pathConf *conf.Path, pathName string) ([]*ManifestEntry, error) {
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+
+	return readManifest(ManifestPath(pathConf.RecordPath, pathName), pathName)
+}
+
+// ManifestWriter appends segments to the hash chain of a path.
+type ManifestWriter struct {
+	RecordPath string
+	PathName   string
+
+	fpath     string
+	lastChain string
+}
+
+// Initialize initializes ManifestWriter.
+func (w *ManifestWriter) Initialize() error {
+	w.fpath = ManifestPath(w.RecordPath, w.PathName)
+
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+
+	entries, err := readManifest(w.fpath, w.PathName)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) != 0 {
+		w.lastChain = entries[len(entries)-1].Chain
+	} else {
+		w.lastChain = manifestGenesisHash
+	}
+
+	return nil
+}
+
+// Append computes the hash of a segment and appends it to the hash chain.
+func (w *ManifestWriter) Append(segmentPath string, start time.Time) (*ManifestEntry, error) {
+	segmentPath, err := filepath.Abs(segmentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, size, err := FileHash(segmentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := filepath.Rel(filepath.Dir(w.fpath), segmentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &ManifestEntry{
+		Path:      w.PathName,
+		File:      filepath.ToSlash(file),
+		Start:     start,
+		Size:      size,
+		Hash:      hash,
+		PrevChain: w.lastChain,
+		Chain:     chainHash(w.lastChain, hash),
+	}
+
+	byts, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	byts = append(byts, '\n')
+
+	manifestMutex.Lock()
+	defer manifestMutex.Unlock()
+
+	err = os.MkdirAll(filepath.Dir(w.fpath), 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(w.fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	_, err = f.Write(byts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return nil, err
+	}
+
+	w.lastChain = e.Chain
+
+	return e, nil
+}
+
+// VerificationStatus is the result of the verification of a segment.
+type VerificationStatus string
+
+// verification statuses.
+const (
+	VerificationStatusOK           VerificationStatus = "ok"
+	VerificationStatusModified     VerificationStatus = "modified"
+	VerificationStatusMissing      VerificationStatus = "missing"
+	VerificationStatusBrokenChain  VerificationStatus = "brokenChain"
+	VerificationStatusUnregistered VerificationStatus = "unregistered"
+)
+
+// VerificationResult is the result of the verification of a segment.
+type VerificationResult struct {
+	Start  time.Time
+	Status VerificationStatus
+	Entry  *ManifestEntry
+}
+
+// Verify checks the integrity of the segments of a path in a time range.
+// Segments are compared with the hash chain, that is verified too.
+func Verify(
+	pathConf *conf.Path,
+	pathName string,
+	start *time.Time,
+	end *time.Time,
+) ([]*VerificationResult, error) {
+	entries, err := FindManifestEntries(pathConf, pathName)
+	if err != nil {
+		return nil, err
+	}
+
+	segments, err := FindSegments(pathConf, pathName, start, end)
+	if err != nil && len(entries) == 0 {
+		return nil, err
+	}
+
+	manifestDir := filepath.Dir(ManifestPath(pathConf.RecordPath, pathName))
+	inRange := func(t time.Time) bool {
+		return (start == nil || !t.Before(*start)) && (end == nil || !t.After(*end))
+	}
+
+	// segments on disk that overlap the range
+	found := make(map[string]struct{})
+	for _, seg := range segments {
+		found[seg.Fpath] = struct{}{}
+	}
+
+	var results []*VerificationResult
+	registered := make(map[string]struct{})
+	var prevChain string
+
+	for _, e := range entries {
+		fpath := filepath.Join(manifestDir, filepath.FromSlash(e.File))
+		registered[fpath] = struct{}{}
+
+		// the chain is verified from its first available entry, even outside the range.
+		chainOK := (prevChain == "" || e.PrevChain == prevChain) && e.Chain == chainHash(e.PrevChain, e.Hash)
+		prevChain = e.Chain
+
+		if _, ok := found[fpath]; !ok && !inRange(e.Start) {
+			continue
+		}
+
+		res := &VerificationResult{
+			Start: e.Start,
+			Entry: e,
+		}
+
+		switch {
+		case !chainOK:
+			res.Status = VerificationStatusBrokenChain
+
+		default:
+			hash, _, err2 := FileHash(fpath)
+			switch {
+			case os.IsNotExist(err2):
+				res.Status = VerificationStatusMissing
+
+			case err2 != nil:
+				return nil, err2
+
+			case hash != e.Hash:
+				res.Status = VerificationStatusModified
+
+			default:
+				res.Status = VerificationStatusOK
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	for _, seg := range segments {
+		if _, ok := registered[seg.Fpath]; !ok {
+			results = append(results, &VerificationResult{
+				Start:  seg.Start,
+				Status: VerificationStatusUnregistered,
+			})
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, ErrNoSegmentsFound
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Start.Before(results[j].Start)
+	})
+
+	return results, nil
+}
+
+// ManifestEntriesOfSegments returns the hash chain entries of the given segments.
+func ManifestEntriesOfSegments(
+	pathConf *conf.Path,
+	pathName string,
+	segments []*Segment,
+) ([]*ManifestEntry, error) {
+	entries, err := FindManifestEntries(pathConf, pathName)
+	if err != nil {
+		return nil, err
+	}
+
+	manifestDir := filepath.Dir(ManifestPath(pathConf.RecordPath, pathName))
+	byPath := make(map[string]*ManifestEntry)
+
+	for _, e := range entries {
+		byPath[filepath.Join(manifestDir, filepath.FromSlash(e.File))] = e
+	}
+
+	ret := make([]*ManifestEntry, 0, len(segments))
+
+	for _, seg := range segments {
+		e, ok := byPath[seg.Fpath]
+		if !ok {
+			return nil, fmt.Errorf("segment %s is not part of the hash chain", seg.Fpath)
+		}
+		ret = append(ret, e)
+	}
+
+	return ret, nil
+}
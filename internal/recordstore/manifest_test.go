@@ -0,0 +1,79 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestVerify(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:         "mypath",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	w := &ManifestWriter{
+		RecordPath: pathConf.RecordPath,
+		PathName:   "mypath",
+	}
+	err = w.Initialize()
+	require.NoError(t, err)
+
+	for i, name := range []string{
+		"2008-11-07_11-22-00-000000.mp4",
+		"2008-11-07_11-23-00-000000.mp4",
+		"2008-11-07_11-24-00-000000.mp4",
+	} {
+		fpath := filepath.Join(dir, "mypath", name)
+
+		err = os.WriteFile(fpath, []byte{byte(i)}, 0o644)
+		require.NoError(t, err)
+
+		_, err = w.Append(fpath, time.Date(2008, 11, 7, 11, 22+i, 0, 0, time.Local))
+		require.NoError(t, err)
+	}
+
+	// segment created outside of the recorder
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-11-07_11-25-00-000000.mp4"), []byte{3}, 0o644)
+	require.NoError(t, err)
+
+	// tampered segment
+	err = os.WriteFile(filepath.Join(dir, "mypath", "2008-11-07_11-23-00-000000.mp4"), []byte{5}, 0o644)
+	require.NoError(t, err)
+
+	results, err := Verify(pathConf, "mypath", nil, nil)
+	require.NoError(t, err)
+
+	statuses := make([]VerificationStatus, len(results))
+	for i, res := range results {
+		statuses[i] = res.Status
+	}
+
+	require.Equal(t, []VerificationStatus{
+		VerificationStatusOK,
+		VerificationStatusModified,
+		VerificationStatusOK,
+		VerificationStatusUnregistered,
+	}, statuses)
+
+	// chain continues after a restart
+	w2 := &ManifestWriter{
+		RecordPath: pathConf.RecordPath,
+		PathName:   "mypath",
+	}
+	err = w2.Initialize()
+	require.NoError(t, err)
+	require.Equal(t, results[2].Entry.Chain, w2.lastChain)
+}
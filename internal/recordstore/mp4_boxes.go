@@ -6,8 +6,14 @@ import (
 
 func boxTypeMtxi() amp4.BoxType { return amp4.StrToBoxType("mtxi") }
 
+func boxTypeMtxs() amp4.BoxType { return amp4.StrToBoxType("mtxs") }
+
+func boxTypeMtxe() amp4.BoxType { return amp4.StrToBoxType("mtxe") }
+
 func init() { //nolint:gochecknoinits
 	amp4.AddBoxDef(&Mtxi{}, 0)
+	amp4.AddBoxDef(&Mtxs{}, 0)
+	amp4.AddBoxDef(&Mtxe{}, 0)
 }
 
 // Mtxi is a MediaMTX segment info.
@@ -23,3 +29,27 @@ type Mtxi struct {
 func (*Mtxi) GetType() amp4.BoxType {
 	return boxTypeMtxi()
 }
+
+// Mtxs marks a MediaMTX sparse segment, that contains key frames of video tracks only.
+type Mtxs struct {
+	amp4.FullBox `mp4:"0,extend"`
+}
+
+// GetType implements amp4.IBox.
+func (*Mtxs) GetType() amp4.BoxType {
+	return boxTypeMtxs()
+}
+
+// Mtxe is a MediaMTX segment encryption info.
+// It contains the data key of the segment, wrapped with the key-encryption key identified by KeyID.
+type Mtxe struct {
+	amp4.FullBox `mp4:"0,extend"`
+	KeyID        [8]byte  `mp4:"1,size=8"`
+	Nonce        [12]byte `mp4:"2,size=8"`
+	WrappedKey   [48]byte `mp4:"3,size=8"`
+}
+
+// GetType implements amp4.IBox.
+func (*Mtxe) GetType() amp4.BoxType {
+	return boxTypeMtxe()
+}
@@ -71,6 +71,9 @@ func PathAddExtension(path string, format conf.RecordFormat) string {
 	case conf.RecordFormatMPEGTS:
 		return path + ".ts"
 
+	case conf.RecordFormatMKV:
+		return path + ".mkv"
+
 	default:
 		return path + ".mp4"
 	}
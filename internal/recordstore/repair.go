@@ -0,0 +1,470 @@
+package recordstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	amp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+)
+
+// RepairResult is the result of the repair of a segment.
+type RepairResult struct {
+	// whether the segment has been modified.
+	Repaired bool
+
+	// number of complete moof/mdat pairs that have been kept.
+	PartsKept int
+
+	// number of trailing bytes that have been removed.
+	BytesDropped int64
+
+	// duration of the segment.
+	Duration time.Duration
+
+	// offset between the previous start of the segment and its first salvaged sample,
+	// that has become the new start of the segment.
+	StartOffset time.Duration
+}
+
+func readBoxHeader(r io.ReaderAt, pos int64) (uint32, string, error) {
+	buf := make([]byte, 8)
+	_, err := r.ReadAt(buf, pos)
+	if err != nil {
+		return 0, "", err
+	}
+
+	size := uint32(buf[0])<<24 | uint32(buf[1])<<16 | uint32(buf[2])<<8 | uint32(buf[3])
+
+	return size, string(buf[4:]), nil
+}
+
+func findInitTrackTimeScale(init *fmp4.Init, id int) uint32 {
+	for _, track := range init.Tracks {
+		if track.ID == id {
+			return track.TimeScale
+		}
+	}
+	return 0
+}
+
+type segmentFMP4Header struct {
+	moovPos  int64
+	moovSize uint32
+	init     fmp4.Init
+	mvhd     amp4.Mvhd
+
+	// mtxi box and position of its payload, filled when the box is present.
+	mtxi    *Mtxi
+	mtxiPos int64
+}
+
+func readSegmentFMP4Header(f *os.File, fileSize int64) (*segmentFMP4Header, error) {
+	// check ftyp and moov
+
+	ftypSize, typ, err := readBoxHeader(f, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != "ftyp" {
+		return nil, fmt.Errorf("ftyp box not found")
+	}
+
+	h := &segmentFMP4Header{
+		moovPos: int64(ftypSize),
+	}
+
+	h.moovSize, typ, err = readBoxHeader(f, h.moovPos)
+	if err != nil {
+		return nil, err
+	}
+
+	if typ != "moov" {
+		return nil, fmt.Errorf("moov box not found")
+	}
+
+	if h.moovPos+int64(h.moovSize) > fileSize {
+		return nil, fmt.Errorf("moov box is incomplete")
+	}
+
+	moov := make([]byte, h.moovSize)
+	_, err = f.ReadAt(moov, h.moovPos)
+	if err != nil {
+		return nil, err
+	}
+
+	err = h.init.Unmarshal(bytes.NewReader(moov))
+	if err != nil {
+		return nil, err
+	}
+
+	// skip moov and mvhd headers
+	_, err = amp4.Unmarshal(bytes.NewReader(moov[16:]), uint64(h.moovSize-16), &h.mvhd, amp4.Context{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, box := range h.init.UserData {
+		if mtxi, ok := box.(*Mtxi); ok {
+			h.mtxi = mtxi
+		}
+	}
+
+	if h.mtxi != nil {
+		var bi []*amp4.BoxInfo
+		bi, err = amp4.ExtractBox(bytes.NewReader(moov), nil,
+			amp4.BoxPath{amp4.BoxTypeMoov(), amp4.BoxTypeUdta(), boxTypeMtxi()})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(bi) != 1 {
+			return nil, fmt.Errorf("mtxi box not found")
+		}
+
+		h.mtxiPos = h.moovPos + int64(bi[0].Offset+bi[0].HeaderSize)
+	}
+
+	return h, nil
+}
+
+// writeDuration writes the duration into the mvhd box of a segment.
+func (h *segmentFMP4Header) writeDuration(f io.WriteSeeker, d time.Duration) error {
+	h.mvhd.DurationV0 = uint32(d * time.Duration(h.mvhd.Timescale) / time.Second)
+
+	// skip moov and mvhd headers
+	_, err := f.Seek(h.moovPos+16, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = amp4.Marshal(f, &h.mvhd, amp4.Context{})
+	return err
+}
+
+// writeMtxi writes the mtxi box in place. The box has a fixed size.
+func (h *segmentFMP4Header) writeMtxi(f io.WriteSeeker) error {
+	_, err := f.Seek(h.mtxiPos, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = amp4.Marshal(f, h.mtxi, amp4.Context{})
+	return err
+}
+
+type repairPart struct {
+	pos   int64
+	size  int64
+	parts fmp4.Parts
+}
+
+// rebasePart subtracts an offset from the base times of a part and writes the part in place.
+func rebasePart(f io.WriterAt, init *fmp4.Init, p *repairPart, offset time.Duration) error {
+	for _, part := range p.parts {
+		for _, track := range part.Tracks {
+			timeScale := findInitTrackTimeScale(init, track.ID)
+			if timeScale == 0 {
+				continue
+			}
+
+			track.BaseTime -= min(track.BaseTime, uint64(int64(offset)*int64(timeScale)/int64(time.Second)))
+		}
+	}
+
+	var buf seekablebuffer.Buffer
+	err := p.parts.Marshal(&buf)
+	if err != nil {
+		return err
+	}
+
+	if int64(len(buf.Bytes())) != p.size {
+		return fmt.Errorf("unable to rewrite part in place")
+	}
+
+	_, err = f.WriteAt(buf.Bytes(), p.pos)
+	return err
+}
+
+// segmentFMP4ReadDuration reads the duration that is written into the header when the segment is closed.
+func segmentFMP4ReadDuration(fpath string) (time.Duration, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	h, err := readSegmentFMP4Header(f, fi.Size())
+	if err != nil {
+		return 0, err
+	}
+
+	if h.mvhd.Timescale == 0 {
+		return 0, nil
+	}
+
+	return time.Duration(h.mvhd.DurationV0) * time.Second / time.Duration(h.mvhd.Timescale), nil
+}
+
+func segmentFMP4IsFinalized(fpath string) (bool, error) {
+	d, err := segmentFMP4ReadDuration(fpath)
+	if err != nil {
+		return false, err
+	}
+
+	return d != 0, nil
+}
+
+// RepairSegmentFMP4 repairs a fMP4 segment that has not been finalized, for instance because of a crash.
+// Incomplete moof/mdat pairs at the end of the segment are dropped and the duration in the header is rewritten.
+// The DTS and NTP of the mtxi box are computed again from the salvaged parts.
+// Segments that are already finalized are left untouched.
+func RepairSegmentFMP4(fpath string) (*RepairResult, error) {
+	f, err := os.OpenFile(fpath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := fi.Size()
+
+	h, err := readSegmentFMP4Header(f, fileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.mvhd.DurationV0 != 0 {
+		return &RepairResult{
+			Duration: time.Duration(h.mvhd.DurationV0) * time.Second / time.Duration(h.mvhd.Timescale),
+		}, nil
+	}
+
+	// find complete moof/mdat pairs
+
+	res := &RepairResult{}
+	pos := h.moovPos + int64(h.moovSize)
+	var kept []*repairPart
+	start := time.Duration(-1)
+	var end time.Duration
+
+	for {
+		moofSize, typ, err := readBoxHeader(f, pos)
+		if err != nil || typ != "moof" || moofSize < 8 {
+			break
+		}
+
+		mdatSize, typ, err := readBoxHeader(f, pos+int64(moofSize))
+		if err != nil || typ != "mdat" || mdatSize < 8 {
+			break
+		}
+
+		pairSize := int64(moofSize) + int64(mdatSize)
+		if pos+pairSize > fileSize {
+			break
+		}
+
+		buf := make([]byte, pairSize)
+		_, err = f.ReadAt(buf, pos)
+		if err != nil {
+			break
+		}
+
+		var parts fmp4.Parts
+		err = parts.Unmarshal(buf)
+		if err != nil {
+			break
+		}
+
+		for _, part := range parts {
+			for _, track := range part.Tracks {
+				timeScale := findInitTrackTimeScale(&h.init, track.ID)
+				if timeScale == 0 {
+					continue
+				}
+
+				d := time.Duration(track.BaseTime) * time.Second / time.Duration(timeScale)
+				if start < 0 || d < start {
+					start = d
+				}
+
+				elapsed := int64(track.BaseTime)
+				for _, sample := range track.Samples {
+					elapsed += int64(sample.Duration)
+				}
+
+				d = time.Duration(elapsed) * time.Second / time.Duration(timeScale)
+				if d > end {
+					end = d
+				}
+			}
+		}
+
+		kept = append(kept, &repairPart{pos: pos, size: pairSize, parts: parts})
+		pos += pairSize
+	}
+
+	res.PartsKept = len(kept)
+	res.BytesDropped = fileSize - pos
+
+	if res.BytesDropped != 0 {
+		err = f.Truncate(pos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// base times are relative to the DTS in the mtxi box.
+	// When salvaged parts start after it, the DTS is moved to the first salvaged sample.
+	if h.mtxi != nil && start > 0 {
+		res.StartOffset = start
+
+		for _, p := range kept {
+			err = rebasePart(f, &h.init, p, start)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		h.mtxi.DTS += int64(start)
+		h.mtxi.NTP += int64(start)
+	}
+
+	res.Duration = end - res.StartOffset
+
+	if h.mtxi != nil {
+		err = h.writeMtxi(f)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = h.writeDuration(f, res.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	err = f.Sync()
+	if err != nil {
+		return nil, err
+	}
+
+	res.Repaired = true
+
+	return res, nil
+}
+
+// FindUnfinalizedSegments returns the last segments of a path that have not been finalized.
+// Segments are checked from the newest one and the search stops at the first finalized segment.
+// It must be called before the path is recorded, otherwise segments that are being written are returned too.
+func FindUnfinalizedSegments(
+	pathConf *conf.Path,
+	pathName string,
+) ([]*Segment, error) {
+	if pathConf.RecordFormat != conf.RecordFormatFMP4 {
+		return nil, nil
+	}
+
+	segments, err := FindSegments(pathConf, pathName, nil, nil)
+	if err != nil {
+		if errors.Is(err, ErrNoSegmentsFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ret []*Segment
+
+	for i := len(segments) - 1; i >= 0; i-- {
+		// segments are uploaded after they have been finalized.
+		if segments[i].Remote != "" {
+			break
+		}
+
+		finalized, err := segmentFMP4IsFinalized(segments[i].Fpath)
+		if err == nil && finalized {
+			break
+		}
+
+		// segments with a broken header are returned too, in order to be reported.
+		ret = append(ret, segments[i])
+	}
+
+	return ret, nil
+}
+
+// RepairSegment repairs a segment and updates the index.
+// Segments with no complete moof/mdat pairs are deleted.
+// Segments whose start has been moved are renamed and the segment is updated with the new path.
+func RepairSegment(pathConf *conf.Path, pathName string, seg *Segment) (*RepairResult, error) {
+	res, err := RepairSegmentFMP4(seg.Fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	if !res.Repaired {
+		return res, nil
+	}
+
+	if res.PartsKept == 0 {
+		err = RemoveSegment(pathConf, pathName, seg.Fpath)
+		return res, err
+	}
+
+	if res.StartOffset != 0 {
+		err = renameSegment(pathConf, pathName, seg, seg.Start.Add(res.StartOffset))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var size int64
+	if fi, err2 := os.Stat(seg.Fpath); err2 == nil {
+		size = fi.Size()
+	}
+
+	err = IndexSegmentComplete(pathConf.RecordPath, pathConf.RecordFormat, pathName, seg.Fpath,
+		res.Duration, size, seg.Codecs)
+	return res, err
+}
+
+// renameSegment moves a segment to the path of a new start and updates the index.
+func renameSegment(pathConf *conf.Path, pathName string, seg *Segment, start time.Time) error {
+	pathFormat := PathAddExtension(
+		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
+		pathConf.RecordFormat,
+	)
+	fpath := Path{Start: start}.Encode(pathFormat)
+
+	err := os.Rename(seg.Fpath, fpath)
+	if err != nil {
+		return err
+	}
+
+	err = IndexSegmentRemove(pathConf, pathName, seg.Fpath)
+	if err != nil {
+		return err
+	}
+
+	seg.Fpath = fpath
+	seg.Start = start
+
+	return IndexSegmentCreate(pathConf.RecordPath, pathConf.RecordFormat, pathName, fpath, start)
+}
@@ -0,0 +1,230 @@
+package recordstore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	amp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairSegmentFMP4(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &mp4.CodecH264{
+				SPS: test.FormatH264.SPS,
+				PPS: test.FormatH264.PPS,
+			},
+		}},
+	}
+
+	var buf seekablebuffer.Buffer
+	err = init.Marshal(&buf)
+	require.NoError(t, err)
+
+	for _, part := range []*fmp4.Part{
+		{
+			SequenceNumber: 0,
+			Tracks: []*fmp4.PartTrack{{
+				ID:       1,
+				BaseTime: 0,
+				Samples: []*fmp4.Sample{
+					{Duration: 90000, Payload: []byte{1, 2}},
+					{Duration: 90000, Payload: []byte{3, 4}},
+				},
+			}},
+		},
+		{
+			SequenceNumber: 1,
+			Tracks: []*fmp4.PartTrack{{
+				ID:       1,
+				BaseTime: 2 * 90000,
+				Samples: []*fmp4.Sample{
+					{Duration: 90000, Payload: []byte{5, 6}},
+				},
+			}},
+		},
+	} {
+		var partBuf seekablebuffer.Buffer
+		err = part.Marshal(&partBuf)
+		require.NoError(t, err)
+
+		_, err = buf.Write(partBuf.Bytes())
+		require.NoError(t, err)
+	}
+
+	complete := len(buf.Bytes())
+
+	// partial part left by a crash
+	byts := append(append([]byte(nil), buf.Bytes()...), buf.Bytes()[complete-20:complete-5]...)
+
+	fpath := filepath.Join(dir, "segment.mp4")
+	err = os.WriteFile(fpath, byts, 0o644)
+	require.NoError(t, err)
+
+	finalized, err := segmentFMP4IsFinalized(fpath)
+	require.NoError(t, err)
+	require.False(t, finalized)
+
+	res, err := RepairSegmentFMP4(fpath)
+	require.NoError(t, err)
+	require.Equal(t, &RepairResult{
+		Repaired:     true,
+		PartsKept:    2,
+		BytesDropped: 15,
+		Duration:     3 * time.Second,
+	}, res)
+
+	fi, err := os.Stat(fpath)
+	require.NoError(t, err)
+	require.Equal(t, int64(complete), fi.Size())
+
+	finalized, err = segmentFMP4IsFinalized(fpath)
+	require.NoError(t, err)
+	require.True(t, finalized)
+
+	res, err = RepairSegmentFMP4(fpath)
+	require.NoError(t, err)
+	require.Equal(t, &RepairResult{
+		Duration: 3 * time.Second,
+	}, res)
+}
+
+func TestRepairSegmentStartOffset(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-recordstore")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pathConf := &conf.Path{
+		Name:         "path1",
+		RecordPath:   filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+		RecordFormat: conf.RecordFormatFMP4,
+	}
+
+	start := time.Date(2008, 11, 7, 11, 22, 0, 900000000, time.Local)
+
+	init := fmp4.Init{
+		Tracks: []*fmp4.InitTrack{{
+			ID:        1,
+			TimeScale: 90000,
+			Codec: &mp4.CodecH264{
+				SPS: test.FormatH264.SPS,
+				PPS: test.FormatH264.PPS,
+			},
+		}},
+		UserData: []amp4.IBox{
+			&Mtxi{
+				SegmentNumber: 3,
+				DTS:           int64(5 * time.Second),
+				NTP:           start.UnixNano(),
+			},
+		},
+	}
+
+	var buf seekablebuffer.Buffer
+	err = init.Marshal(&buf)
+	require.NoError(t, err)
+
+	// the first salvaged sample is one second after the start written in the header
+	for _, part := range []*fmp4.Part{
+		{
+			SequenceNumber: 0,
+			Tracks: []*fmp4.PartTrack{{
+				ID:       1,
+				BaseTime: 90000,
+				Samples: []*fmp4.Sample{
+					{Duration: 90000, Payload: []byte{1, 2}},
+					{Duration: 90000, Payload: []byte{3, 4}},
+				},
+			}},
+		},
+		{
+			SequenceNumber: 1,
+			Tracks: []*fmp4.PartTrack{{
+				ID:       1,
+				BaseTime: 3 * 90000,
+				Samples: []*fmp4.Sample{
+					{Duration: 90000, Payload: []byte{5, 6}},
+				},
+			}},
+		},
+	} {
+		var partBuf seekablebuffer.Buffer
+		err = part.Marshal(&partBuf)
+		require.NoError(t, err)
+
+		_, err = buf.Write(partBuf.Bytes())
+		require.NoError(t, err)
+	}
+
+	fpath := filepath.Join(dir, "path1", "2008-11-07_11-22-00-900000.mp4")
+	err = os.Mkdir(filepath.Join(dir, "path1"), 0o755)
+	require.NoError(t, err)
+
+	err = os.WriteFile(fpath, buf.Bytes(), 0o644)
+	require.NoError(t, err)
+
+	err = IndexSegmentCreate(pathConf.RecordPath, pathConf.RecordFormat, "path1", fpath, start)
+	require.NoError(t, err)
+
+	segments, err := FindUnfinalizedSegments(pathConf, "path1")
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+
+	res, err := RepairSegment(pathConf, "path1", segments[0])
+	require.NoError(t, err)
+	require.Equal(t, &RepairResult{
+		Repaired:    true,
+		PartsKept:   2,
+		Duration:    3 * time.Second,
+		StartOffset: 1 * time.Second,
+	}, res)
+
+	newPath := filepath.Join(dir, "path1", "2008-11-07_11-22-01-900000.mp4")
+	require.Equal(t, newPath, segments[0].Fpath)
+
+	_, err = os.Stat(fpath)
+	require.True(t, os.IsNotExist(err))
+
+	f, err := os.Open(newPath)
+	require.NoError(t, err)
+	defer f.Close()
+
+	fi, err := f.Stat()
+	require.NoError(t, err)
+
+	h, err := readSegmentFMP4Header(f, fi.Size())
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), h.mtxi.SegmentNumber)
+	require.Equal(t, int64(6*time.Second), h.mtxi.DTS)
+	require.Equal(t, start.Add(time.Second).UnixNano(), h.mtxi.NTP)
+
+	byts, err := os.ReadFile(newPath)
+	require.NoError(t, err)
+
+	var parts fmp4.Parts
+	err = parts.Unmarshal(byts[h.moovPos+int64(h.moovSize):])
+	require.NoError(t, err)
+	require.Len(t, parts, 2)
+	require.Equal(t, uint64(0), parts[0].Tracks[0].BaseTime)
+	require.Equal(t, uint64(2*90000), parts[1].Tracks[0].BaseTime)
+
+	found, err := FindSegments(pathConf, "path1", nil, nil)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, newPath, found[0].Fpath)
+	require.Equal(t, 3*time.Second, found[0].Duration)
+}
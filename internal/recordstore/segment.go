@@ -2,10 +2,7 @@ package recordstore
 
 import (
 	"errors"
-	"io/fs"
-	"path/filepath"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
@@ -14,80 +11,52 @@ import (
 // ErrNoSegmentsFound is returned when no recording segments have been found.
 var ErrNoSegmentsFound = errors.New("no recording segments found")
 
-var errFound = errors.New("found")
-
 // Segment is a recording segment.
 type Segment struct {
 	Fpath string
 	Start time.Time
-}
-
-func fixedPathHasSegments(pathConf *conf.Path) bool {
-	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathConf.Name),
-		pathConf.RecordFormat,
-	)
-
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
 
-	commonPath := CommonPath(recordPath)
+	// the following fields are filled by the recording index
+	// and are zero when unknown.
+	Duration time.Duration
+	Size     int64
+	Codecs   []string
 
-	err := filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	// whether the segment has been downsampled.
+	Sparse bool
 
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
-			if ok {
-				return errFound
-			}
-		}
+	// key of the segment inside the object storage,
+	// filled when the segment has been uploaded.
+	Remote string
+}
 
-		return nil
-	})
-	if err != nil && !errors.Is(err, errFound) {
+func fixedPathHasSegments(pathConf *conf.Path) bool {
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	if err != nil {
 		return false
 	}
 
-	return errors.Is(err, errFound)
+	idx.mutex.RLock()
+	defer idx.mutex.RUnlock()
+
+	return len(idx.byPath[pathConf.Name]) != 0
 }
 
 func regexpPathFindPathsWithSegments(pathConf *conf.Path) map[string]struct{} {
-	recordPath := PathAddExtension(
-		pathConf.RecordPath,
-		pathConf.RecordFormat,
-	)
-
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
-
-	commonPath := CommonPath(recordPath)
-
 	ret := make(map[string]struct{})
 
-	filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error { //nolint:errcheck
-		if err != nil {
-			return err
-		}
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
+	if err != nil {
+		return ret
+	}
 
-		if !info.IsDir() {
-			var pa Path
-			if ok := pa.Decode(recordPath, fpath); ok {
-				if err = conf.IsValidPathName(pa.Path); err == nil {
-					if pathConf.Regexp.FindStringSubmatch(pa.Path) != nil {
-						ret[pa.Path] = struct{}{}
-					}
-				}
+	for _, name := range idx.pathNames() {
+		if err = conf.IsValidPathName(name); err == nil {
+			if pathConf.Regexp.FindStringSubmatch(name) != nil {
+				ret[name] = struct{}{}
 			}
 		}
-
-		return nil
-	})
+	}
 
 	return ret
 }
@@ -127,72 +96,18 @@ func FindSegments(
 	start *time.Time,
 	end *time.Time,
 ) ([]*Segment, error) {
-	recordPath := PathAddExtension(
-		strings.ReplaceAll(pathConf.RecordPath, "%path", pathName),
-		pathConf.RecordFormat,
-	)
-
-	// we have to convert to absolute paths
-	// otherwise, recordPath and fpath inside Walk() won't have common elements
-	recordPath, _ = filepath.Abs(recordPath)
-
-	commonPath := CommonPath(recordPath)
-	var segments []*Segment
-
-	err := filepath.WalkDir(commonPath, func(fpath string, info fs.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			var pa Path
-			ok := pa.Decode(recordPath, fpath)
-
-			// gather all segments that start before the end of the playback
-			if ok && (end == nil || !end.Before(pa.Start)) {
-				segments = append(segments, &Segment{
-					Fpath: fpath,
-					Start: pa.Start,
-				})
-			}
-		}
-
-		return nil
-	})
+	idx, err := getIndex(pathConf.RecordPath, pathConf.RecordFormat)
 	if err != nil {
 		return nil, err
 	}
 
-	if segments == nil {
-		return nil, ErrNoSegmentsFound
+	segments, err := idx.findSegments(pathName, start, end)
+	if err != nil {
+		return nil, err
 	}
 
-	sort.Slice(segments, func(i, j int) bool {
-		return segments[i].Start.Before(segments[j].Start)
-	})
-
-	if start != nil {
-		if start.Before(segments[0].Start) {
-			return segments, nil
-		}
-
-		// find the segment that may contain the start of the playback and remove all previous ones
-		found := false
-		for i := 0; i < len(segments)-1; i++ {
-			if !start.Before(segments[i].Start) && start.Before(segments[i+1].Start) {
-				segments = segments[i:]
-				found = true
-				break
-			}
-		}
-
-		// otherwise, keep the last segment only and check if it may contain the start of the playback
-		if !found {
-			segments = segments[len(segments)-1:]
-			if segments[len(segments)-1].Start.After(*start) {
-				return nil, ErrNoSegmentsFound
-			}
-		}
+	if len(segments) == 0 {
+		return nil, ErrNoSegmentsFound
 	}
 
 	return segments, nil
@@ -0,0 +1,1007 @@
+package recordstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	amp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/fmp4/seekablebuffer"
+	"github.com/bluenviron/mediacommon/v2/pkg/formats/mp4"
+	"github.com/google/uuid"
+)
+
+// Matroska element IDs.
+const (
+	mkvIDEBML               = 0x1A45DFA3
+	mkvIDEBMLVersion        = 0x4286
+	mkvIDEBMLReadVersion    = 0x42F7
+	mkvIDEBMLMaxIDLength    = 0x42F2
+	mkvIDEBMLMaxSizeLength  = 0x42F3
+	mkvIDDocType            = 0x4282
+	mkvIDDocTypeVersion     = 0x4287
+	mkvIDDocTypeReadVersion = 0x4285
+	mkvIDSegment            = 0x18538067
+	mkvIDInfo               = 0x1549A966
+	mkvIDTimestampScale     = 0x2AD7B1
+	mkvIDDuration           = 0x4489
+	mkvIDDateUTC            = 0x4461
+	mkvIDMuxingApp          = 0x4D80
+	mkvIDWritingApp         = 0x5741
+	mkvIDTracks             = 0x1654AE6B
+	mkvIDTrackEntry         = 0xAE
+	mkvIDTrackNumber        = 0xD7
+	mkvIDTrackUID           = 0x73C5
+	mkvIDTrackType          = 0x83
+	mkvIDFlagLacing         = 0x9C
+	mkvIDCodecID            = 0x86
+	mkvIDCodecPrivate       = 0x63A2
+	mkvIDVideo              = 0xE0
+	mkvIDPixelWidth         = 0xB0
+	mkvIDPixelHeight        = 0xBA
+	mkvIDAudio              = 0xE1
+	mkvIDSamplingFrequency  = 0xB5
+	mkvIDChannels           = 0x9F
+	mkvIDBitDepth           = 0x6264
+	mkvIDTags               = 0x1254C367
+	mkvIDTag                = 0x7373
+	mkvIDTargets            = 0x63C0
+	mkvIDSimpleTag          = 0x67C8
+	mkvIDTagName            = 0x45A3
+	mkvIDTagString          = 0x4487
+	mkvIDTagBinary          = 0x4485
+	mkvIDCluster            = 0x1F43B675
+	mkvIDTimestamp          = 0xE7
+	mkvIDSimpleBlock        = 0xA3
+)
+
+// tags that contain the same informations of the mtxi box of fMP4 segments.
+const (
+	mkvTagStreamID      = "MTX_STREAM_ID"
+	mkvTagSegmentNumber = "MTX_SEGMENT_NUMBER"
+	mkvTagDTS           = "MTX_DTS"
+	mkvTagNTP           = "MTX_NTP"
+
+	// fMP4 initialization of tracks, that allows to remux segments into MP4
+	// without parsing codec-specific data.
+	mkvTagTracks = "MTX_TRACKS"
+)
+
+const (
+	// timestamps are expressed in milliseconds.
+	mkvTimestampScale = 1000000
+
+	mkvTrackTypeVideo = 1
+	mkvTrackTypeAudio = 2
+
+	mkvMaxRelativeTimestamp = math.MaxInt16
+	mkvMinRelativeTimestamp = math.MinInt16
+)
+
+var mkvEpoch = time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// unknown size, encoded on 8 bytes.
+var mkvUnknownSize = []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+func mkvAppendID(buf []byte, id uint32) []byte {
+	switch {
+	case id >= 1<<24:
+		return append(buf, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	case id >= 1<<16:
+		return append(buf, byte(id>>16), byte(id>>8), byte(id))
+	case id >= 1<<8:
+		return append(buf, byte(id>>8), byte(id))
+	default:
+		return append(buf, byte(id))
+	}
+}
+
+func mkvAppendSize(buf []byte, size uint64) []byte {
+	n := 1
+	for n < 8 && size >= (1<<(7*n))-1 {
+		n++
+	}
+
+	size |= 1 << (7 * n)
+
+	for i := n - 1; i >= 0; i-- {
+		buf = append(buf, byte(size>>(8*i)))
+	}
+	return buf
+}
+
+func mkvElement(id uint32, payload []byte) []byte {
+	buf := mkvAppendID(nil, id)
+	buf = mkvAppendSize(buf, uint64(len(payload)))
+	return append(buf, payload...)
+}
+
+func mkvMaster(id uint32, children ...[]byte) []byte {
+	return mkvElement(id, bytes.Join(children, nil))
+}
+
+func mkvUint(id uint32, v uint64) []byte {
+	n := 1
+	for n < 8 && v >= 1<<(8*n) {
+		n++
+	}
+
+	payload := make([]byte, n)
+	for i := range n {
+		payload[i] = byte(v >> (8 * (n - 1 - i)))
+	}
+	return mkvElement(id, payload)
+}
+
+func mkvInt(id uint32, v int64) []byte {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, uint64(v))
+	return mkvElement(id, payload)
+}
+
+func mkvFloat(id uint32, v float64) []byte {
+	payload := make([]byte, 8)
+	binary.BigEndian.PutUint64(payload, math.Float64bits(v))
+	return mkvElement(id, payload)
+}
+
+func mkvString(id uint32, v string) []byte {
+	return mkvElement(id, []byte(v))
+}
+
+func mkvSimpleTag(name string, value []byte, binaryValue bool) []byte {
+	if binaryValue {
+		return mkvMaster(mkvIDSimpleTag,
+			mkvString(mkvIDTagName, name),
+			mkvElement(mkvIDTagBinary, value))
+	}
+	return mkvMaster(mkvIDSimpleTag,
+		mkvString(mkvIDTagName, name),
+		mkvElement(mkvIDTagString, value))
+}
+
+// mkvCodecPrivateFromInit returns the content of a codec configuration box
+// that is generated by the fMP4 muxer.
+func mkvCodecPrivateFromInit(track *fmp4.InitTrack, boxType string) ([]byte, error) {
+	init := fmp4.Init{Tracks: []*fmp4.InitTrack{track}}
+
+	var buf seekablebuffer.Buffer
+	err := init.Marshal(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	byts := buf.Bytes()
+	var ret []byte
+
+	_, err = amp4.ReadBoxStructure(bytes.NewReader(byts), func(h *amp4.ReadHandle) (any, error) {
+		if h.BoxInfo.Type.String() == boxType {
+			start := h.BoxInfo.Offset + h.BoxInfo.HeaderSize
+			ret = byts[start : h.BoxInfo.Offset+h.BoxInfo.Size]
+			return nil, nil
+		}
+		return h.Expand()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if ret == nil {
+		return nil, fmt.Errorf("%s box not found", boxType)
+	}
+
+	return ret, nil
+}
+
+// channel mappings of Opus streams with more than 2 channels (RFC7845, section 5.1.1.2).
+var mkvOpusChannelMappings = map[int]struct {
+	streamCount  uint8
+	coupledCount uint8
+	mapping      []uint8
+}{
+	3: {2, 1, []uint8{0, 2, 1}},
+	4: {2, 2, []uint8{0, 1, 2, 3}},
+	5: {3, 2, []uint8{0, 4, 1, 2, 3}},
+	6: {4, 2, []uint8{0, 4, 1, 2, 3, 5}},
+	7: {4, 3, []uint8{0, 4, 1, 2, 3, 5, 6}},
+	8: {5, 3, []uint8{0, 6, 1, 2, 3, 4, 5, 7}},
+}
+
+func mkvOpusHead(channelCount int) []byte {
+	head := []byte{'O', 'p', 'u', 's', 'H', 'e', 'a', 'd', 1, byte(channelCount)}
+	head = binary.LittleEndian.AppendUint16(head, 3840) // pre-skip
+	head = binary.LittleEndian.AppendUint32(head, 48000)
+	head = binary.LittleEndian.AppendUint16(head, 0) // output gain
+
+	if m, ok := mkvOpusChannelMappings[channelCount]; ok {
+		head = append(head, 1, m.streamCount, m.coupledCount)
+		head = append(head, m.mapping...)
+	} else {
+		head = append(head, 0)
+	}
+
+	return head
+}
+
+func mkvVideo(width int, height int) []byte {
+	return mkvMaster(mkvIDVideo,
+		mkvUint(mkvIDPixelWidth, uint64(width)),
+		mkvUint(mkvIDPixelHeight, uint64(height)))
+}
+
+func mkvAudio(sampleRate int, channelCount int, bitDepth int) []byte {
+	children := [][]byte{
+		mkvFloat(mkvIDSamplingFrequency, float64(sampleRate)),
+		mkvUint(mkvIDChannels, uint64(channelCount)),
+	}
+	if bitDepth != 0 {
+		children = append(children, mkvUint(mkvIDBitDepth, uint64(bitDepth)))
+	}
+	return mkvMaster(mkvIDAudio, children...)
+}
+
+func mkvTrackEntry(track *fmp4.InitTrack) ([]byte, error) {
+	var codecID string
+	var codecPrivate []byte
+	var trackType uint64
+	var settings []byte
+	var err error
+
+	switch codec := track.Codec.(type) {
+	case *mp4.CodecAV1:
+		codecID = "V_AV1"
+		codecPrivate, err = mkvCodecPrivateFromInit(track, "av1C")
+		if err != nil {
+			return nil, err
+		}
+
+		var sh av1.SequenceHeader
+		if sh.Unmarshal(codec.SequenceHeader) == nil {
+			settings = mkvVideo(sh.Width(), sh.Height())
+		}
+
+	case *mp4.CodecVP9:
+		codecID = "V_VP9"
+		settings = mkvVideo(codec.Width, codec.Height)
+
+	case *mp4.CodecH265:
+		codecID = "V_MPEGH/ISO/HEVC"
+		codecPrivate, err = mkvCodecPrivateFromInit(track, "hvcC")
+		if err != nil {
+			return nil, err
+		}
+
+		var sps h265.SPS
+		if sps.Unmarshal(codec.SPS) == nil {
+			settings = mkvVideo(sps.Width(), sps.Height())
+		}
+
+	case *mp4.CodecH264:
+		codecID = "V_MPEG4/ISO/AVC"
+		codecPrivate, err = mkvCodecPrivateFromInit(track, "avcC")
+		if err != nil {
+			return nil, err
+		}
+
+		var sps h264.SPS
+		if sps.Unmarshal(codec.SPS) == nil {
+			settings = mkvVideo(sps.Width(), sps.Height())
+		}
+
+	case *mp4.CodecMPEG4Video:
+		codecID = "V_MPEG4/ISO/ASP"
+		codecPrivate = codec.Config
+
+	case *mp4.CodecMPEG1Video:
+		codecID = "V_MPEG2"
+		codecPrivate = codec.Config
+
+	case *mp4.CodecMJPEG:
+		codecID = "V_MJPEG"
+		settings = mkvVideo(codec.Width, codec.Height)
+
+	case *mp4.CodecOpus:
+		codecID = "A_OPUS"
+		codecPrivate = mkvOpusHead(codec.ChannelCount)
+		settings = mkvAudio(48000, codec.ChannelCount, 0)
+
+	case *mp4.CodecMPEG4Audio:
+		codecID = "A_AAC"
+		codecPrivate, err = codec.Config.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		settings = mkvAudio(codec.Config.SampleRate, codec.Config.ChannelCount, 0)
+
+	case *mp4.CodecMPEG1Audio:
+		// decoders detect the layer from frame headers.
+		codecID = "A_MPEG/L3"
+		settings = mkvAudio(codec.SampleRate, codec.ChannelCount, 0)
+
+	case *mp4.CodecAC3:
+		codecID = "A_AC3"
+		settings = mkvAudio(codec.SampleRate, codec.ChannelCount, 0)
+
+	case *mp4.CodecLPCM:
+		if codec.LittleEndian {
+			codecID = "A_PCM/INT/LIT"
+		} else {
+			codecID = "A_PCM/INT/BIG"
+		}
+		settings = mkvAudio(codec.SampleRate, codec.ChannelCount, codec.BitDepth)
+
+	default:
+		return nil, fmt.Errorf("unsupported codec: %T", codec)
+	}
+
+	if track.Codec.IsVideo() {
+		trackType = mkvTrackTypeVideo
+	} else {
+		trackType = mkvTrackTypeAudio
+	}
+
+	children := [][]byte{
+		mkvUint(mkvIDTrackNumber, uint64(track.ID)),
+		mkvUint(mkvIDTrackUID, uint64(track.ID)),
+		mkvUint(mkvIDTrackType, trackType),
+		mkvUint(mkvIDFlagLacing, 0),
+		mkvString(mkvIDCodecID, codecID),
+	}
+
+	if codecPrivate != nil {
+		children = append(children, mkvElement(mkvIDCodecPrivate, codecPrivate))
+	}
+
+	if settings != nil {
+		children = append(children, settings)
+	}
+
+	return mkvMaster(mkvIDTrackEntry, children...), nil
+}
+
+// MKVBlock is a sample of a Matroska segment.
+type MKVBlock struct {
+	TrackID    int
+	DTS        time.Duration // relative to the start of the segment
+	PTS        time.Duration // relative to the start of the segment
+	IsKeyFrame bool
+	Payload    []byte
+}
+
+// MKVSegmentWriter writes a Matroska segment.
+type MKVSegmentWriter struct {
+	W io.WriteSeeker
+
+	segmentSizePos int64
+	durationPos    int64
+}
+
+// WriteHeader writes the header of the segment.
+// init contains tracks and the mtxi box of the segment.
+func (w *MKVSegmentWriter) WriteHeader(init *fmp4.Init, mtxi *Mtxi) error {
+	var tracks [][]byte
+	for _, track := range init.Tracks {
+		entry, err := mkvTrackEntry(track)
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, entry)
+	}
+
+	var initBuf seekablebuffer.Buffer
+	err := (&fmp4.Init{Tracks: init.Tracks}).Marshal(&initBuf)
+	if err != nil {
+		return err
+	}
+
+	ebml := mkvMaster(mkvIDEBML,
+		mkvUint(mkvIDEBMLVersion, 1),
+		mkvUint(mkvIDEBMLReadVersion, 1),
+		mkvUint(mkvIDEBMLMaxIDLength, 4),
+		mkvUint(mkvIDEBMLMaxSizeLength, 8),
+		mkvString(mkvIDDocType, "matroska"),
+		mkvUint(mkvIDDocTypeVersion, 4),
+		mkvUint(mkvIDDocTypeReadVersion, 2))
+
+	segmentHeader := append(mkvAppendID(nil, mkvIDSegment), mkvUnknownSize...)
+
+	// duration is placed at the end of Info, in order to be overwritten when the segment is finalized.
+	info := mkvMaster(mkvIDInfo,
+		mkvUint(mkvIDTimestampScale, mkvTimestampScale),
+		mkvString(mkvIDMuxingApp, "mediamtx"),
+		mkvString(mkvIDWritingApp, "mediamtx"),
+		mkvInt(mkvIDDateUTC, int64(time.Unix(0, mtxi.NTP).Sub(mkvEpoch))),
+		mkvFloat(mkvIDDuration, 0))
+
+	tags := mkvMaster(mkvIDTags,
+		mkvMaster(mkvIDTag,
+			mkvMaster(mkvIDTargets),
+			mkvSimpleTag(mkvTagStreamID, []byte(uuid.UUID(mtxi.StreamID).String()), false),
+			mkvSimpleTag(mkvTagSegmentNumber, []byte(strconv.FormatUint(mtxi.SegmentNumber, 10)), false),
+			mkvSimpleTag(mkvTagDTS, []byte(strconv.FormatInt(mtxi.DTS, 10)), false),
+			mkvSimpleTag(mkvTagNTP, []byte(strconv.FormatInt(mtxi.NTP, 10)), false),
+			mkvSimpleTag(mkvTagTracks, initBuf.Bytes(), true)))
+
+	w.segmentSizePos = int64(len(ebml) + 4)
+	w.durationPos = int64(len(ebml)+len(segmentHeader)+len(info)) - 8
+
+	buf := bytes.Join([][]byte{
+		ebml,
+		segmentHeader,
+		info,
+		mkvMaster(mkvIDTracks, tracks...),
+		tags,
+	}, nil)
+
+	_, err = w.W.Write(buf)
+	return err
+}
+
+// WriteCluster writes blocks into one or more clusters.
+func (w *MKVSegmentWriter) WriteCluster(blocks []*MKVBlock) error {
+	// blocks must be stored in decode order
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].DTS < blocks[j].DTS
+	})
+
+	var buf []byte
+	var cluster [][]byte
+	var clusterTimestamp int64
+
+	flush := func() {
+		if cluster != nil {
+			buf = append(buf, mkvMaster(mkvIDCluster, cluster...)...)
+			cluster = nil
+		}
+	}
+
+	for _, block := range blocks {
+		pts := int64(block.PTS / time.Millisecond)
+
+		if cluster == nil ||
+			(pts-clusterTimestamp) > mkvMaxRelativeTimestamp ||
+			(pts-clusterTimestamp) < mkvMinRelativeTimestamp {
+			flush()
+			clusterTimestamp = max(pts, 0)
+			cluster = [][]byte{mkvUint(mkvIDTimestamp, uint64(clusterTimestamp))}
+		}
+
+		var flags byte
+		if block.IsKeyFrame {
+			flags |= 0x80
+		}
+
+		payload := mkvAppendSize(nil, uint64(block.TrackID))
+		payload = binary.BigEndian.AppendUint16(payload, uint16(int16(pts-clusterTimestamp)))
+		payload = append(payload, flags)
+		payload = append(payload, block.Payload...)
+
+		cluster = append(cluster, mkvElement(mkvIDSimpleBlock, payload))
+	}
+
+	flush()
+
+	_, err := w.W.Write(buf)
+	return err
+}
+
+// Finalize writes the duration and the size of the segment.
+func (w *MKVSegmentWriter) Finalize(duration time.Duration) error {
+	end, err := w.W.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Seek(w.durationPos, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	err = binary.Write(w.W, binary.BigEndian, math.Float64bits(float64(duration)/mkvTimestampScale))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Seek(w.segmentSizePos, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	size := uint64(end-w.segmentSizePos-8) | (1 << 56)
+	err = binary.Write(w.W, binary.BigEndian, size)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.W.Seek(0, io.SeekEnd)
+	return err
+}
+
+// mkvReader reads EBML elements and keeps track of the position.
+type mkvReader struct {
+	br  *bufio.Reader
+	pos int64
+}
+
+func (r *mkvReader) readByte() (byte, error) {
+	b, err := r.br.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	r.pos++
+	return b, nil
+}
+
+func (r *mkvReader) read(n uint64) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r.br, buf)
+	if err != nil {
+		return nil, err
+	}
+	r.pos += int64(n)
+	return buf, nil
+}
+
+func (r *mkvReader) skip(n uint64) error {
+	_, err := r.br.Discard(int(n))
+	if err != nil {
+		return err
+	}
+	r.pos += int64(n)
+	return nil
+}
+
+func mkvVintLength(first byte) (int, error) {
+	for i := range 8 {
+		if first&(0x80>>i) != 0 {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid variable-size integer")
+}
+
+// readHeader reads ID and size of an element. A negative size means that size is unknown.
+func (r *mkvReader) readHeader() (uint32, int64, error) {
+	first, err := r.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n, err := mkvVintLength(first)
+	if err != nil || n > 4 {
+		return 0, 0, fmt.Errorf("invalid element ID")
+	}
+
+	id := uint32(first)
+	for range n - 1 {
+		var b byte
+		b, err = r.readByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		id = id<<8 | uint32(b)
+	}
+
+	first, err = r.readByte()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n, err = mkvVintLength(first)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	size := uint64(first & (0xFF >> n))
+	allOnes := size == uint64(0xFF>>n)
+
+	for range n - 1 {
+		var b byte
+		b, err = r.readByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		size = size<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+
+	if allOnes {
+		return id, -1, nil
+	}
+
+	if size > math.MaxInt64 {
+		return 0, 0, fmt.Errorf("invalid element size")
+	}
+
+	return id, int64(size), nil
+}
+
+func mkvParseChildren(buf []byte, cb func(id uint32, payload []byte) error) error {
+	r := &mkvReader{br: bufio.NewReader(bytes.NewReader(buf))}
+
+	for r.pos < int64(len(buf)) {
+		id, size, err := r.readHeader()
+		if err != nil {
+			return err
+		}
+
+		if size < 0 || r.pos+size > int64(len(buf)) {
+			return fmt.Errorf("invalid element size")
+		}
+
+		payload := buf[r.pos : r.pos+size]
+
+		err = cb(id, payload)
+		if err != nil {
+			return err
+		}
+
+		err = r.skip(uint64(size))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func mkvDecodeUint(buf []byte) uint64 {
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func mkvDecodeFloat(buf []byte) float64 {
+	switch len(buf) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf))
+	default:
+		return 0
+	}
+}
+
+// MKVSegmentHeader is the header of a Matroska segment written by the recorder.
+type MKVSegmentHeader struct {
+	// tracks and mtxi box of the segment.
+	Init *fmp4.Init
+
+	// duration of the segment. It is zero when the segment has not been finalized.
+	Duration time.Duration
+
+	timestampScale int64
+	clustersPos    int64
+}
+
+func (h *MKVSegmentHeader) parseTags(payload []byte) error {
+	mtxi := &Mtxi{}
+	hasMtxi := false
+
+	err := mkvParseChildren(payload, func(id uint32, payload []byte) error {
+		if id != mkvIDTag {
+			return nil
+		}
+
+		return mkvParseChildren(payload, func(id uint32, payload []byte) error {
+			if id != mkvIDSimpleTag {
+				return nil
+			}
+
+			var name string
+			var value []byte
+
+			err := mkvParseChildren(payload, func(id uint32, payload []byte) error {
+				switch id {
+				case mkvIDTagName:
+					name = string(payload)
+				case mkvIDTagString, mkvIDTagBinary:
+					value = payload
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			switch name {
+			case mkvTagStreamID:
+				streamID, err := uuid.Parse(string(value))
+				if err != nil {
+					return err
+				}
+				mtxi.StreamID = streamID
+				hasMtxi = true
+
+			case mkvTagSegmentNumber:
+				mtxi.SegmentNumber, err = strconv.ParseUint(string(value), 10, 64)
+
+			case mkvTagDTS:
+				mtxi.DTS, err = strconv.ParseInt(string(value), 10, 64)
+
+			case mkvTagNTP:
+				mtxi.NTP, err = strconv.ParseInt(string(value), 10, 64)
+
+			case mkvTagTracks:
+				var init fmp4.Init
+				err = init.Unmarshal(bytes.NewReader(value))
+				h.Init.Tracks = init.Tracks
+			}
+
+			return err
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if hasMtxi {
+		h.Init.UserData = append(h.Init.UserData, mtxi)
+	}
+
+	return nil
+}
+
+// ReadMKVSegmentHeader reads the header of a Matroska segment.
+func ReadMKVSegmentHeader(r io.ReadSeeker) (*MKVSegmentHeader, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	mr := &mkvReader{br: bufio.NewReader(r)}
+
+	id, size, err := mr.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if id != mkvIDEBML || size < 0 {
+		return nil, fmt.Errorf("EBML header not found")
+	}
+
+	err = mr.skip(uint64(size))
+	if err != nil {
+		return nil, err
+	}
+
+	id, _, err = mr.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if id != mkvIDSegment {
+		return nil, fmt.Errorf("segment not found")
+	}
+
+	h := &MKVSegmentHeader{
+		Init:           &fmp4.Init{},
+		timestampScale: mkvTimestampScale,
+	}
+	var duration float64
+
+	for {
+		elemPos := mr.pos
+
+		id, size, err = mr.readHeader()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				h.clustersPos = elemPos
+				break
+			}
+			return nil, err
+		}
+
+		if id == mkvIDCluster {
+			h.clustersPos = elemPos
+			break
+		}
+
+		if size < 0 {
+			return nil, fmt.Errorf("unexpected element with unknown size")
+		}
+
+		switch id {
+		case mkvIDInfo:
+			var payload []byte
+			payload, err = mr.read(uint64(size))
+			if err != nil {
+				return nil, err
+			}
+
+			err = mkvParseChildren(payload, func(id uint32, payload []byte) error {
+				switch id {
+				case mkvIDTimestampScale:
+					h.timestampScale = int64(mkvDecodeUint(payload))
+				case mkvIDDuration:
+					duration = mkvDecodeFloat(payload)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+
+		case mkvIDTags:
+			var payload []byte
+			payload, err = mr.read(uint64(size))
+			if err != nil {
+				return nil, err
+			}
+
+			err = h.parseTags(payload)
+			if err != nil {
+				return nil, err
+			}
+
+		default:
+			err = mr.skip(uint64(size))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if h.Init.Tracks == nil {
+		return nil, fmt.Errorf("%s tag not found", mkvTagTracks)
+	}
+
+	h.Duration = time.Duration(duration * float64(h.timestampScale))
+
+	return h, nil
+}
+
+// MKVBlockInfo contains position and timing of a block of a Matroska segment.
+type MKVBlockInfo struct {
+	TrackID    int
+	PTS        time.Duration // relative to the start of the segment
+	IsKeyFrame bool
+	Offset     int64
+	Size       int64
+}
+
+// ReadBlocks reads position and timing of all blocks of the segment.
+// Reading stops at the first incomplete element, in order to support segments that have not been finalized.
+func (h *MKVSegmentHeader) ReadBlocks(r io.ReadSeeker) ([]*MKVBlockInfo, error) {
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.Seek(h.clustersPos, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	mr := &mkvReader{br: bufio.NewReader(r), pos: h.clustersPos}
+
+	var blocks []*MKVBlockInfo
+	var clusterTimestamp int64
+
+	for {
+		id, size, err := mr.readHeader()
+		if err != nil {
+			break
+		}
+
+		// clusters are entered, other elements are skipped
+		if id == mkvIDCluster {
+			continue
+		}
+
+		if size < 0 || mr.pos+size > fileSize {
+			break
+		}
+
+		switch id {
+		case mkvIDTimestamp:
+			var payload []byte
+			payload, err = mr.read(uint64(size))
+			if err != nil {
+				return blocks, nil
+			}
+			clusterTimestamp = int64(mkvDecodeUint(payload))
+
+		case mkvIDSimpleBlock:
+			dataPos := mr.pos
+
+			first, err := mr.readByte()
+			if err != nil {
+				return blocks, nil
+			}
+
+			n, err := mkvVintLength(first)
+			if err != nil {
+				return nil, err
+			}
+
+			trackID := uint64(first & (0xFF >> n))
+			rest, err := mr.read(uint64(n - 1 + 3))
+			if err != nil {
+				return blocks, nil
+			}
+
+			for _, b := range rest[:n-1] {
+				trackID = trackID<<8 | uint64(b)
+			}
+
+			relTimestamp := int16(binary.BigEndian.Uint16(rest[n-1:]))
+			flags := rest[n+1]
+
+			headerSize := mr.pos - dataPos
+
+			// lacing is not used by the recorder
+			if (flags & 0x06) == 0 {
+				blocks = append(blocks, &MKVBlockInfo{
+					TrackID:    int(trackID),
+					PTS:        time.Duration((clusterTimestamp + int64(relTimestamp)) * h.timestampScale),
+					IsKeyFrame: (flags & 0x80) != 0,
+					Offset:     mr.pos,
+					Size:       size - headerSize,
+				})
+			}
+
+			err = mr.skip(uint64(size - headerSize))
+			if err != nil {
+				return blocks, nil
+			}
+
+		default:
+			err = mr.skip(uint64(size))
+			if err != nil {
+				return blocks, nil
+			}
+		}
+	}
+
+	return blocks, nil
+}
+
+// ReadDuration returns the duration of the segment.
+// When the segment has not been finalized, duration is computed from blocks.
+func (h *MKVSegmentHeader) ReadDuration(r io.ReadSeeker) (time.Duration, error) {
+	if h.Duration != 0 {
+		return h.Duration, nil
+	}
+
+	blocks, err := h.ReadBlocks(r)
+	if err != nil {
+		return 0, err
+	}
+
+	var d time.Duration
+	for _, block := range blocks {
+		d = max(d, block.PTS)
+	}
+
+	return d, nil
+}
+
+func segmentMKVReadDuration(fpath string) (time.Duration, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h, err := ReadMKVSegmentHeader(f)
+	if err != nil {
+		return 0, err
+	}
+
+	return h.ReadDuration(f)
+}
@@ -123,10 +123,12 @@ func TestFindSegments(t *testing.T) {
 					{
 						Fpath: filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
 						Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+						Size:  1,
 					},
 					{
 						Fpath: filepath.Join(dir, "path1", "2016-05-19_22-15-25-000427.mp4"),
 						Start: time.Date(2016, 5, 19, 22, 15, 25, 427000, time.Local),
+						Size:  1,
 					},
 				}, segments)
 
@@ -135,6 +137,7 @@ func TestFindSegments(t *testing.T) {
 					{
 						Fpath: filepath.Join(dir, "path1", "2015-05-19_22-15-25-000427.mp4"),
 						Start: time.Date(2015, 5, 19, 22, 15, 25, 427000, time.Local),
+						Size:  1,
 					},
 				}, segments)
 			}
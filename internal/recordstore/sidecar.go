@@ -0,0 +1,107 @@
+package recordstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sidecar files are placed in the common path of recording segments,
+// in order to be moved and deleted together with them.
+func sidecarPath(recordPath string, pathName string, fileName string) string {
+	recordPath = strings.ReplaceAll(recordPath, "%path", pathName)
+	recordPath, _ = filepath.Abs(recordPath)
+	return filepath.Join(CommonPath(recordPath), fileName)
+}
+
+// readSidecarFile decodes a JSON sidecar file. A missing file is not an error.
+func readSidecarFile(fpath string, v any) error {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	return json.Unmarshal(byts, v)
+}
+
+// writeSidecarFile encodes a JSON sidecar file, or removes it when empty is true.
+func writeSidecarFile(fpath string, v any, empty bool) error {
+	if empty {
+		err := os.Remove(fpath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	byts, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(fpath, byts)
+}
+
+// WriteFileAtomic writes a file through a temporary file that is then renamed, in order to never leave a partial file.
+func WriteFileAtomic(fpath string, byts []byte) error {
+	tmpPath := fpath + ".tmp"
+
+	err := os.WriteFile(tmpPath, byts, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, fpath)
+}
+
+// ReadJSONLines decodes a file that contains a JSON value per line and calls cb for each value.
+// Lines that can't be decoded are skipped, since a partial line can be left by a crash.
+// The error of a missing file can be checked with os.IsNotExist.
+func ReadJSONLines[T any](fpath string, cb func(v *T)) error {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+
+	for scanner.Scan() {
+		var v T
+		err = json.Unmarshal(scanner.Bytes(), &v)
+		if err != nil {
+			continue
+		}
+
+		cb(&v)
+	}
+
+	return scanner.Err()
+}
+
+// WriteJSONLines replaces the content of a file with a JSON value per line.
+func WriteJSONLines[T any](fpath string, values []T) error {
+	var byts []byte
+
+	for _, v := range values {
+		line, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		byts = append(byts, line...)
+		byts = append(byts, '\n')
+	}
+
+	return WriteFileAtomic(fpath, byts)
+}
@@ -0,0 +1,144 @@
+package recorduploader
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+type queueOp string
+
+const (
+	queueOpAdd    queueOp = "add"
+	queueOpRemove queueOp = "remove"
+)
+
+// queueRecord is a line of the queue log.
+type queueRecord struct {
+	Op   queueOp `json:"op"`
+	Path string  `json:"path"`
+	File string  `json:"file"`
+}
+
+type queueEntry struct {
+	path string
+	file string
+
+	attempts int
+	retryAt  time.Time
+}
+
+// queue is a queue of segments to upload that is persisted on disk.
+// Operations are appended to a log, that is compacted when the queue is loaded.
+type queue struct {
+	fpath string
+
+	entries []*queueEntry
+}
+
+func (q *queue) load() error {
+	ops := 0
+
+	err := recordstore.ReadJSONLines(q.fpath, func(rec *queueRecord) {
+		q.apply(rec)
+		ops++
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if ops != len(q.entries) {
+		return q.compact()
+	}
+
+	return nil
+}
+
+func (q *queue) apply(rec *queueRecord) {
+	switch rec.Op {
+	case queueOpAdd:
+		q.entries = append(q.entries, &queueEntry{
+			path: rec.Path,
+			file: rec.File,
+		})
+
+	case queueOpRemove:
+		for i, e := range q.entries {
+			if e.file == rec.File {
+				q.entries = append(q.entries[:i], q.entries[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// compact rewrites the log with pending entries only.
+func (q *queue) compact() error {
+	recs := make([]*queueRecord, len(q.entries))
+
+	for i, e := range q.entries {
+		recs[i] = &queueRecord{
+			Op:   queueOpAdd,
+			Path: e.path,
+			File: e.file,
+		}
+	}
+
+	return recordstore.WriteJSONLines(q.fpath, recs)
+}
+
+func (q *queue) write(rec *queueRecord) error {
+	q.apply(rec)
+
+	byts, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	byts = append(byts, '\n')
+
+	err = os.MkdirAll(filepath.Dir(q.fpath), 0o755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(q.fpath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(byts)
+	return err
+}
+
+func (q *queue) push(pathName string, fpath string) error {
+	return q.write(&queueRecord{
+		Op:   queueOpAdd,
+		Path: pathName,
+		File: fpath,
+	})
+}
+
+func (q *queue) remove(e *queueEntry) error {
+	err := q.write(&queueRecord{
+		Op:   queueOpRemove,
+		Path: e.path,
+		File: e.file,
+	})
+	if err != nil {
+		return err
+	}
+
+	// do not let the log grow indefinitely
+	if len(q.entries) == 0 {
+		return q.compact()
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+// Package recorduploader contains the recording uploader.
+package recorduploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/objectstore"
+	"github.com/bluenviron/mediamtx/internal/recordstore"
+)
+
+var (
+	minRetryPause = 1 * time.Second
+	maxRetryPause = 5 * time.Minute
+)
+
+func retryPause(attempts int) time.Duration {
+	pause := minRetryPause
+	for i := 1; i < attempts && pause < maxRetryPause; i++ {
+		pause *= 2
+	}
+	return min(pause, maxRetryPause)
+}
+
+// Uploader uploads completed recording segments to an object storage.
+// Segments waiting to be uploaded are stored in a queue that survives restarts.
+type Uploader struct {
+	Client    *objectstore.Client
+	QueuePath string
+	PathConfs map[string]*conf.Path
+	Parent    logger.Writer
+
+	ctx       context.Context
+	ctxCancel func()
+	mutex     sync.Mutex
+	queue     *queue
+
+	chPush chan struct{}
+	done   chan struct{}
+}
+
+// Initialize initializes an Uploader.
+func (u *Uploader) Initialize() error {
+	u.queue = &queue{
+		fpath: u.QueuePath,
+	}
+	err := u.queue.load()
+	if err != nil {
+		return fmt.Errorf("unable to load upload queue: %w", err)
+	}
+
+	if len(u.queue.entries) != 0 {
+		u.Log(logger.Info, "%d segments are waiting to be uploaded", len(u.queue.entries))
+	}
+
+	u.ctx, u.ctxCancel = context.WithCancel(context.Background())
+	u.chPush = make(chan struct{}, 1)
+	u.done = make(chan struct{})
+
+	go u.run()
+
+	return nil
+}
+
+// Close closes the Uploader.
+// Segments that have not been uploaded yet are uploaded at next startup.
+func (u *Uploader) Close() {
+	u.ctxCancel()
+	<-u.done
+}
+
+// Log implements logger.Writer.
+func (u *Uploader) Log(level logger.Level, format string, args ...any) {
+	u.Parent.Log(level, "[record uploader] "+format, args...)
+}
+
+// ReloadPathConfs is called by core.Core.
+func (u *Uploader) ReloadPathConfs(pathConfs map[string]*conf.Path) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.PathConfs = pathConfs
+}
+
+// Push adds a completed segment to the upload queue.
+func (u *Uploader) Push(pathName string, segmentPath string) {
+	segmentPath, _ = filepath.Abs(segmentPath)
+
+	u.mutex.Lock()
+	err := u.queue.push(pathName, segmentPath)
+	u.mutex.Unlock()
+
+	if err != nil {
+		u.Log(logger.Error, "unable to add %s to the upload queue: %v", segmentPath, err)
+		return
+	}
+
+	select {
+	case u.chPush <- struct{}{}:
+	default:
+	}
+}
+
+func (u *Uploader) run() {
+	defer close(u.done)
+
+	for {
+		e, pause := u.next()
+
+		if e != nil {
+			u.process(e)
+			continue
+		}
+
+		var t *time.Timer
+		var retry <-chan time.Time
+		if pause > 0 {
+			t = time.NewTimer(pause)
+			retry = t.C
+		}
+
+		select {
+		case <-u.chPush:
+		case <-retry:
+		case <-u.ctx.Done():
+			return
+		}
+
+		if t != nil {
+			t.Stop()
+		}
+	}
+}
+
+// next returns the first entry that is ready to be uploaded,
+// or the time to wait until the next retry.
+func (u *Uploader) next() (*queueEntry, time.Duration) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	now := time.Now()
+	var pause time.Duration
+
+	for _, e := range u.queue.entries {
+		if !e.retryAt.After(now) {
+			return e, 0
+		}
+
+		if pause == 0 || e.retryAt.Sub(now) < pause {
+			pause = e.retryAt.Sub(now)
+		}
+	}
+
+	return nil, pause
+}
+
+func (u *Uploader) process(e *queueEntry) {
+	err := u.upload(e)
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if err != nil {
+		if u.ctx.Err() != nil {
+			return
+		}
+
+		e.attempts++
+		e.retryAt = time.Now().Add(retryPause(e.attempts))
+		u.Log(logger.Warn, "unable to upload %s (attempt %d): %v", e.file, e.attempts, err)
+		return
+	}
+
+	err = u.queue.remove(e)
+	if err != nil {
+		u.Log(logger.Error, "unable to update the upload queue: %v", err)
+	}
+}
+
+func (u *Uploader) upload(e *queueEntry) error {
+	u.mutex.Lock()
+	pathConf, _, err := conf.FindPathConf(u.PathConfs, e.path)
+	u.mutex.Unlock()
+
+	if err != nil || !pathConf.RecordUpload {
+		u.Log(logger.Warn, "skipping %s since upload is not enabled anymore", e.file)
+		return nil
+	}
+
+	f, err := os.Open(e.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			u.Log(logger.Warn, "skipping %s since it doesn't exist anymore", e.file)
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	key, err := recordstore.SegmentObjectKey(pathConf, e.file)
+	if err != nil {
+		return err
+	}
+
+	sum, err := u.Client.Put(u.ctx, key, f, fi.Size())
+	if err != nil {
+		return err
+	}
+
+	// verify that the object has been stored entirely.
+	// ETags of objects uploaded with a single request are the MD5 sum of their content.
+	info, err := u.Client.Head(u.ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if info.Size != fi.Size() || (info.ETag != sum && !strings.Contains(info.ETag, "-")) {
+		return fmt.Errorf("verification failed: size %d, etag %s", info.Size, info.ETag)
+	}
+
+	err = recordstore.IndexSegmentUpload(pathConf, e.path, e.file, key)
+	if err != nil {
+		return err
+	}
+
+	u.Log(logger.Debug, "uploaded %s to %s", e.file, key)
+
+	if pathConf.RecordUploadDelete {
+		err = recordstore.RemoveLocalSegment(&recordstore.Segment{Fpath: e.file})
+		if err != nil {
+			u.Log(logger.Warn, "unable to remove %s: %v", e.file, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package recorduploader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/objectstore"
+	"github.com/bluenviron/mediamtx/internal/test"
+	"github.com/stretchr/testify/require"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; !cond(); i++ {
+		require.Less(t, i, 200)
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestUploader(t *testing.T) {
+	minRetryPause = 50 * time.Millisecond
+	defer func() { minRetryPause = 1 * time.Second }()
+
+	dir, err := os.MkdirTemp("", "mediamtx-recorduploader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.Mkdir(filepath.Join(dir, "mypath"), 0o755)
+	require.NoError(t, err)
+
+	segmentPath := filepath.Join(dir, "mypath", "2008-11-07_11-22-00-500000.mp4")
+	err = os.WriteFile(segmentPath, []byte("segment content"), 0o644)
+	require.NoError(t, err)
+
+	store := test.NewObjectStore()
+	defer store.Close()
+
+	store.SetFail(true)
+
+	pathConfs := map[string]*conf.Path{
+		"mypath": {
+			Name:               "mypath",
+			RecordPath:         filepath.Join(dir, "%path/%Y-%m-%d_%H-%M-%S-%f"),
+			RecordFormat:       conf.RecordFormatFMP4,
+			RecordUpload:       true,
+			RecordUploadDelete: true,
+		},
+	}
+
+	queuePath := filepath.Join(dir, "queue.jsonl")
+
+	u := &Uploader{
+		Client: &objectstore.Client{
+			Endpoint:  store.URL(),
+			Bucket:    "mybucket",
+			Region:    "us-east-1",
+			AccessKey: "myaccess",
+			SecretKey: "mysecret",
+		},
+		QueuePath: queuePath,
+		PathConfs: pathConfs,
+		Parent:    test.NilLogger,
+	}
+	err = u.Initialize()
+	require.NoError(t, err)
+
+	u.Push("mypath", segmentPath)
+
+	waitFor(t, func() bool {
+		u.mutex.Lock()
+		defer u.mutex.Unlock()
+		return len(u.queue.entries) == 1 && u.queue.entries[0].attempts >= 1
+	})
+
+	// segment must be uploaded after a restart
+	u.Close()
+
+	store.SetFail(false)
+
+	u = &Uploader{
+		Client:    u.Client,
+		QueuePath: queuePath,
+		PathConfs: pathConfs,
+		Parent:    test.NilLogger,
+	}
+	err = u.Initialize()
+	require.NoError(t, err)
+	defer u.Close()
+
+	waitFor(t, func() bool {
+		_, ok := store.Object("mybucket/mypath/2008-11-07_11-22-00-500000.mp4")
+		return ok
+	})
+
+	waitFor(t, func() bool {
+		_, err2 := os.Stat(segmentPath)
+		return os.IsNotExist(err2)
+	})
+
+	byts, _ := store.Object("mybucket/mypath/2008-11-07_11-22-00-500000.mp4")
+	require.Equal(t, []byte("segment content"), byts)
+
+	waitFor(t, func() bool {
+		byts, err = os.ReadFile(queuePath)
+		require.NoError(t, err)
+		return len(byts) == 0
+	})
+}
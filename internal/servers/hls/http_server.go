@@ -174,6 +174,22 @@ func (s *httpServer) onRequest(ctx *gin.Context) {
 		return
 	}
 
+	// muxers are shared among readers, therefore a per-reader track selection can't be applied.
+	if ctx.Request.URL.Query().Has("tracks") {
+		s.Log(logger.Info, "connection %v used the 'tracks' parameter, that is not supported by HLS",
+			httpp.RemoteAddr(ctx))
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// readers that use a delay are served by a dedicated muxer, shared among readers with the same delay.
+	delay, err := defs.ReaderDelay(ctx.Request.URL.RawQuery)
+	if err != nil {
+		s.Log(logger.Info, "connection %v used an invalid delay: %v", httpp.RemoteAddr(ctx), err)
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	switch fname {
 	case "":
 		ctx.Header("Cache-Control", "max-age=3600")
@@ -185,6 +201,7 @@ func (s *httpServer) onRequest(ctx *gin.Context) {
 		var mux *muxer
 		mux, err = s.parent.getMuxer(serverGetMuxerReq{
 			path:           dir,
+			delay:          delay,
 			remoteAddr:     httpp.RemoteAddr(ctx),
 			query:          ctx.Request.URL.RawQuery,
 			sourceOnDemand: pathConf.SourceOnDemand,
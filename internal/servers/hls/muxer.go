@@ -47,6 +47,7 @@ type muxerGetInstanceReq struct {
 
 type muxer struct {
 	parentCtx       context.Context
+	delay           time.Duration
 	remoteAddr      string
 	variant         conf.HLSVariant
 	segmentCount    int
@@ -67,6 +68,7 @@ type muxer struct {
 	path            defs.Path
 	lastRequestTime *int64
 	bytesSent       *uint64
+	readerSkips     *uint64
 
 	// in
 	chGetInstance chan muxerGetInstanceReq
@@ -80,6 +82,7 @@ func (m *muxer) initialize() {
 	m.created = time.Now()
 	m.lastRequestTime = ptrOf(time.Now().UnixNano())
 	m.bytesSent = new(uint64)
+	m.readerSkips = new(uint64)
 	m.chGetInstance = make(chan muxerGetInstanceReq)
 
 	m.Log(logger.Info, "created %s", func() string {
@@ -99,6 +102,10 @@ func (m *muxer) Close() {
 
 // Log implements logger.Writer.
 func (m *muxer) Log(level logger.Level, format string, args ...any) {
+	if m.delay > 0 {
+		m.parent.Log(level, "[muxer %s, delay %v] "+format, append([]any{m.pathName, m.delay}, args...)...)
+		return
+	}
 	m.parent.Log(level, "[muxer %s] "+format, append([]any{m.pathName}, args...)...)
 }
 
@@ -136,6 +143,13 @@ func (m *muxer) runInner() error {
 
 	defer m.path.RemoveReader(defs.PathRemoveReaderReq{Author: m})
 
+	// the muxer is shared among readers, therefore tracks are selected
+	// by the path configuration only.
+	desc, err := defs.ReaderDesc(stream.Desc, "", path.SafeConf())
+	if err != nil {
+		return err
+	}
+
 	var instanceError chan error
 	var recreateTimer *time.Timer
 
@@ -147,8 +161,11 @@ func (m *muxer) runInner() error {
 		segmentMaxSize:  m.segmentMaxSize,
 		directory:       m.directory,
 		pathName:        m.pathName,
+		delay:           m.delay,
+		desc:            desc,
 		stream:          stream,
 		bytesSent:       m.bytesSent,
+		readerSkips:     m.readerSkips,
 		parent:          m,
 	}
 	err = mi.initialize()
@@ -204,8 +221,11 @@ func (m *muxer) runInner() error {
 				segmentMaxSize:  m.segmentMaxSize,
 				directory:       m.directory,
 				pathName:        m.pathName,
+				delay:           m.delay,
+				desc:            desc,
 				stream:          stream,
 				bytesSent:       m.bytesSent,
+				readerSkips:     m.readerSkips,
 				parent:          m,
 			}
 			err = mi.initialize()
@@ -252,9 +272,15 @@ func (m *muxer) APIReaderDescribe() defs.APIPathSourceOrReader {
 	}
 }
 
+// APIReaderSkips implements defs.ReaderWithSkips.
+func (m *muxer) APIReaderSkips() uint64 {
+	return atomic.LoadUint64(m.readerSkips)
+}
+
 func (m *muxer) apiItem() *defs.APIHLSMuxer {
 	return &defs.APIHLSMuxer{
 		Path:        m.pathName,
+		Delay:       conf.Duration(m.delay),
 		Created:     m.created,
 		LastRequest: time.Unix(0, atomic.LoadInt64(m.lastRequestTime)),
 		BytesSent:   atomic.LoadUint64(m.bytesSent),
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/bluenviron/gohlslib/v2"
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
@@ -22,8 +23,11 @@ type muxerInstance struct {
 	segmentMaxSize  conf.StringSize
 	directory       string
 	pathName        string
+	delay           time.Duration
+	desc            *description.Session
 	stream          *stream.Stream
 	bytesSent       *uint64
+	readerSkips     *uint64
 	parent          logger.Writer
 
 	hmuxer *gohlslib.Muxer
@@ -34,6 +38,9 @@ func (mi *muxerInstance) initialize() error {
 	var muxerDirectory string
 	if mi.directory != "" {
 		muxerDirectory = filepath.Join(mi.directory, mi.pathName)
+		if mi.delay > 0 {
+			muxerDirectory = filepath.Join(muxerDirectory, "delay_"+mi.delay.String())
+		}
 		os.MkdirAll(muxerDirectory, 0o755)
 	}
 
@@ -50,11 +57,13 @@ func (mi *muxerInstance) initialize() error {
 	}
 
 	mi.reader = &stream.Reader{
+		Delay:         mi.delay,
 		SkipBytesSent: true,
+		Skips:         mi.readerSkips,
 		Parent:        mi,
 	}
 
-	err := hls.FromStream(mi.stream.Desc, mi.reader, mi.hmuxer)
+	err := hls.FromStream(mi.desc, defs.MediaNames(mi.stream.Desc, mi.desc), mi.reader, mi.hmuxer)
 	if err != nil {
 		return err
 	}
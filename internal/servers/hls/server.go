@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/defs"
@@ -27,8 +28,15 @@ type serverGetMuxerRes struct {
 	err   error
 }
 
+// muxers are identified by path and delay, since readers that use a delay need a dedicated muxer.
+type serverMuxerKey struct {
+	path  string
+	delay time.Duration
+}
+
 type serverGetMuxerReq struct {
 	path           string
+	delay          time.Duration
 	remoteAddr     string
 	query          string
 	sourceOnDemand bool
@@ -94,7 +102,7 @@ type Server struct {
 	ctxCancel  func()
 	wg         sync.WaitGroup
 	httpServer *httpServer
-	muxers     map[string]*muxer
+	muxers     map[serverMuxerKey]*muxer
 
 	// in
 	chPathReady    chan defs.Path
@@ -111,7 +119,7 @@ func (s *Server) Initialize() error {
 
 	s.ctx = ctx
 	s.ctxCancel = ctxCancel
-	s.muxers = make(map[string]*muxer)
+	s.muxers = make(map[serverMuxerKey]*muxer)
 	s.chPathReady = make(chan defs.Path)
 	s.chPathNotReady = make(chan defs.Path)
 	s.chGetMuxer = make(chan serverGetMuxerReq)
@@ -175,8 +183,8 @@ func (s *Server) run() {
 	if s.AlwaysRemux {
 		for _, pa := range readyPaths {
 			if !pa.SafeConf().SourceOnDemand {
-				if _, ok := s.muxers[pa.Name()]; !ok {
-					s.createMuxer(pa.Name(), "", "")
+				if _, ok := s.muxers[serverMuxerKey{path: pa.Name()}]; !ok {
+					s.createMuxer(pa.Name(), 0, "", "")
 				}
 			}
 		}
@@ -187,32 +195,34 @@ outer:
 		select {
 		case pa := <-s.chPathReady:
 			if s.AlwaysRemux && !pa.SafeConf().SourceOnDemand {
-				if _, ok := s.muxers[pa.Name()]; !ok {
-					s.createMuxer(pa.Name(), "", "")
+				if _, ok := s.muxers[serverMuxerKey{path: pa.Name()}]; !ok {
+					s.createMuxer(pa.Name(), 0, "", "")
 				}
 			}
 
 		case pa := <-s.chPathNotReady:
-			c, ok := s.muxers[pa.Name()]
+			key := serverMuxerKey{path: pa.Name()}
+			c, ok := s.muxers[key]
 			if ok && c.remoteAddr == "" { // created with "always remux"
 				c.Close()
-				delete(s.muxers, pa.Name())
+				delete(s.muxers, key)
 			}
 
 		case req := <-s.chGetMuxer:
-			mux, ok := s.muxers[req.path]
+			mux, ok := s.muxers[serverMuxerKey{path: req.path, delay: req.delay}]
 			switch {
 			case ok:
 				req.res <- serverGetMuxerRes{muxer: mux}
-			case s.AlwaysRemux && !req.sourceOnDemand:
+			case s.AlwaysRemux && !req.sourceOnDemand && req.delay == 0:
 				req.res <- serverGetMuxerRes{err: fmt.Errorf("muxer is waiting to be created")}
 			default:
-				req.res <- serverGetMuxerRes{muxer: s.createMuxer(req.path, req.remoteAddr, req.query)}
+				req.res <- serverGetMuxerRes{muxer: s.createMuxer(req.path, req.delay, req.remoteAddr, req.query)}
 			}
 
 		case c := <-s.chCloseMuxer:
-			if c2, ok := s.muxers[c.PathName()]; ok && c2 == c {
-				delete(s.muxers, c.PathName())
+			key := serverMuxerKey{path: c.PathName(), delay: c.delay}
+			if c2, ok := s.muxers[key]; ok && c2 == c {
+				delete(s.muxers, key)
 			}
 
 		case req := <-s.chAPIMuxerList:
@@ -233,7 +243,7 @@ outer:
 			}
 
 		case req := <-s.chAPIMuxerGet:
-			muxer, ok := s.muxers[req.name]
+			muxer, ok := s.muxers[serverMuxerKey{path: req.name}]
 			if !ok {
 				req.res <- serverAPIMuxersGetRes{err: ErrMuxerNotFound}
 				continue
@@ -251,9 +261,10 @@ outer:
 	s.httpServer.close()
 }
 
-func (s *Server) createMuxer(pathName string, remoteAddr string, query string) *muxer {
+func (s *Server) createMuxer(pathName string, delay time.Duration, remoteAddr string, query string) *muxer {
 	r := &muxer{
 		parentCtx:       s.ctx,
+		delay:           delay,
 		remoteAddr:      remoteAddr,
 		variant:         s.Variant,
 		segmentCount:    s.SegmentCount,
@@ -269,7 +280,7 @@ func (s *Server) createMuxer(pathName string, remoteAddr string, query string) *
 		closeAfter:      s.MuxerCloseAfter,
 	}
 	r.initialize()
-	s.muxers[pathName] = r
+	s.muxers[serverMuxerKey{path: pathName, delay: delay}] = r
 	return r
 }
 
@@ -173,6 +173,49 @@ func TestServerNotFound(t *testing.T) {
 	}
 }
 
+func TestServerUnsupportedQuery(t *testing.T) {
+	pm := &dummyPathManager{
+		findPathConfImpl: func(_ defs.PathFindPathConfReq) (*conf.Path, error) {
+			return &conf.Path{}, nil
+		},
+		addReaderImpl: func(_ defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+			t.Errorf("should not happen")
+			return nil, nil, fmt.Errorf("unexpected")
+		},
+	}
+
+	s := &Server{
+		Address:         "127.0.0.1:8888",
+		Variant:         conf.HLSVariant(gohlslib.MuxerVariantMPEGTS),
+		SegmentCount:    7,
+		SegmentDuration: conf.Duration(1 * time.Second),
+		PartDuration:    conf.Duration(200 * time.Millisecond),
+		SegmentMaxSize:  50 * 1024 * 1024,
+		TrustedProxies:  conf.IPNetworks{},
+		ReadTimeout:     conf.Duration(10 * time.Second),
+		WriteTimeout:    conf.Duration(10 * time.Second),
+		PathManager:     pm,
+		Parent:          test.NilLogger,
+	}
+	err := s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr}
+
+	for _, query := range []string{"delay=abc", "tracks=video"} {
+		func() {
+			res, err2 := hc.Get("http://127.0.0.1:8888/teststream/index.m3u8?" + query)
+			require.NoError(t, err2)
+			defer res.Body.Close()
+
+			require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		}()
+	}
+}
+
 func TestServerRead(t *testing.T) {
 	for _, ca := range []string{
 		"always remux off",
@@ -398,6 +441,73 @@ func TestServerRead(t *testing.T) {
 	}
 }
 
+func TestServerReadDelay(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{test.MediaH264}}
+
+	strm := &stream.Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		TimeShift:          true,
+		TimeShiftDuration:  10 * time.Second,
+		TimeShiftMaxSize:   1024 * 1024,
+		Parent:             test.NilLogger,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+
+	queries := make(chan string, 2)
+
+	pm := &dummyPathManager{
+		findPathConfImpl: func(_ defs.PathFindPathConfReq) (*conf.Path, error) {
+			return &conf.Path{}, nil
+		},
+		addReaderImpl: func(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+			queries <- req.AccessRequest.Query
+			return &dummyPath{}, strm, nil
+		},
+	}
+
+	s := &Server{
+		Address:         "127.0.0.1:8888",
+		Variant:         conf.HLSVariant(gohlslib.MuxerVariantMPEGTS),
+		SegmentCount:    7,
+		SegmentDuration: conf.Duration(1 * time.Second),
+		PartDuration:    conf.Duration(200 * time.Millisecond),
+		SegmentMaxSize:  50 * 1024 * 1024,
+		TrustedProxies:  conf.IPNetworks{},
+		ReadTimeout:     conf.Duration(10 * time.Second),
+		WriteTimeout:    conf.Duration(10 * time.Second),
+		MuxerCloseAfter: conf.Duration(60 * time.Second),
+		PathManager:     pm,
+		Parent:          test.NilLogger,
+	}
+	err = s.Initialize()
+	require.NoError(t, err)
+	defer s.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+	hc := &http.Client{Transport: tr, Timeout: 500 * time.Millisecond}
+
+	for _, query := range []string{"", "delay=1s", "delay=1s"} {
+		res, err2 := hc.Get("http://127.0.0.1:8888/teststream/index.m3u8?" + query)
+		if err2 == nil {
+			res.Body.Close()
+		}
+	}
+
+	require.Equal(t, "", <-queries)
+	require.Equal(t, "delay=1s", <-queries)
+
+	list, err := s.APIMuxersList()
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	require.Equal(t, conf.Duration(0), list.Items[0].Delay)
+	require.Equal(t, conf.Duration(1*time.Second), list.Items[1].Delay)
+}
+
 func TestServerDirectory(t *testing.T) {
 	dir, err := os.MkdirTemp("", "mediamtx-playback")
 	require.NoError(t, err)
@@ -7,6 +7,7 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/gortmplib"
@@ -38,15 +39,16 @@ type conn struct {
 	pathManager         serverPathManager
 	parent              *Server
 
-	ctx       context.Context
-	ctxCancel func()
-	uuid      uuid.UUID
-	created   time.Time
-	mutex     sync.RWMutex
-	rconn     *gortmplib.ServerConn
-	state     defs.APIRTMPConnState
-	pathName  string
-	query     string
+	ctx         context.Context
+	ctxCancel   func()
+	uuid        uuid.UUID
+	created     time.Time
+	readerSkips *uint64
+	mutex       sync.RWMutex
+	rconn       *gortmplib.ServerConn
+	state       defs.APIRTMPConnState
+	pathName    string
+	query       string
 }
 
 func (c *conn) initialize() {
@@ -54,6 +56,7 @@ func (c *conn) initialize() {
 
 	c.uuid = uuid.New()
 	c.created = time.Now()
+	c.readerSkips = new(uint64)
 	c.state = defs.APIRTMPConnStateIdle
 
 	c.Log(logger.Info, "opened")
@@ -183,9 +186,22 @@ func (c *conn) runRead() error {
 	c.query = c.rconn.URL.RawQuery
 	c.mutex.Unlock()
 
-	r := &stream.Reader{Parent: c}
+	// delay has already been validated by the path
+	delay, _ := defs.ReaderDelay(c.rconn.URL.RawQuery)
 
-	err = rtmp.FromStream(strm.Desc, r, c.rconn, c.nconn, time.Duration(c.writeTimeout))
+	r := &stream.Reader{
+		Delay:  delay,
+		Skips:  c.readerSkips,
+		Parent: c,
+	}
+
+	// tracks have been validated by the path, but the configuration may have been reloaded since then
+	desc, err := defs.ReaderDesc(strm.Desc, c.rconn.URL.RawQuery, path.SafeConf())
+	if err != nil {
+		return err
+	}
+
+	err = rtmp.FromStream(desc, r, c.rconn, c.nconn, time.Duration(c.writeTimeout))
 	if err != nil {
 		return err
 	}
@@ -297,6 +313,11 @@ func (c *conn) APIReaderDescribe() defs.APIPathSourceOrReader {
 	}
 }
 
+// APIReaderSkips implements defs.ReaderWithSkips.
+func (c *conn) APIReaderSkips() uint64 {
+	return atomic.LoadUint64(c.readerSkips)
+}
+
 // APISourceDescribe implements source.
 func (c *conn) APISourceDescribe() defs.APIPathSourceOrReader {
 	return c.APIReaderDescribe()
@@ -70,6 +70,7 @@ type conn struct {
 	uuid             uuid.UUID
 	created          time.Time
 	onDisconnectHook func()
+	describeStream   *gortsplib.ServerStream
 }
 
 func (c *conn) initialize() {
@@ -121,6 +122,10 @@ func (c *conn) ip() net.IP {
 func (c *conn) onClose(err error) {
 	c.Log(logger.Info, "closed: %v", err)
 
+	if c.describeStream != nil {
+		c.describeStream.Close()
+	}
+
 	c.onDisconnectHook()
 }
 
@@ -193,6 +198,37 @@ func (c *conn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 		}, nil, nil
 	}
 
+	desc, err := defs.ReaderDesc(res.Stream.Desc, ctx.Query, res.Path.SafeConf())
+	if err != nil {
+		return &base.Response{
+			StatusCode: base.StatusBadRequest,
+		}, nil, err
+	}
+
+	// when tracks are selected, the description is generated by a dedicated stream,
+	// that is kept until the next request since it is used after the response is returned.
+	if desc != res.Stream.Desc {
+		if c.describeStream != nil {
+			c.describeStream.Close()
+		}
+
+		c.describeStream = &gortsplib.ServerStream{
+			Server: c.rserver,
+			Desc:   desc,
+		}
+		err = c.describeStream.Initialize()
+		if err != nil {
+			c.describeStream = nil
+			return &base.Response{
+				StatusCode: base.StatusInternalServerError,
+			}, nil, err
+		}
+
+		return &base.Response{
+			StatusCode: base.StatusOK,
+		}, c.describeStream, nil
+	}
+
 	var stream *gortsplib.ServerStream
 	if !c.isTLS {
 		stream = res.Stream.RTSPStream(c.rserver)
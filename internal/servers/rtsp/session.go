@@ -23,6 +23,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/protocols/rtsp"
 	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
 )
 
 func profileLabel(p headers.TransportProfile) string {
@@ -54,6 +55,9 @@ type session struct {
 	packetsLost     *counterdumper.CounterDumper
 	decodeErrors    *counterdumper.CounterDumper
 	discardedFrames *counterdumper.CounterDumper
+	delay           time.Duration
+	dedicatedStream *gortsplib.ServerStream
+	dedicatedReader *stream.Reader
 }
 
 func (s *session) initialize() {
@@ -128,6 +132,16 @@ func (s *session) onClose(err error) {
 		s.onUnreadHook()
 	}
 
+	if s.dedicatedReader != nil {
+		s.stream.RemoveReader(s.dedicatedReader)
+		s.dedicatedReader = nil
+	}
+
+	if s.dedicatedStream != nil {
+		s.dedicatedStream.Close()
+		s.dedicatedStream = nil
+	}
+
 	switch s.rsession.State() {
 	case gortsplib.ServerSessionStatePrePlay, gortsplib.ServerSessionStatePlay:
 		s.path.RemoveReader(defs.PathRemoveReaderReq{Author: s})
@@ -261,6 +275,38 @@ func (s *session) onSetup(c *conn, ctx *gortsplib.ServerHandlerOnSetupCtx,
 		s.path = path
 		s.stream = stream
 
+		// delay has already been validated by the path
+		s.delay, _ = defs.ReaderDelay(ctx.Query)
+
+		// tracks have been validated by the path, but the configuration may have been reloaded since then
+		desc, err := defs.ReaderDesc(stream.Desc, ctx.Query, path.SafeConf())
+		if err != nil {
+			return &base.Response{
+				StatusCode: base.StatusBadRequest,
+			}, nil, err
+		}
+
+		// delayed sessions and sessions with selected tracks are fed by a dedicated stream
+		if s.delay > 0 || desc != stream.Desc {
+			if s.dedicatedStream == nil {
+				s.dedicatedStream = &gortsplib.ServerStream{
+					Server: s.rserver,
+					Desc:   desc,
+				}
+				err = s.dedicatedStream.Initialize()
+				if err != nil {
+					s.dedicatedStream = nil
+					return &base.Response{
+						StatusCode: base.StatusInternalServerError,
+					}, nil, err
+				}
+			}
+
+			return &base.Response{
+				StatusCode: base.StatusOK,
+			}, s.dedicatedStream, nil
+		}
+
 		var rstream *gortsplib.ServerStream
 		if !s.isTLS {
 			rstream = stream.RTSPStream(s.rserver)
@@ -297,6 +343,10 @@ func (s *session) onPlay(_ *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, e
 			Reader:          s.APIReaderDescribe(),
 			Query:           s.rsession.Query(),
 		})
+
+		if s.dedicatedStream != nil && s.dedicatedReader == nil {
+			s.startDedicatedReader()
+		}
 	}
 
 	return &base.Response{
@@ -305,6 +355,27 @@ func (s *session) onPlay(_ *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, e
 	}, nil
 }
 
+func (s *session) startDedicatedReader() {
+	s.dedicatedReader = &stream.Reader{
+		Delay:  s.delay,
+		Parent: s,
+	}
+
+	for _, medi := range s.rsession.Medias() {
+		for _, forma := range medi.Formats {
+			s.dedicatedReader.OnData(medi, forma, func(u *unit.Unit) error {
+				// packets are shared with other readers and are modified by the RTSP stream
+				for _, pkt := range u.RTPPackets {
+					s.dedicatedStream.WritePacketRTPWithNTP(medi, pkt.Clone(), u.NTP) //nolint:errcheck
+				}
+				return nil
+			})
+		}
+	}
+
+	s.stream.AddReader(s.dedicatedReader)
+}
+
 // onRecord is called by rtspServer.
 func (s *session) onRecord(_ *gortsplib.ServerHandlerOnRecordCtx) (*base.Response, error) {
 	path, stream, err := s.pathManager.AddPublisher(defs.PathAddPublisherReq{
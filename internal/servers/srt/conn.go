@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
@@ -56,15 +57,16 @@ type conn struct {
 	pathManager         serverPathManager
 	parent              *Server
 
-	ctx       context.Context
-	ctxCancel func()
-	created   time.Time
-	uuid      uuid.UUID
-	mutex     sync.RWMutex
-	state     defs.APISRTConnState
-	pathName  string
-	query     string
-	sconn     srt.Conn
+	ctx         context.Context
+	ctxCancel   func()
+	created     time.Time
+	readerSkips *uint64
+	uuid        uuid.UUID
+	mutex       sync.RWMutex
+	state       defs.APISRTConnState
+	pathName    string
+	query       string
+	sconn       srt.Conn
 }
 
 func (c *conn) initialize() {
@@ -72,6 +74,7 @@ func (c *conn) initialize() {
 
 	c.created = time.Now()
 	c.uuid = uuid.New()
+	c.readerSkips = new(uint64)
 	c.state = defs.APISRTConnStateIdle
 
 	c.Log(logger.Info, "opened")
@@ -290,6 +293,13 @@ func (c *conn) runRead(streamID *streamID) error {
 		return err
 	}
 
+	// tracks have been validated by the path, but the configuration may have been reloaded since then
+	desc, err := defs.ReaderDesc(strm.Desc, streamID.query, path.SafeConf())
+	if err != nil {
+		c.connReq.Reject(srt.REJ_PEER)
+		return err
+	}
+
 	sconn, err := c.connReq.Accept()
 	if err != nil {
 		return err
@@ -298,9 +308,16 @@ func (c *conn) runRead(streamID *streamID) error {
 
 	bw := bufio.NewWriterSize(sconn, srtMaxPayloadSize(c.udpMaxPayloadSize))
 
-	r := &stream.Reader{Parent: c}
+	// delay has already been validated by the path
+	delay, _ := defs.ReaderDelay(streamID.query)
 
-	err = mpegts.FromStream(strm.Desc, r, bw, sconn, time.Duration(c.writeTimeout))
+	r := &stream.Reader{
+		Delay:  delay,
+		Skips:  c.readerSkips,
+		Parent: c,
+	}
+
+	err = mpegts.FromStream(desc, r, bw, sconn, time.Duration(c.writeTimeout))
 	if err != nil {
 		return err
 	}
@@ -348,6 +365,11 @@ func (c *conn) APIReaderDescribe() defs.APIPathSourceOrReader {
 	}
 }
 
+// APIReaderSkips implements defs.ReaderWithSkips.
+func (c *conn) APIReaderSkips() uint64 {
+	return atomic.LoadUint64(c.readerSkips)
+}
+
 // APISourceDescribe implements source.
 func (c *conn) APISourceDescribe() defs.APIPathSourceOrReader {
 	return c.APIReaderDescribe()
@@ -8,6 +8,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
@@ -57,13 +58,14 @@ type session struct {
 	pathManager           serverPathManager
 	parent                sessionParent
 
-	ctx       context.Context
-	ctxCancel func()
-	created   time.Time
-	uuid      uuid.UUID
-	secret    uuid.UUID
-	mutex     sync.RWMutex
-	pc        *webrtc.PeerConnection
+	ctx         context.Context
+	ctxCancel   func()
+	created     time.Time
+	readerSkips *uint64
+	uuid        uuid.UUID
+	secret      uuid.UUID
+	mutex       sync.RWMutex
+	pc          *webrtc.PeerConnection
 
 	chNew           chan webRTCNewSessionReq
 	chAddCandidates chan webRTCAddSessionCandidatesReq
@@ -76,6 +78,7 @@ func (s *session) initialize() {
 	s.ctxCancel = ctxCancel
 	s.created = time.Now()
 	s.uuid = uuid.New()
+	s.readerSkips = new(uint64)
 	s.secret = uuid.New()
 	s.chNew = make(chan webRTCNewSessionReq)
 	s.chAddCandidates = make(chan webRTCAddSessionCandidatesReq)
@@ -318,9 +321,22 @@ func (s *session) runRead() (int, error) {
 		Log:                   s,
 	}
 
-	r := &stream.Reader{Parent: s}
+	// delay has already been validated by the path
+	delay, _ := defs.ReaderDelay(req.Query)
 
-	err = webrtc.FromStream(strm.Desc, r, pc)
+	r := &stream.Reader{
+		Delay:  delay,
+		Skips:  s.readerSkips,
+		Parent: s,
+	}
+
+	// tracks have been validated by the path, but the configuration may have been reloaded since then
+	desc, err := defs.ReaderDesc(strm.Desc, req.Query, path.SafeConf())
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	err = webrtc.FromStream(desc, defs.MediaNames(strm.Desc, desc), r, pc)
 	if err != nil {
 		return http.StatusBadRequest, err
 	}
@@ -450,6 +466,11 @@ func (s *session) APIReaderDescribe() defs.APIPathSourceOrReader {
 	}
 }
 
+// APIReaderSkips implements defs.ReaderWithSkips.
+func (s *session) APIReaderSkips() uint64 {
+	return atomic.LoadUint64(s.readerSkips)
+}
+
 // APISourceDescribe implements source.
 func (s *session) APISourceDescribe() defs.APIPathSourceOrReader {
 	return s.APIReaderDescribe()
@@ -0,0 +1,341 @@
+// Package composite contains the composite static source.
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+
+	"github.com/bluenviron/mediamtx/internal/conf"
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	pauseBetweenErrors = 1 * time.Second
+)
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+func durationToTimestamp(d time.Duration, clockRate int) int64 {
+	return multiplyAndDivide(int64(d), int64(clockRate), int64(time.Second))
+}
+
+func timestampToDuration(t int64, clockRate int) time.Duration {
+	return time.Duration(multiplyAndDivide(t, int64(time.Second), int64(clockRate)))
+}
+
+type inputReader struct {
+	ctx       context.Context
+	ctxCancel func()
+}
+
+// Close implements reader.
+func (r *inputReader) Close() {
+	r.ctxCancel()
+}
+
+// APIReaderDescribe implements reader.
+func (*inputReader) APIReaderDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "composite",
+		ID:   "",
+	}
+}
+
+// input is a connection to one of the input paths.
+type input struct {
+	conf   conf.CompositeInput
+	path   defs.Path
+	stream *stream.Stream
+	author *inputReader
+	reader *stream.Reader
+	medias []*description.Media
+
+	// timestamps of all tracks of an input are aligned with the first received unit
+	mutex       sync.Mutex
+	hasAnchor   bool
+	anchorRef   time.Time
+	anchorPTS   time.Duration
+	initialized bool
+}
+
+func (in *input) close() {
+	if in.initialized {
+		in.stream.RemoveReader(in.reader)
+	}
+	in.path.RemoveReader(defs.PathRemoveReaderReq{Author: in.author})
+	in.author.ctxCancel()
+}
+
+// selectMedias returns medias of the input that have one of the requested types.
+func (in *input) selectMedias() []*description.Media {
+	var out []*description.Media
+
+	for _, media := range in.stream.Desc.Medias {
+		if len(in.conf.Medias) == 0 || slices.Contains(in.conf.Medias, string(media.Type)) {
+			out = append(out, media)
+		}
+	}
+
+	return out
+}
+
+type parent interface {
+	logger.Writer
+	SetReady(req defs.PathSourceStaticSetReadyReq) defs.PathSourceStaticSetReadyRes
+	SetNotReady(req defs.PathSourceStaticSetNotReadyReq)
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
+// Source is a composite static source.
+// It reads selected medias from multiple paths and merges them into a single stream.
+type Source struct {
+	Inputs     []conf.CompositeInput
+	Timestamps conf.CompositeTimestamps
+	Parent     parent
+}
+
+// Log implements logger.Writer.
+func (s *Source) Log(level logger.Level, format string, args ...any) {
+	s.Parent.Log(level, "[composite source] "+format, args...)
+}
+
+// Run implements StaticSource.
+func (s *Source) Run(params defs.StaticSourceRunParams) error {
+	inputs, err := s.waitForInputs(params.Context)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		for _, in := range inputs {
+			in.close()
+		}
+	}()
+
+	var medias []*description.Media
+
+	for _, in := range inputs {
+		for _, media := range in.medias {
+			medias = append(medias, &description.Media{
+				Type:    media.Type,
+				Formats: media.Formats,
+			})
+		}
+	}
+
+	// timestamps are relative to start, that is moved back by negative offsets
+	// in order to avoid negative timestamps.
+	var minOffset time.Duration
+	for _, in := range inputs {
+		minOffset = min(minOffset, time.Duration(in.conf.Offset))
+	}
+	start := time.Now().Add(minOffset)
+
+	res := s.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
+		Desc:               &description.Session{Medias: medias},
+		GenerateRTPPackets: true,
+		FillNTP:            false,
+	})
+	if res.Err != nil {
+		return res.Err
+	}
+
+	defer s.Parent.SetNotReady(defs.PathSourceStaticSetNotReadyReq{})
+
+	i := 0
+
+	for _, in := range inputs {
+		for _, media := range in.medias {
+			s.setupMedia(in, media, medias[i], res.Stream, start)
+			i++
+		}
+
+		in.stream.AddReader(in.reader)
+		in.initialized = true
+	}
+
+	readerErr := make(chan error)
+	done := make(chan struct{})
+	defer close(done)
+
+	for _, in := range inputs {
+		go func() {
+			select {
+			case err := <-in.reader.Error():
+				select {
+				case readerErr <- fmt.Errorf("input '%s': %w", in.conf.Path, err):
+				case <-done:
+				}
+
+			case <-in.author.ctx.Done():
+				select {
+				case readerErr <- fmt.Errorf("input '%s' closed", in.conf.Path):
+				case <-done:
+				}
+
+			case <-done:
+			}
+		}()
+	}
+
+	select {
+	case err := <-readerErr:
+		return err
+
+	case <-params.Context.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// waitForInputs waits until all inputs are available.
+func (s *Source) waitForInputs(ctx context.Context) ([]*input, error) {
+	for {
+		inputs, err := s.openInputs()
+		if err == nil {
+			return inputs, nil
+		}
+
+		var err2 defs.PathNoStreamAvailableError
+		if !errors.As(err, &err2) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(pauseBetweenErrors):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("terminated")
+		}
+	}
+}
+
+func (s *Source) openInputs() ([]*input, error) {
+	inputs := make([]*input, 0, len(s.Inputs))
+
+	for _, inConf := range s.Inputs {
+		in, err := s.openInput(inConf)
+		if err != nil {
+			for _, in := range inputs {
+				in.close()
+			}
+			return nil, err
+		}
+
+		inputs = append(inputs, in)
+	}
+
+	return inputs, nil
+}
+
+func (s *Source) openInput(inConf conf.CompositeInput) (*input, error) {
+	author := &inputReader{}
+	author.ctx, author.ctxCancel = context.WithCancel(context.Background())
+
+	path, strm, err := s.Parent.AddReader(defs.PathAddReaderReq{
+		Author: author,
+		AccessRequest: defs.PathAccessRequest{
+			Name:     inConf.Path,
+			SkipAuth: true,
+		},
+	})
+	if err != nil {
+		author.ctxCancel()
+		return nil, err
+	}
+
+	in := &input{
+		conf:   inConf,
+		path:   path,
+		stream: strm,
+		author: author,
+		reader: &stream.Reader{
+			SkipBytesSent: true,
+			Parent:        s,
+		},
+	}
+
+	in.medias = in.selectMedias()
+	if len(in.medias) == 0 {
+		in.close()
+		return nil, fmt.Errorf("input '%s' has no medias of the requested types (%s)",
+			inConf.Path, defs.MediasInfo(strm.Desc.Medias))
+	}
+
+	return in, nil
+}
+
+// setupMedia routes a media of an input into a media of the composite stream.
+func (s *Source) setupMedia(
+	in *input,
+	inMedia *description.Media,
+	outMedia *description.Media,
+	out *stream.Stream,
+	start time.Time,
+) {
+	for i, inFormat := range inMedia.Formats {
+		outFormat := outMedia.Formats[i]
+
+		in.reader.OnData(inMedia, inFormat, func(u *unit.Unit) error {
+			if u.NilPayload() {
+				return nil
+			}
+
+			pts := s.convertTimestamp(in, inFormat, u, start)
+
+			out.WriteUnit(outMedia, outFormat, &unit.Unit{
+				PTS:     durationToTimestamp(pts, outFormat.ClockRate()),
+				NTP:     start.Add(pts),
+				Payload: u.Payload,
+			})
+
+			return nil
+		})
+	}
+}
+
+// convertTimestamp converts the timestamp of a unit of an input
+// into a timestamp of the composite stream, relative to its start.
+func (s *Source) convertTimestamp(
+	in *input,
+	forma format.Format,
+	u *unit.Unit,
+	start time.Time,
+) time.Duration {
+	pts := timestampToDuration(u.PTS, forma.ClockRate())
+
+	in.mutex.Lock()
+	defer in.mutex.Unlock()
+
+	if !in.hasAnchor {
+		if s.Timestamps == conf.CompositeTimestampsReceiveTime || u.NTP.IsZero() {
+			in.anchorRef = time.Now()
+		} else {
+			in.anchorRef = u.NTP
+		}
+		in.anchorPTS = pts
+		in.hasAnchor = true
+	}
+
+	return in.anchorRef.Sub(start) + time.Duration(in.conf.Offset) + (pts - in.anchorPTS)
+}
+
+// APISourceDescribe implements StaticSource.
+func (*Source) APISourceDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "compositeSource",
+		ID:   "",
+	}
+}
@@ -0,0 +1,207 @@
+package staticsources
+
+import (
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
+)
+
+const (
+	failoverHistorySize = 50
+	failoverStallPeriod = 1 * time.Second
+)
+
+var errProbeSucceeded = errors.New("probe succeeded")
+
+// redactSource removes credentials from a source URL.
+func redactSource(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	return u.Redacted()
+}
+
+type failoverSource struct {
+	url           string
+	healthy       bool
+	failures      uint64
+	lastError     string
+	lastErrorTime time.Time
+	lastReadyTime time.Time
+}
+
+type failoverEvent struct {
+	time   time.Time
+	from   int
+	to     int
+	reason string
+}
+
+// failover contains the state of sources of a path with multiple sources.
+// Sources are sorted by priority, the first one is the primary source.
+type failover struct {
+	mutex        sync.RWMutex
+	sources      []*failoverSource
+	active       int
+	failedInARow int
+	history      []failoverEvent
+}
+
+func newFailover(sources []string) *failover {
+	f := &failover{}
+	for _, src := range sources {
+		f.sources = append(f.sources, &failoverSource{url: src})
+	}
+	return f
+}
+
+func (f *failover) activeSource() int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.active
+}
+
+// setReady is called when the active source becomes ready.
+func (f *failover) setReady() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	src := f.sources[f.active]
+	src.healthy = true
+	src.lastReadyTime = time.Now()
+	f.failedInARow = 0
+}
+
+// setFailed is called when a source fails.
+func (f *failover) setFailed(i int, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	src := f.sources[i]
+	src.healthy = false
+	src.failures++
+	src.lastError = err.Error()
+	src.lastErrorTime = time.Now()
+}
+
+// next returns the source that must be used after a failure of the active one,
+// and whether all sources failed in a row, therefore a pause is needed.
+func (f *failover) next() (int, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.failedInARow++
+
+	if f.failedInARow >= len(f.sources) {
+		f.failedInARow = 0
+		return 0, true
+	}
+
+	return (f.active + 1) % len(f.sources), false
+}
+
+// switchTo changes the active source.
+func (f *failover) switchTo(i int, reason string, l logger.Writer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if i == f.active {
+		return
+	}
+
+	l.Log(logger.Warn, "switching from source %d to source %d: %s", f.active, i, reason)
+
+	f.history = append(f.history, failoverEvent{
+		time:   time.Now(),
+		from:   f.active,
+		to:     i,
+		reason: reason,
+	})
+	if len(f.history) > failoverHistorySize {
+		f.history = f.history[len(f.history)-failoverHistorySize:]
+	}
+
+	f.active = i
+}
+
+func (f *failover) apiDescribe() *defs.APIPathSourceFailover {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	ret := &defs.APIPathSourceFailover{
+		ActiveSource: f.active,
+		Sources:      make([]defs.APIPathFailoverSource, len(f.sources)),
+		History:      make([]defs.APIPathFailoverEvent, len(f.history)),
+	}
+
+	for i, src := range f.sources {
+		ret.Sources[i] = defs.APIPathFailoverSource{
+			URL:      redactSource(src.url),
+			Healthy:  src.healthy,
+			Failures: src.failures,
+			LastError: func() *string {
+				if src.lastError == "" {
+					return nil
+				}
+				v := src.lastError
+				return &v
+			}(),
+			LastErrorTime: func() *time.Time {
+				if src.lastErrorTime.IsZero() {
+					return nil
+				}
+				v := src.lastErrorTime
+				return &v
+			}(),
+			LastReadyTime: func() *time.Time {
+				if src.lastReadyTime.IsZero() {
+					return nil
+				}
+				v := src.lastReadyTime
+				return &v
+			}(),
+		}
+	}
+
+	for i, ev := range f.history {
+		ret.History[i] = defs.APIPathFailoverEvent{
+			Time:   ev.time,
+			From:   ev.from,
+			To:     ev.to,
+			Reason: ev.reason,
+		}
+	}
+
+	return ret
+}
+
+// failoverProbe is the parent of an instance that checks whether the primary source is healthy.
+// The instance is stopped as soon as it becomes ready.
+type failoverProbe struct {
+	handler *Handler
+}
+
+// Log implements logger.Writer.
+func (p *failoverProbe) Log(level logger.Level, format string, args ...any) {
+	p.handler.Log(level, "[probe] "+format, args...)
+}
+
+// SetReady is called by a staticSource.
+func (p *failoverProbe) SetReady(_ defs.PathSourceStaticSetReadyReq) defs.PathSourceStaticSetReadyRes {
+	return defs.PathSourceStaticSetReadyRes{Err: errProbeSucceeded}
+}
+
+// SetNotReady is called by a staticSource.
+func (p *failoverProbe) SetNotReady(_ defs.PathSourceStaticSetNotReadyReq) {
+}
+
+// AddReader is called by a staticSource.
+func (p *failoverProbe) AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error) {
+	return p.handler.AddReader(req)
+}
@@ -3,6 +3,7 @@ package staticsources
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/bluenviron/mediamtx/internal/conf"
 	"github.com/bluenviron/mediamtx/internal/defs"
 	"github.com/bluenviron/mediamtx/internal/logger"
+	sscomposite "github.com/bluenviron/mediamtx/internal/staticsources/composite"
 	sshls "github.com/bluenviron/mediamtx/internal/staticsources/hls"
 	ssmpegts "github.com/bluenviron/mediamtx/internal/staticsources/mpegts"
 	ssrpicamera "github.com/bluenviron/mediamtx/internal/staticsources/rpicamera"
@@ -18,6 +20,7 @@ import (
 	ssrtp "github.com/bluenviron/mediamtx/internal/staticsources/rtp"
 	ssrtsp "github.com/bluenviron/mediamtx/internal/staticsources/rtsp"
 	sssrt "github.com/bluenviron/mediamtx/internal/staticsources/srt"
+	ssswitcher "github.com/bluenviron/mediamtx/internal/staticsources/switcher"
 	sswebrtc "github.com/bluenviron/mediamtx/internal/staticsources/webrtc"
 	"github.com/bluenviron/mediamtx/internal/stream"
 )
@@ -50,6 +53,13 @@ type staticSource interface {
 	APISourceDescribe() defs.APIPathSourceOrReader
 }
 
+type instanceParent interface {
+	logger.Writer
+	SetReady(req defs.PathSourceStaticSetReadyReq) defs.PathSourceStaticSetReadyRes
+	SetNotReady(req defs.PathSourceStaticSetNotReadyReq)
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
 type handlerPathManager interface {
 	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
 }
@@ -75,7 +85,9 @@ type Handler struct {
 
 	ctx       context.Context
 	ctxCancel func()
-	instance  staticSource
+	sources   []string
+	instances []staticSource
+	failover  *failover
 	running   bool
 	query     string
 
@@ -83,6 +95,8 @@ type Handler struct {
 	chReloadConf          chan *conf.Path
 	chInstanceSetReady    chan defs.PathSourceStaticSetReadyReq
 	chInstanceSetNotReady chan defs.PathSourceStaticSetNotReadyReq
+	chInstanceReady       chan *stream.Stream
+	chRestart             chan string
 
 	// out
 	done chan struct{}
@@ -93,73 +107,102 @@ func (s *Handler) Initialize() {
 	s.chReloadConf = make(chan *conf.Path)
 	s.chInstanceSetReady = make(chan defs.PathSourceStaticSetReadyReq)
 	s.chInstanceSetNotReady = make(chan defs.PathSourceStaticSetNotReadyReq)
+	s.chInstanceReady = make(chan *stream.Stream)
+	s.chRestart = make(chan string, 1)
+
+	s.sources = append([]string{s.Conf.Source}, s.Conf.SourceFailover...)
+
+	for _, src := range s.sources {
+		s.instances = append(s.instances, s.newInstance(src, s))
+	}
+
+	if len(s.sources) > 1 {
+		s.failover = newFailover(s.sources)
+	}
+}
 
+func (s *Handler) newInstance(source string, parent instanceParent) staticSource {
 	switch {
-	case strings.HasPrefix(s.Conf.Source, "rtsp://") ||
-		strings.HasPrefix(s.Conf.Source, "rtsps://") ||
-		strings.HasPrefix(s.Conf.Source, "rtsp+http://") ||
-		strings.HasPrefix(s.Conf.Source, "rtsps+http://") ||
-		strings.HasPrefix(s.Conf.Source, "rtsp+ws://") ||
-		strings.HasPrefix(s.Conf.Source, "rtsps+ws://"):
-		s.instance = &ssrtsp.Source{
+	case strings.HasPrefix(source, "rtsp://") ||
+		strings.HasPrefix(source, "rtsps://") ||
+		strings.HasPrefix(source, "rtsp+http://") ||
+		strings.HasPrefix(source, "rtsps+http://") ||
+		strings.HasPrefix(source, "rtsp+ws://") ||
+		strings.HasPrefix(source, "rtsps+ws://"):
+		return &ssrtsp.Source{
 			ReadTimeout:       s.ReadTimeout,
 			WriteTimeout:      s.WriteTimeout,
 			WriteQueueSize:    s.WriteQueueSize,
 			UDPReadBufferSize: s.UDPReadBufferSize,
-			Parent:            s,
+			Parent:            parent,
 		}
 
-	case strings.HasPrefix(s.Conf.Source, "rtmp://") ||
-		strings.HasPrefix(s.Conf.Source, "rtmps://"):
-		s.instance = &ssrtmp.Source{
+	case strings.HasPrefix(source, "rtmp://") ||
+		strings.HasPrefix(source, "rtmps://"):
+		return &ssrtmp.Source{
 			ReadTimeout:  s.ReadTimeout,
 			WriteTimeout: s.WriteTimeout,
-			Parent:       s,
+			Parent:       parent,
 		}
 
-	case strings.HasPrefix(s.Conf.Source, "http://") ||
-		strings.HasPrefix(s.Conf.Source, "https://"):
-		s.instance = &sshls.Source{
+	case strings.HasPrefix(source, "http://") ||
+		strings.HasPrefix(source, "https://"):
+		return &sshls.Source{
 			ReadTimeout: s.ReadTimeout,
-			Parent:      s,
+			Parent:      parent,
 		}
 
-	case strings.HasPrefix(s.Conf.Source, "udp://") ||
-		strings.HasPrefix(s.Conf.Source, "udp+mpegts://") ||
-		strings.HasPrefix(s.Conf.Source, "unix+mpegts://"):
-		s.instance = &ssmpegts.Source{
+	case strings.HasPrefix(source, "udp://") ||
+		strings.HasPrefix(source, "udp+mpegts://") ||
+		strings.HasPrefix(source, "unix+mpegts://"):
+		return &ssmpegts.Source{
 			ReadTimeout:       s.ReadTimeout,
 			UDPReadBufferSize: s.UDPReadBufferSize,
-			Parent:            s,
+			Parent:            parent,
 		}
 
-	case strings.HasPrefix(s.Conf.Source, "srt://"):
-		s.instance = &sssrt.Source{
+	case strings.HasPrefix(source, "srt://"):
+		return &sssrt.Source{
 			ReadTimeout: s.ReadTimeout,
-			Parent:      s,
+			Parent:      parent,
 		}
 
-	case strings.HasPrefix(s.Conf.Source, "whep://") ||
-		strings.HasPrefix(s.Conf.Source, "wheps://"):
-		s.instance = &sswebrtc.Source{
+	case strings.HasPrefix(source, "whep://") ||
+		strings.HasPrefix(source, "wheps://"):
+		return &sswebrtc.Source{
 			ReadTimeout:       s.ReadTimeout,
 			UDPReadBufferSize: s.UDPReadBufferSize,
-			Parent:            s,
+			Parent:            parent,
 		}
 
-	case strings.HasPrefix(s.Conf.Source, "udp+rtp://") ||
-		strings.HasPrefix(s.Conf.Source, "unix+rtp://"):
-		s.instance = &ssrtp.Source{
+	case strings.HasPrefix(source, "udp+rtp://") ||
+		strings.HasPrefix(source, "unix+rtp://"):
+		return &ssrtp.Source{
 			ReadTimeout:       s.ReadTimeout,
 			UDPReadBufferSize: s.UDPReadBufferSize,
-			Parent:            s,
+			Parent:            parent,
 		}
 
-	case s.Conf.Source == "rpiCamera":
-		s.instance = &ssrpicamera.Source{
+	case source == "rpiCamera":
+		return &ssrpicamera.Source{
 			RTPMaxPayloadSize: s.RTPMaxPayloadSize,
 			LogLevel:          s.LogLevel,
-			Parent:            s,
+			Parent:            parent,
+		}
+
+	case source == "switcher":
+		src := &ssswitcher.Source{
+			Inputs: s.Conf.SwitcherInputs,
+			Parent: parent,
+		}
+		src.Initialize()
+		return src
+
+	case source == "composite":
+		return &sscomposite.Source{
+			Inputs:     s.Conf.CompositeInputs,
+			Timestamps: s.Conf.CompositeTimestamps,
+			Parent:     parent,
 		}
 
 	default:
@@ -180,10 +223,14 @@ func (s *Handler) Start(onDemand bool, query string) {
 
 	s.running = true
 	s.query = query
+
+	if s.failover != nil {
+		s.failover.switchTo(0, "source restarted", s)
+	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	s.done = make(chan struct{})
 
-	s.instance.Log(logger.Info, "started%s",
+	s.instance().Log(logger.Info, "started%s",
 		func() string {
 			if onDemand {
 				return " on demand"
@@ -202,7 +249,7 @@ func (s *Handler) Stop(reason string) {
 
 	s.running = false
 
-	s.instance.Log(logger.Info, "stopped: %s", reason)
+	s.instance().Log(logger.Info, "stopped: %s", reason)
 
 	s.ctxCancel()
 
@@ -210,6 +257,17 @@ func (s *Handler) Stop(reason string) {
 	<-s.done
 }
 
+func (s *Handler) activeSource() int {
+	if s.failover == nil {
+		return 0
+	}
+	return s.failover.activeSource()
+}
+
+func (s *Handler) instance() staticSource {
+	return s.instances[s.activeSource()]
+}
+
 // Log implements logger.Writer.
 func (s *Handler) Log(level logger.Level, format string, args ...any) {
 	s.Parent.Log(level, format, args...)
@@ -224,11 +282,12 @@ func (s *Handler) run() {
 	runReloadConf := make(chan *conf.Path)
 
 	recreate := func() {
-		resolvedSource := resolveSource(s.Conf.Source, s.Matches, s.query)
+		instance := s.instance()
+		resolvedSource := resolveSource(s.sources[s.activeSource()], s.Matches, s.query)
 
 		runCtx, runCtxCancel = context.WithCancel(context.Background())
 		go func() {
-			runErr <- s.instance.Run(defs.StaticSourceRunParams{
+			runErr <- instance.Run(defs.StaticSourceRunParams{
 				Context:        runCtx,
 				ResolvedSource: resolvedSource,
 				Conf:           s.Conf,
@@ -241,14 +300,104 @@ func (s *Handler) run() {
 
 	recreating := false
 	recreateTimer := emptyTimer()
+	restarting := false
+
+	// discard restart requests of previous runs
+	select {
+	case <-s.chRestart:
+	default:
+	}
+
+	// failover
+	switching := false
+	nextSource := 0
+	nextReason := ""
+	var readyStream *stream.Stream
+	var readyBytes uint64
+	var readyBytesTime time.Time
+	var stallTick <-chan time.Time
+	failbackTimer := emptyTimer()
+	probing := false
+	var probeCtxCancel func()
+	probeErr := make(chan error)
+
+	if s.failover != nil {
+		stallTicker := time.NewTicker(failoverStallPeriod)
+		defer stallTicker.Stop()
+		stallTick = stallTicker.C
+	}
+
+	resetFailback := func() {
+		failbackTimer.Stop()
+		failbackTimer = emptyTimer()
+	}
+
+	// stop the active instance and start another one when Run() returns
+	switchSource := func(next int, reason string) {
+		switching = true
+		nextSource = next
+		nextReason = reason
+		readyStream = nil
+		resetFailback()
+		runCtxCancel()
+	}
+
+	startProbe := func() {
+		probing = true
+
+		instance := s.newInstance(s.sources[0], &failoverProbe{handler: s})
+		resolvedSource := resolveSource(s.sources[0], s.Matches, s.query)
+
+		var probeCtx context.Context
+		probeCtx, probeCtxCancel = context.WithCancel(context.Background())
+		go func() {
+			probeErr <- instance.Run(defs.StaticSourceRunParams{
+				Context:        probeCtx,
+				ResolvedSource: resolvedSource,
+				Conf:           s.Conf,
+				ReloadConf:     make(chan *conf.Path),
+			})
+		}()
+	}
 
 	for {
 		select {
 		case err := <-runErr:
 			runCtxCancel()
-			s.instance.Log(logger.Error, err.Error())
-			recreating = true
-			recreateTimer = time.NewTimer(retryPause)
+			readyStream = nil
+			resetFailback()
+
+			if switching {
+				switching = false
+				s.failover.switchTo(nextSource, nextReason, s)
+				recreate()
+				continue
+			}
+
+			if restarting {
+				restarting = false
+				recreate()
+				continue
+			}
+
+			s.instance().Log(logger.Error, err.Error())
+
+			if s.failover == nil {
+				recreating = true
+				recreateTimer = time.NewTimer(retryPause)
+				continue
+			}
+
+			s.failover.setFailed(s.activeSource(), err)
+			next, pause := s.failover.next()
+			s.failover.switchTo(next, "source failed: "+err.Error(), s)
+
+			if pause {
+				recreating = true
+				recreateTimer = time.NewTimer(retryPause)
+			} else {
+				recreate()
+			}
 
 		case req := <-s.chInstanceSetReady:
 			s.Parent.StaticSourceHandlerSetReady(s.ctx, req)
@@ -256,9 +405,89 @@ func (s *Handler) run() {
 		case req := <-s.chInstanceSetNotReady:
 			s.Parent.StaticSourceHandlerSetNotReady(s.ctx, req)
 
+		case strm := <-s.chInstanceReady:
+			if s.failover != nil && !switching {
+				s.failover.setReady()
+				readyStream = strm
+				readyBytes = strm.BytesReceived()
+				readyBytesTime = time.Now()
+
+				if s.activeSource() != 0 && !probing && s.Conf.SourceFailbackInterval > 0 {
+					failbackTimer = time.NewTimer(time.Duration(s.Conf.SourceFailbackInterval))
+				}
+			}
+
+		case <-stallTick:
+			if readyStream == nil || s.Conf.SourceFailoverStallTimeout <= 0 {
+				continue
+			}
+
+			if cur := readyStream.BytesReceived(); cur != readyBytes {
+				readyBytes = cur
+				readyBytesTime = time.Now()
+				continue
+			}
+
+			if time.Since(readyBytesTime) >= time.Duration(s.Conf.SourceFailoverStallTimeout) {
+				err := fmt.Errorf("no data received in the last %v", time.Duration(s.Conf.SourceFailoverStallTimeout))
+				s.instance().Log(logger.Error, "stalled: %v", err)
+				s.failover.setFailed(s.activeSource(), err)
+				next, _ := s.failover.next()
+				switchSource(next, "source stalled")
+			}
+
+		case reason := <-s.chRestart:
+			if recreating || switching || restarting {
+				continue
+			}
+
+			s.instance().Log(logger.Warn, "restarting: %s", reason)
+
+			if s.failover != nil {
+				s.failover.setFailed(s.activeSource(), errors.New(reason))
+				next, _ := s.failover.next()
+				switchSource(next, "source restarted: "+reason)
+			} else {
+				restarting = true
+				runCtxCancel()
+			}
+
+		case <-failbackTimer.C:
+			if s.activeSource() != 0 && !probing {
+				startProbe()
+			}
+
+		case err := <-probeErr:
+			probing = false
+			probeCtxCancel()
+
+			if !errors.Is(err, errProbeSucceeded) {
+				s.failover.setFailed(0, err)
+
+				if s.activeSource() != 0 && readyStream != nil && s.Conf.SourceFailbackInterval > 0 {
+					failbackTimer = time.NewTimer(time.Duration(s.Conf.SourceFailbackInterval))
+				}
+				continue
+			}
+
+			switch {
+			case switching:
+				nextSource = 0
+				nextReason = "primary source is healthy again"
+
+			case recreating:
+				recreateTimer.Stop()
+				recreating = false
+				s.failover.switchTo(0, "primary source is healthy again", s)
+				recreate()
+
+			case s.activeSource() != 0:
+				switchSource(0, "primary source is healthy again")
+			}
+
 		case newConf := <-s.chReloadConf:
 			s.Conf = newConf
-			if !recreating {
+			if !recreating && !switching {
 				cReloadConf := runReloadConf
 				cInnerCtx := runCtx
 				go func() {
@@ -278,6 +507,10 @@ func (s *Handler) run() {
 				runCtxCancel()
 				<-runErr
 			}
+			if probing {
+				probeCtxCancel()
+				<-probeErr
+			}
 			return
 		}
 	}
@@ -299,9 +532,49 @@ func (s *Handler) ReloadConf(newConf *conf.Path) {
 	}()
 }
 
+// Restart restarts the active source, or switches to the next one when there are multiple sources.
+// It can be called from any goroutine.
+func (s *Handler) Restart(reason string) {
+	select {
+	case s.chRestart <- reason:
+	default:
+	}
+}
+
 // APISourceDescribe instanceements source.
 func (s *Handler) APISourceDescribe() defs.APIPathSourceOrReader {
-	return s.instance.APISourceDescribe()
+	return s.instance().APISourceDescribe()
+}
+
+// APIFailoverDescribe describes the state of sources, if the path has multiple sources.
+func (s *Handler) APIFailoverDescribe() *defs.APIPathSourceFailover {
+	if s.failover == nil {
+		return nil
+	}
+	return s.failover.apiDescribe()
+}
+
+// APISwitcherDescribe returns the state of a switcher source.
+func (s *Handler) APISwitcherDescribe() *defs.APIPathSwitcher {
+	if src, ok := s.instances[0].(*ssswitcher.Source); ok {
+		return src.APISwitcherDescribe()
+	}
+	return nil
+}
+
+// APISwitcherSwitch changes the active input of a switcher source.
+func (s *Handler) APISwitcherSwitch(input string) (*defs.APIPathSwitcher, error) {
+	src, ok := s.instances[0].(*ssswitcher.Source)
+	if !ok {
+		return nil, defs.ErrPathNotSwitcher
+	}
+
+	err := src.Switch(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return src.APISwitcherDescribe(), nil
 }
 
 // SetReady is called by a staticSource.
@@ -312,7 +585,12 @@ func (s *Handler) SetReady(req defs.PathSourceStaticSetReadyReq) defs.PathSource
 		res := <-req.Res
 
 		if res.Err == nil {
-			s.instance.Log(logger.Info, "ready: %s", defs.MediasInfo(req.Desc.Medias))
+			s.instance().Log(logger.Info, "ready: %s", defs.MediasInfo(req.Desc.Medias))
+
+			select {
+			case s.chInstanceReady <- res.Stream:
+			case <-s.ctx.Done():
+			}
 		}
 
 		return res
@@ -0,0 +1,417 @@
+// Package switcher contains the switcher static source.
+package switcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+
+	"github.com/bluenviron/mediamtx/internal/defs"
+	"github.com/bluenviron/mediamtx/internal/logger"
+	"github.com/bluenviron/mediamtx/internal/stream"
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	pauseBetweenErrors = 1 * time.Second
+)
+
+type inputReader struct {
+	ctx       context.Context
+	ctxCancel func()
+}
+
+// Close implements reader.
+func (r *inputReader) Close() {
+	r.ctxCancel()
+}
+
+// APIReaderDescribe implements reader.
+func (*inputReader) APIReaderDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "switcher",
+		ID:   "",
+	}
+}
+
+// input is a connection to one of the input paths.
+type input struct {
+	index  int
+	path   defs.Path
+	stream *stream.Stream
+	author *inputReader
+	reader *stream.Reader
+}
+
+func (in *input) close() {
+	in.stream.RemoveReader(in.reader)
+	in.path.RemoveReader(defs.PathRemoveReaderReq{Author: in.author})
+	in.author.ctxCancel()
+}
+
+func cloneDesc(desc *description.Session) *description.Session {
+	medias := make([]*description.Media, len(desc.Medias))
+	for i, media := range desc.Medias {
+		medias[i] = &description.Media{
+			Type:    media.Type,
+			Formats: media.Formats,
+		}
+	}
+	return &description.Session{Medias: medias}
+}
+
+type parent interface {
+	logger.Writer
+	SetReady(req defs.PathSourceStaticSetReadyReq) defs.PathSourceStaticSetReadyRes
+	SetNotReady(req defs.PathSourceStaticSetNotReadyReq)
+	AddReader(req defs.PathAddReaderReq) (defs.Path, *stream.Stream, error)
+}
+
+// Source is a switcher static source.
+// It reads from multiple paths and forwards one of them.
+type Source struct {
+	Inputs []string
+	Parent parent
+
+	mutex    sync.Mutex
+	active   int
+	pending  int
+	chSwitch chan struct{}
+	chCut    chan *input
+}
+
+// Initialize initializes Source.
+func (s *Source) Initialize() {
+	s.pending = -1
+	s.chSwitch = make(chan struct{}, 1)
+	s.chCut = make(chan *input, 1)
+}
+
+// Log implements logger.Writer.
+func (s *Source) Log(level logger.Level, format string, args ...any) {
+	s.Parent.Log(level, "[switcher source] "+format, args...)
+}
+
+// Run implements StaticSource.
+func (s *Source) Run(params defs.StaticSourceRunParams) error {
+	// discard cuts of previous runs
+	select {
+	case <-s.chCut:
+	default:
+	}
+
+	activeIn, err := s.waitForActive(params.Context)
+	if err != nil {
+		return err
+	}
+
+	// activeIn changes after every cut
+	defer func() {
+		activeIn.close()
+	}()
+
+	res := s.Parent.SetReady(defs.PathSourceStaticSetReadyReq{
+		Desc:               cloneDesc(activeIn.stream.Desc),
+		GenerateRTPPackets: false,
+		FillNTP:            true,
+	})
+	if res.Err != nil {
+		return res.Err
+	}
+
+	defer s.Parent.SetNotReady(defs.PathSourceStaticSetNotReadyReq{})
+
+	s.setupInput(activeIn, res.Stream)
+	activeIn.stream.AddReader(activeIn.reader)
+
+	var pendingIn *input
+
+	defer func() {
+		if pendingIn != nil {
+			pendingIn.close()
+		}
+	}()
+
+	onCut := func(in *input) {
+		if in != pendingIn {
+			return
+		}
+
+		s.Log(logger.Info, "switched to input '%s'", s.Inputs[in.index])
+		activeIn.close()
+		activeIn = pendingIn
+		pendingIn = nil
+	}
+
+	for {
+		select {
+		case <-s.chSwitch:
+			// a cut may have happened in the meanwhile
+			select {
+			case in := <-s.chCut:
+				onCut(in)
+			default:
+			}
+
+			target := s.pendingInput()
+
+			if pendingIn != nil && pendingIn.index != target {
+				pendingIn.close()
+				pendingIn = nil
+			}
+
+			if target < 0 || pendingIn != nil {
+				continue
+			}
+
+			pendingIn, err = s.openInput(target)
+			if err != nil {
+				s.Log(logger.Error, "unable to switch to input '%s': %v", s.Inputs[target], err)
+				s.cancelPending(target)
+				continue
+			}
+
+			if s.setupInput(pendingIn, res.Stream) == 0 {
+				s.Log(logger.Error, "unable to switch to input '%s': input has no tracks compatible with %s",
+					s.Inputs[target], defs.MediasInfo(res.Stream.Desc.Medias))
+				pendingIn.close()
+				pendingIn = nil
+				s.cancelPending(target)
+				continue
+			}
+
+			s.Log(logger.Info, "switching to input '%s' on next key frame", s.Inputs[target])
+			pendingIn.stream.AddReader(pendingIn.reader)
+
+		case in := <-s.chCut:
+			onCut(in)
+
+		case err = <-activeIn.reader.Error():
+			return fmt.Errorf("input '%s': %w", s.Inputs[activeIn.index], err)
+
+		case <-activeIn.author.ctx.Done():
+			return fmt.Errorf("input '%s' closed", s.Inputs[activeIn.index])
+
+		case err = <-readerError(pendingIn):
+			s.Log(logger.Error, "unable to switch to input '%s': %v", s.Inputs[pendingIn.index], err)
+			s.cancelPending(pendingIn.index)
+			pendingIn.close()
+			pendingIn = nil
+
+		case <-readerDone(pendingIn):
+			s.Log(logger.Error, "unable to switch to input '%s': input closed", s.Inputs[pendingIn.index])
+			s.cancelPending(pendingIn.index)
+			pendingIn.close()
+			pendingIn = nil
+
+		case <-params.Context.Done():
+			return fmt.Errorf("terminated")
+		}
+	}
+}
+
+func readerError(in *input) chan error {
+	if in == nil {
+		return nil
+	}
+	return in.reader.Error()
+}
+
+func readerDone(in *input) <-chan struct{} {
+	if in == nil {
+		return nil
+	}
+	return in.author.ctx.Done()
+}
+
+// waitForActive waits until the active input is available.
+func (s *Source) waitForActive(ctx context.Context) (*input, error) {
+	for {
+		s.mutex.Lock()
+		if s.pending >= 0 {
+			s.active = s.pending
+			s.pending = -1
+		}
+		active := s.active
+		s.mutex.Unlock()
+
+		in, err := s.openInput(active)
+		if err == nil {
+			return in, nil
+		}
+
+		var err2 defs.PathNoStreamAvailableError
+		if !errors.As(err, &err2) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(pauseBetweenErrors):
+		case <-s.chSwitch:
+		case <-ctx.Done():
+			return nil, fmt.Errorf("terminated")
+		}
+	}
+}
+
+func (s *Source) openInput(index int) (*input, error) {
+	author := &inputReader{}
+	author.ctx, author.ctxCancel = context.WithCancel(context.Background())
+
+	path, strm, err := s.Parent.AddReader(defs.PathAddReaderReq{
+		Author: author,
+		AccessRequest: defs.PathAccessRequest{
+			Name:     s.Inputs[index],
+			SkipAuth: true,
+		},
+	})
+	if err != nil {
+		author.ctxCancel()
+		return nil, err
+	}
+
+	return &input{
+		index:  index,
+		path:   path,
+		stream: strm,
+		author: author,
+		reader: &stream.Reader{
+			SkipBytesSent: true,
+			Parent:        s,
+		},
+	}, nil
+}
+
+// setupInput routes tracks of an input to compatible tracks of the program stream,
+// and returns the number of routed tracks.
+func (s *Source) setupInput(in *input, program *stream.Stream) int {
+	used := make(map[format.Format]struct{})
+	programHasKeyFrames := false
+	hasKeyFrames := false
+	n := 0
+
+	for _, media := range program.Desc.Medias {
+		for _, forma := range media.Formats {
+			if stream.FormatHasKeyFrames(forma) {
+				programHasKeyFrames = true
+			}
+
+			inMedia, inFormat := stream.FindCompatibleFormat(in.stream.Desc, media.Type, forma, used)
+			if inFormat == nil {
+				continue
+			}
+
+			used[inFormat] = struct{}{}
+			n++
+
+			if stream.FormatHasKeyFrames(inFormat) {
+				hasKeyFrames = true
+			}
+
+			cmedia := media
+			cforma := forma
+
+			in.reader.OnData(inMedia, inFormat, func(u *unit.Unit) error {
+				s.mutex.Lock()
+				defer s.mutex.Unlock()
+
+				switch {
+				case s.active == in.index:
+
+				// the cut happens on the first key frame of the pending input,
+				// or on its first unit if it has no key frames
+				case s.pending == in.index &&
+					(!hasKeyFrames || (stream.FormatHasKeyFrames(inFormat) && stream.IsKeyFrame(inFormat, u))):
+					s.active = in.index
+					s.pending = -1
+					program.Splice()
+					s.chCut <- in
+
+				default:
+					return nil
+				}
+
+				program.WriteSplicedUnit(cmedia, cforma, u)
+				return nil
+			})
+		}
+	}
+
+	// the program stream waits for a key frame after every cut
+	if programHasKeyFrames && !hasKeyFrames {
+		return 0
+	}
+
+	return n
+}
+
+func (s *Source) pendingInput() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pending
+}
+
+func (s *Source) cancelPending(index int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.pending == index {
+		s.pending = -1
+	}
+}
+
+// Switch changes the active input.
+// The change happens on the next key frame of the new input.
+func (s *Source) Switch(name string) error {
+	index := slices.Index(s.Inputs, name)
+	if index < 0 {
+		return defs.ErrSwitcherInputNotFound
+	}
+
+	s.mutex.Lock()
+	if index == s.active {
+		s.pending = -1
+	} else {
+		s.pending = index
+	}
+	s.mutex.Unlock()
+
+	select {
+	case s.chSwitch <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// APISwitcherDescribe returns the state of the switcher.
+func (s *Source) APISwitcherDescribe() *defs.APIPathSwitcher {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return &defs.APIPathSwitcher{
+		Inputs:      s.Inputs,
+		ActiveInput: s.Inputs[s.active],
+		PendingInput: func() *string {
+			if s.pending < 0 {
+				return nil
+			}
+			v := s.Inputs[s.pending]
+			return &v
+		}(),
+	}
+}
+
+// APISourceDescribe implements StaticSource.
+func (*Source) APISourceDescribe() defs.APIPathSourceOrReader {
+	return defs.APIPathSourceOrReader{
+		Type: "switcherSource",
+		ID:   "",
+	}
+}
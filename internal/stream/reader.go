@@ -2,6 +2,9 @@ package stream
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	"github.com/bluenviron/gortsplib/v5/pkg/format"
@@ -17,12 +20,22 @@ type OnDataFunc func(*unit.Unit) error
 // Reader is a stream reader.
 type Reader struct {
 	SkipBytesSent bool
-	Parent        logger.Writer
+	// delay of the stream with respect to live, when time-shift is enabled.
+	Delay time.Duration
+	// optional counter of the times the reader skipped to the next key frame.
+	Skips  *uint64
+	Parent logger.Writer
 
 	onDatas         map[*description.Media]map[format.Format]OnDataFunc
 	queueSize       int
+	reservedSize    int
+	queued          int64
+	streamSkips     *uint64
 	buffer          *ringbuffer.RingBuffer
 	discardedFrames *counterdumper.CounterDumper
+	skipMutex       sync.Mutex
+	skipping        map[format.Format]struct{}
+	timeShift       *timeShiftReader
 
 	// out
 	err chan error
@@ -61,6 +74,7 @@ func (r *Reader) Error() chan error {
 func (r *Reader) start() {
 	buffer, _ := ringbuffer.New(uint64(r.queueSize))
 	r.buffer = buffer
+	r.skipping = make(map[format.Format]struct{})
 	r.err = make(chan error)
 
 	r.discardedFrames = &counterdumper.CounterDumper{
@@ -98,6 +112,8 @@ func (r *Reader) runInner() error {
 			return fmt.Errorf("terminated")
 		}
 
+		atomic.AddInt64(&r.queued, -1)
+
 		err := cb.(func() error)()
 		if err != nil {
 			return err
@@ -105,9 +121,71 @@ func (r *Reader) runInner() error {
 	}
 }
 
-func (r *Reader) push(cb func() error) {
+// skipUntilKeyFrame discards units of formats with key frames until the next key frame.
+// It returns true if a format was not being skipped before.
+func (r *Reader) skipUntilKeyFrame() bool {
+	r.skipMutex.Lock()
+	defer r.skipMutex.Unlock()
+
+	added := false
+
+	for _, formats := range r.onDatas {
+		for forma := range formats {
+			if FormatHasKeyFrames(forma) {
+				if _, ok := r.skipping[forma]; !ok {
+					r.skipping[forma] = struct{}{}
+					added = true
+				}
+			}
+		}
+	}
+
+	return added
+}
+
+func (r *Reader) skip() {
+	r.discardedFrames.Increase()
+
+	if r.skipUntilKeyFrame() {
+		atomic.AddUint64(r.streamSkips, 1)
+		if r.Skips != nil {
+			atomic.AddUint64(r.Skips, 1)
+		}
+	}
+}
+
+// push adds a unit to the queue.
+// When the queue is full, units of all formats with key frames are discarded until the next key frame,
+// in order to avoid sending frames that can't be decoded.
+// Part of the queue is reserved to formats without key frames (i.e. audio),
+// that are therefore kept while video is being skipped.
+func (r *Reader) push(forma format.Format, u *unit.Unit, cb func() error) {
+	hasKeyFrames := FormatHasKeyFrames(forma)
+
+	if hasKeyFrames {
+		r.skipMutex.Lock()
+		_, skipping := r.skipping[forma]
+		if skipping {
+			if !IsKeyFrame(forma, u) {
+				r.skipMutex.Unlock()
+				r.discardedFrames.Increase()
+				return
+			}
+			delete(r.skipping, forma)
+		}
+		r.skipMutex.Unlock()
+
+		if atomic.LoadInt64(&r.queued) >= int64(r.queueSize-r.reservedSize) {
+			r.skip()
+			return
+		}
+	}
+
+	atomic.AddInt64(&r.queued, 1)
+
 	ok := r.buffer.Push(cb)
 	if !ok {
-		r.discardedFrames.Increase()
+		atomic.AddInt64(&r.queued, -1)
+		r.skip()
 	}
 }
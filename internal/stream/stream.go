@@ -2,6 +2,9 @@
 package stream
 
 import (
+	"fmt"
+	"reflect"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -24,22 +27,33 @@ type Stream struct {
 	Desc               *description.Session
 	GenerateRTPPackets bool
 	FillNTP            bool
+	Spliceable         bool
+	GOPCache           bool
+	GOPCacheMaxSize    uint64
+	TimeShift          bool
+	TimeShiftDuration  time.Duration
+	TimeShiftMaxSize   uint64
 	Parent             logger.Writer
 
 	bytesReceived    *uint64
 	bytesSent        *uint64
+	readerSkips      *uint64
 	medias           map[*description.Media]*streamMedia
 	mutex            sync.RWMutex
 	rtspStream       *gortsplib.ServerStream
 	rtspsStream      *gortsplib.ServerStream
 	readers          map[*Reader]struct{}
 	processingErrors *counterdumper.CounterDumper
+	splicer          splicer
+	gopCache         *gopCache
+	timeShift        *timeShift
 }
 
 // Initialize initializes a Stream.
 func (s *Stream) Initialize() error {
 	s.bytesReceived = new(uint64)
 	s.bytesSent = new(uint64)
+	s.readerSkips = new(uint64)
 	s.medias = make(map[*description.Media]*streamMedia)
 	s.readers = make(map[*Reader]struct{})
 
@@ -57,6 +71,20 @@ func (s *Stream) Initialize() error {
 	}
 	s.processingErrors.Start()
 
+	if s.GOPCache {
+		s.gopCache = &gopCache{
+			maxSize: s.GOPCacheMaxSize,
+		}
+		s.gopCache.initialize()
+	}
+
+	if s.TimeShift {
+		s.timeShift = &timeShift{
+			duration: s.TimeShiftDuration,
+			maxSize:  s.TimeShiftMaxSize,
+		}
+	}
+
 	for _, media := range s.Desc.Medias {
 		s.medias[media] = &streamMedia{
 			rtpMaxPayloadSize:  s.RTPMaxPayloadSize,
@@ -111,6 +139,27 @@ func (s *Stream) BytesSent() uint64 {
 	return bytesSent
 }
 
+// ReaderSkips returns the number of times a reader skipped to the next key frame
+// because it was too slow.
+func (s *Stream) ReaderSkips() uint64 {
+	return atomic.LoadUint64(s.readerSkips)
+}
+
+// TrackStats returns statistics of every track, in the same order of the stream description.
+func (s *Stream) TrackStats() []TrackStats {
+	var ret []TrackStats
+
+	for _, media := range s.Desc.Medias {
+		sm := s.medias[media]
+
+		for _, forma := range media.Formats {
+			ret = append(ret, sm.formats[forma].stats.get())
+		}
+	}
+
+	return ret
+}
+
 // RTSPStream returns the RTSP stream.
 func (s *Stream) RTSPStream(server *gortsplib.Server) *gortsplib.ServerStream {
 	s.mutex.Lock()
@@ -147,6 +196,22 @@ func (s *Stream) RTSPSStream(server *gortsplib.Server) *gortsplib.ServerStream {
 	return s.rtspsStream
 }
 
+// readerMedia returns the media of the stream that corresponds to a media of a reader.
+// Readers can use copies of medias of the stream, like renamed ones, that share formats with them.
+func (s *Stream) readerMedia(medi *description.Media) *streamMedia {
+	if sm, ok := s.medias[medi]; ok {
+		return sm
+	}
+
+	for _, sm := range s.medias {
+		if slices.Contains(sm.media.Formats, medi.Formats[0]) {
+			return sm
+		}
+	}
+
+	return nil
+}
+
 // AddReader adds a reader.
 // Used by all protocols except RTSP.
 func (s *Stream) AddReader(r *Reader) {
@@ -155,8 +220,35 @@ func (s *Stream) AddReader(r *Reader) {
 
 	s.readers[r] = struct{}{}
 
+	r.streamSkips = s.readerSkips
+	r.reservedSize = s.WriteQueueSize / 4
+
+	// delayed readers are fed by the time-shift buffer
+	if r.Delay > 0 && s.timeShift != nil {
+		cbs := make(map[*streamFormat]OnDataFunc)
+		for medi, formats := range r.onDatas {
+			sm := s.readerMedia(medi)
+
+			for forma, onData := range formats {
+				cbs[sm.formats[forma]] = onData
+			}
+		}
+
+		r.queueSize = s.WriteQueueSize
+		r.start()
+
+		r.timeShift = &timeShiftReader{
+			stream: s,
+			ts:     s.timeShift,
+			reader: r,
+			cbs:    cbs,
+		}
+		r.timeShift.initialize()
+		return
+	}
+
 	for medi, formats := range r.onDatas {
-		sm := s.medias[medi]
+		sm := s.readerMedia(medi)
 
 		for forma, onData := range formats {
 			sf := sm.formats[forma]
@@ -165,7 +257,25 @@ func (s *Stream) AddReader(r *Reader) {
 	}
 
 	r.queueSize = s.WriteQueueSize
+
+	var cached []gopCacheEntry
+	if s.gopCache != nil {
+		cached = s.gopCache.get()
+
+		// make room for cached units
+		for r.queueSize < (len(cached) + s.WriteQueueSize) {
+			r.queueSize *= 2
+		}
+	}
+
 	r.start()
+
+	// send units since the last key frame, then live units
+	for _, entry := range cached {
+		if onData, ok := entry.sf.onDatas[r]; ok {
+			s.pushUnit(r, entry.sf.format, onData, entry.u, entry.size)
+		}
+	}
 }
 
 // RemoveReader removes a reader.
@@ -174,10 +284,15 @@ func (s *Stream) RemoveReader(r *Reader) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	if r.timeShift != nil {
+		r.timeShift.close()
+		r.timeShift = nil
+	}
+
 	r.stop()
 
 	for medi, formats := range r.onDatas {
-		sm := s.medias[medi]
+		sm := s.readerMedia(medi)
 
 		for forma := range formats {
 			sf := sm.formats[forma]
@@ -188,15 +303,24 @@ func (s *Stream) RemoveReader(r *Reader) {
 	delete(s.readers, r)
 }
 
+func (s *Stream) pushUnit(r *Reader, forma format.Format, onData OnDataFunc, u *unit.Unit, size uint64) {
+	r.push(forma, u, func() error {
+		if !r.SkipBytesSent {
+			atomic.AddUint64(s.bytesSent, size)
+		}
+		return onData(u)
+	})
+}
+
 // WriteUnit writes a Unit.
 func (s *Stream) WriteUnit(medi *description.Media, forma format.Format, u *unit.Unit) {
-	sm := s.medias[medi]
-	sf := sm.formats[forma]
-
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	sf.writeUnit(s, medi, u)
+	sm := s.medias[medi]
+	sf := sm.formats[forma]
+
+	sf.writeUnit(s, sm.media, u)
 }
 
 // WriteRTPPacket writes a RTP packet.
@@ -207,11 +331,157 @@ func (s *Stream) WriteRTPPacket(
 	ntp time.Time,
 	pts int64,
 ) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	sm := s.medias[medi]
 	sf := sm.formats[forma]
 
+	sf.writeRTPPacket(s, sm.media, pkt, ntp, pts)
+}
+
+// Splice prepares the stream to receive data from a different source.
+// Timestamps of next units are shifted in order to continue the current timeline,
+// RTP packets are rewritten in order to keep them continuous,
+// and units are discarded until a key frame is received.
+// It can be used only when Spliceable is true.
+func (s *Stream) Splice() {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	sf.writeRTPPacket(s, medi, pkt, ntp, pts)
+	waitKeyFrame := false
+
+	for _, media := range s.Desc.Medias {
+		sm := s.medias[media]
+
+		for _, forma := range media.Formats {
+			sf := sm.formats[forma]
+
+			s.splicer.mutex.Lock()
+			sf.rtpResync = true
+			s.splicer.mutex.Unlock()
+
+			if FormatHasKeyFrames(forma) {
+				waitKeyFrame = true
+			}
+		}
+	}
+
+	s.splicer.splice(waitKeyFrame)
+}
+
+// SpliceSource allows the stream to receive data from a source
+// whose description is different but compatible, then calls Splice().
+// Medias and formats of desc can then be used in WriteUnit() and WriteRTPPacket().
+func (s *Stream) SpliceSource(desc *description.Session, generateRTPPackets bool) error {
+	err := s.checkCompatible(desc, generateRTPPackets)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+
+	medias := make(map[*description.Media]*streamMedia)
+
+	for i, media := range s.Desc.Medias {
+		sm := s.medias[media]
+		medias[media] = sm
+		medias[desc.Medias[i]] = sm
+
+		formats := make(map[format.Format]*streamFormat)
+
+		for j, forma := range media.Formats {
+			sf := sm.formats[forma]
+			formats[forma] = sf
+			formats[desc.Medias[i].Formats[j]] = sf
+		}
+
+		sm.formats = formats
+	}
+
+	s.medias = medias
+
+	s.mutex.Unlock()
+
+	s.Splice()
+
+	return nil
+}
+
+func (s *Stream) checkCompatible(desc *description.Session, generateRTPPackets bool) error {
+	if generateRTPPackets && !s.GenerateRTPPackets {
+		return fmt.Errorf("source requires generation of RTP packets")
+	}
+
+	if len(desc.Medias) != len(s.Desc.Medias) {
+		return fmt.Errorf("source has %d medias, while the stream has %d", len(desc.Medias), len(s.Desc.Medias))
+	}
+
+	for i, media := range s.Desc.Medias {
+		newMedia := desc.Medias[i]
+
+		if newMedia.Type != media.Type || len(newMedia.Formats) != len(media.Formats) {
+			return fmt.Errorf("media %d is not compatible", i)
+		}
+
+		for j, forma := range media.Formats {
+			if !FormatsCompatible(newMedia.Formats[j], forma) ||
+				newMedia.Formats[j].PayloadType() != forma.PayloadType() {
+				return fmt.Errorf("format %s of media %d is not compatible", newMedia.Formats[j].Codec(), i)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WriteSplicedUnit writes a Unit that has been already processed by another stream.
+// It can be used only when Spliceable is true.
+func (s *Stream) WriteSplicedUnit(medi *description.Media, forma format.Format, u *unit.Unit) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sm := s.medias[medi]
+	sf := sm.formats[forma]
+
+	// unit and RTP packets are shared with readers of the other stream
+	nu := *u
+	nu.RTPPackets = make([]*rtp.Packet, len(u.RTPPackets))
+	for i, pkt := range u.RTPPackets {
+		nu.RTPPackets[i] = pkt.Clone()
+	}
+
+	sf.writeUnitInner(s, sm.media, &nu)
+}
+
+// FormatsCompatible checks whether units of a format can be written into another one.
+func FormatsCompatible(a format.Format, b format.Format) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && a.ClockRate() == b.ClockRate()
+}
+
+// FindCompatibleFormat finds a format of desc, not present in used,
+// whose units can be written into forma.
+func FindCompatibleFormat(
+	desc *description.Session,
+	typ description.MediaType,
+	forma format.Format,
+	used map[format.Format]struct{},
+) (*description.Media, format.Format) {
+	for _, media := range desc.Medias {
+		if media.Type != typ {
+			continue
+		}
+
+		for _, candidate := range media.Formats {
+			if _, ok := used[candidate]; ok {
+				continue
+			}
+
+			if FormatsCompatible(candidate, forma) {
+				return media, candidate
+			}
+		}
+	}
+
+	return nil, nil
 }
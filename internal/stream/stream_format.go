@@ -25,6 +25,7 @@ func unitSize(u *unit.Unit) uint64 {
 
 type streamFormat struct {
 	rtpMaxPayloadSize  int
+	media              *description.Media
 	format             format.Format
 	generateRTPPackets bool
 	fillNTP            bool
@@ -34,6 +35,17 @@ type streamFormat struct {
 	proc         codecprocessor.Processor
 	ntpEstimator *ntpestimator.Estimator
 	onDatas      map[*Reader]OnDataFunc
+	stats        *trackStats
+
+	// used by splicer
+	rtpInitialized bool
+	rtpResync      bool
+	rtpSSRC        uint32
+	rtpSeqOffset   uint16
+	rtpTSOffset    uint32
+	rtpLastSeq     uint16
+	rtpLastTS      uint32
+	rtpLastPTS     int64
 }
 
 func (sf *streamFormat) initialize() error {
@@ -49,6 +61,12 @@ func (sf *streamFormat) initialize() error {
 		ClockRate: sf.format.ClockRate(),
 	}
 
+	sf.stats = &trackStats{
+		media: sf.media,
+		forma: sf.format,
+	}
+	sf.stats.initialize()
+
 	return nil
 }
 
@@ -69,7 +87,11 @@ func (sf *streamFormat) writeRTPPacket(
 	ntp time.Time,
 	pts int64,
 ) {
-	hasNonRTSPReaders := len(sf.onDatas) > 0
+	// decode RTP packets when waiting for a key frame, in order to find it,
+	// and when they have to be cached or time-shifted, in order to be sent to readers later.
+	// Statistics do not need decoded packets.
+	hasNonRTSPReaders := len(sf.onDatas) > 0 || s.gopCache != nil || s.timeShift != nil ||
+		(s.Spliceable && s.splicer.isWaitingKeyFrame())
 
 	u := &unit.Unit{
 		PTS:        pts,
@@ -86,7 +108,38 @@ func (sf *streamFormat) writeRTPPacket(
 	sf.writeUnitInner(s, medi, u)
 }
 
+// rewriteRTPPackets keeps SSRC, sequence numbers and timestamps of RTP packets
+// continuous when the source of the stream changes.
+func (sf *streamFormat) rewriteRTPPackets(u *unit.Unit) {
+	for i, pkt := range u.RTPPackets {
+		switch {
+		case !sf.rtpInitialized:
+			sf.rtpInitialized = true
+			sf.rtpResync = false
+			sf.rtpSSRC = pkt.SSRC
+
+		case sf.rtpResync && i == 0:
+			sf.rtpResync = false
+			sf.rtpSeqOffset = sf.rtpLastSeq + 1 - pkt.SequenceNumber
+			sf.rtpTSOffset = sf.rtpLastTS + uint32(u.PTS-sf.rtpLastPTS) - pkt.Timestamp
+		}
+
+		pkt.PayloadType = sf.format.PayloadType()
+		pkt.SSRC = sf.rtpSSRC
+		pkt.SequenceNumber += sf.rtpSeqOffset
+		pkt.Timestamp += sf.rtpTSOffset
+
+		sf.rtpLastSeq = pkt.SequenceNumber
+		sf.rtpLastTS = pkt.Timestamp
+		sf.rtpLastPTS = u.PTS
+	}
+}
+
 func (sf *streamFormat) writeUnitInner(s *Stream, medi *description.Media, u *unit.Unit) {
+	if s.Spliceable && !s.splicer.process(sf, u) {
+		return
+	}
+
 	if sf.fillNTP {
 		u.NTP = sf.ntpEstimator.Estimate(u.PTS)
 	}
@@ -95,6 +148,8 @@ func (sf *streamFormat) writeUnitInner(s *Stream, medi *description.Media, u *un
 
 	atomic.AddUint64(s.bytesReceived, size)
 
+	sf.stats.add(u, size)
+
 	if s.rtspStream != nil {
 		for _, pkt := range u.RTPPackets {
 			s.rtspStream.WritePacketRTPWithNTP(medi, pkt, u.NTP) //nolint:errcheck
@@ -107,14 +162,15 @@ func (sf *streamFormat) writeUnitInner(s *Stream, medi *description.Media, u *un
 		}
 	}
 
+	if s.gopCache != nil {
+		s.gopCache.add(sf, u, size)
+	}
+
+	if s.timeShift != nil {
+		s.timeShift.add(sf, u, size)
+	}
+
 	for sr, onData := range sf.onDatas {
-		csr := sr
-		cOnData := onData
-		sr.push(func() error {
-			if !csr.SkipBytesSent {
-				atomic.AddUint64(s.bytesSent, size)
-			}
-			return cOnData(u)
-		})
+		s.pushUnit(sr, sf.format, onData, u, size)
 	}
 }
@@ -0,0 +1,92 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type gopCacheEntry struct {
+	seq  uint64
+	sf   *streamFormat
+	u    *unit.Unit
+	size uint64
+}
+
+// gopCache stores units received since the last key frame of every video format,
+// in order to send them to new readers, that can then start decoding immediately.
+type gopCache struct {
+	maxSize uint64
+
+	mutex   sync.Mutex
+	active  bool
+	entries []gopCacheEntry
+	size    uint64
+	seq     uint64
+	starts  map[*streamFormat]uint64
+}
+
+func (gc *gopCache) initialize() {
+	gc.starts = make(map[*streamFormat]uint64)
+}
+
+func (gc *gopCache) add(sf *streamFormat, u *unit.Unit, size uint64) {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+
+	if FormatHasKeyFrames(sf.format) && IsKeyFrame(sf.format, u) {
+		gc.starts[sf] = gc.seq
+		gc.active = true
+		gc.trim()
+	}
+
+	// cache is filled starting from a key frame
+	if !gc.active {
+		return
+	}
+
+	// when the cache is full, it is emptied and filled again starting from the next key frame
+	if (gc.size + size) > gc.maxSize {
+		gc.reset()
+		return
+	}
+
+	gc.entries = append(gc.entries, gopCacheEntry{
+		seq:  gc.seq,
+		sf:   sf,
+		u:    u,
+		size: size,
+	})
+	gc.size += size
+	gc.seq++
+}
+
+// trim removes entries that precede the last key frame of all formats.
+func (gc *gopCache) trim() {
+	start := gc.seq
+	for _, v := range gc.starts {
+		start = min(start, v)
+	}
+
+	i := 0
+	for i < len(gc.entries) && gc.entries[i].seq < start {
+		gc.size -= gc.entries[i].size
+		i++
+	}
+
+	gc.entries = append([]gopCacheEntry(nil), gc.entries[i:]...)
+}
+
+func (gc *gopCache) reset() {
+	gc.active = false
+	gc.entries = nil
+	gc.size = 0
+	gc.starts = make(map[*streamFormat]uint64)
+}
+
+func (gc *gopCache) get() []gopCacheEntry {
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+
+	return append([]gopCacheEntry(nil), gc.entries...)
+}
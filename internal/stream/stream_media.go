@@ -24,6 +24,7 @@ func (sm *streamMedia) initialize() error {
 	for _, forma := range sm.media.Formats {
 		sf := &streamFormat{
 			rtpMaxPayloadSize:  sm.rtpMaxPayloadSize,
+			media:              sm.media,
 			format:             forma,
 			generateRTPPackets: sm.generateRTPPackets,
 			fillNTP:            sm.fillNTP,
@@ -0,0 +1,107 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+// spliceGap is the time between the last unit of the previous source and the first unit of the next one.
+const spliceGap = 20 * time.Millisecond
+
+func multiplyAndDivide(v, m, d int64) int64 {
+	secs := v / d
+	dec := v % d
+	return (secs*m + dec*m/d)
+}
+
+func timestampToDuration(t int64, clockRate int) time.Duration {
+	return time.Duration(multiplyAndDivide(t, int64(time.Second), int64(clockRate)))
+}
+
+func durationToTimestamp(d time.Duration, clockRate int) int64 {
+	return multiplyAndDivide(int64(d), int64(clockRate), int64(time.Second))
+}
+
+// FormatHasKeyFrames checks whether a format is made of key frames and non-key frames.
+func FormatHasKeyFrames(forma format.Format) bool {
+	switch forma.(type) {
+	case *format.H264, *format.H265:
+		return true
+	}
+	return false
+}
+
+// IsKeyFrame checks whether a unit contains a key frame.
+func IsKeyFrame(forma format.Format, u *unit.Unit) bool {
+	switch forma.(type) {
+	case *format.H264:
+		au, ok := u.Payload.(unit.PayloadH264)
+		return ok && h264.IsRandomAccess(au)
+
+	case *format.H265:
+		au, ok := u.Payload.(unit.PayloadH265)
+		return ok && h265.IsRandomAccess(au)
+	}
+	return false
+}
+
+// splicer allows to switch the source of a stream
+// while keeping timestamps and RTP packets continuous.
+type splicer struct {
+	mutex           sync.Mutex
+	resync          bool
+	waitingKeyFrame bool
+	offset          time.Duration
+	lastTime        time.Duration
+}
+
+func (sp *splicer) splice(waitKeyFrame bool) {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	sp.resync = true
+	sp.waitingKeyFrame = waitKeyFrame
+}
+
+func (sp *splicer) isWaitingKeyFrame() bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	return sp.waitingKeyFrame
+}
+
+// process shifts the timestamp of a unit and rewrites its RTP packets.
+// It returns false when the unit must be discarded.
+func (sp *splicer) process(sf *streamFormat, u *unit.Unit) bool {
+	sp.mutex.Lock()
+	defer sp.mutex.Unlock()
+
+	if sp.waitingKeyFrame {
+		if !IsKeyFrame(sf.format, u) {
+			return false
+		}
+		sp.waitingKeyFrame = false
+	}
+
+	clockRate := sf.format.ClockRate()
+	t := timestampToDuration(u.PTS, clockRate)
+
+	if sp.resync {
+		sp.offset = sp.lastTime + spliceGap - t
+		sp.resync = false
+	}
+
+	t += sp.offset
+	u.PTS = durationToTimestamp(t, clockRate)
+	sp.lastTime = max(sp.lastTime, t)
+
+	sf.rewriteRTPPackets(u)
+
+	return true
+}
@@ -0,0 +1,441 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v5/pkg/description"
+	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/av1"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/v2/pkg/codecs/mpeg4audio"
+
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+const (
+	statsWindow = 1 * time.Second
+)
+
+var h264ProfileNames = map[uint8]string{
+	66:  "Baseline",
+	77:  "Main",
+	88:  "Extended",
+	100: "High",
+	110: "High 10",
+	122: "High 4:2:2",
+	244: "High 4:4:4 Predictive",
+}
+
+var h265ProfileNames = map[uint8]string{
+	1: "Main",
+	2: "Main 10",
+	3: "Main Still Picture",
+	4: "Format Range Extensions",
+}
+
+var av1ProfileNames = map[uint8]string{
+	0: "Main",
+	1: "High",
+	2: "Professional",
+}
+
+var chromaFormatNames = map[uint32]string{
+	0: "4:0:0",
+	1: "4:2:0",
+	2: "4:2:2",
+	3: "4:4:4",
+}
+
+// H264 profiles that contain chroma_format_idc in the SPS.
+var h264ChromaProfiles = map[uint8]struct{}{
+	100: {}, 110: {}, 122: {}, 244: {}, 44: {}, 83: {}, 86: {}, 118: {}, 128: {}, 138: {}, 139: {}, 134: {}, 135: {},
+}
+
+// TrackStats are statistics of a track.
+type TrackStats struct {
+	Codec            string
+	Width            int
+	Height           int
+	Profile          string
+	Level            string
+	ChromaFormat     string
+	SampleRate       int
+	ChannelCount     int
+	FPS              float64
+	KeyFrameInterval time.Duration
+	BFrames          bool
+	Bitrate          uint64
+	AverageBitrate   uint64
+}
+
+func isStatsKeyFrame(forma format.Format, u *unit.Unit) bool {
+	if tu, ok := u.Payload.(unit.PayloadAV1); ok {
+		return av1.IsRandomAccess2(tu)
+	}
+	return FormatHasKeyFrames(forma) && IsKeyFrame(forma, u)
+}
+
+func isH264KeyFrameNALU(nalu []byte) bool {
+	return len(nalu) != 0 && h264.NALUType(nalu[0]&0x1F) == h264.NALUTypeIDR
+}
+
+func isH265KeyFrameType(typ h265.NALUType) bool {
+	switch typ {
+	case h265.NALUType_IDR_W_RADL, h265.NALUType_IDR_N_LP, h265.NALUType_CRA_NUT:
+		return true
+	}
+	return false
+}
+
+func isH265KeyFrameNALU(nalu []byte) bool {
+	return len(nalu) != 0 && isH265KeyFrameType(h265.NALUType((nalu[0]>>1)&0b111111))
+}
+
+// rtpAggregatedNALUs returns NALUs contained in a STAP-A (H264) or AP (H265) packet.
+func rtpAggregatedNALUs(payload []byte) [][]byte {
+	var ret [][]byte
+
+	for len(payload) >= 2 {
+		size := int(payload[0])<<8 | int(payload[1])
+		payload = payload[2:]
+
+		if size == 0 || size > len(payload) {
+			break
+		}
+
+		ret = append(ret, payload[:size])
+		payload = payload[size:]
+	}
+
+	return ret
+}
+
+// rtpIsKeyFrame checks whether a RTP packet contains the beginning of a key frame,
+// without decoding the payload.
+func rtpIsKeyFrame(forma format.Format, payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+
+	switch forma.(type) {
+	case *format.H264:
+		switch payload[0] & 0x1F {
+		case 24: // STAP-A
+			for _, nalu := range rtpAggregatedNALUs(payload[1:]) {
+				if isH264KeyFrameNALU(nalu) {
+					return true
+				}
+			}
+			return false
+
+		case 28: // FU-A
+			return len(payload) >= 2 && (payload[1]&0x80) != 0 &&
+				h264.NALUType(payload[1]&0x1F) == h264.NALUTypeIDR
+
+		default:
+			return isH264KeyFrameNALU(payload)
+		}
+
+	case *format.H265:
+		if len(payload) < 2 {
+			return false
+		}
+
+		switch (payload[0] >> 1) & 0b111111 {
+		case 48: // AP
+			for _, nalu := range rtpAggregatedNALUs(payload[2:]) {
+				if isH265KeyFrameNALU(nalu) {
+					return true
+				}
+			}
+			return false
+
+		case 49: // FU
+			return len(payload) >= 3 && (payload[2]&0x80) != 0 &&
+				isH265KeyFrameType(h265.NALUType(payload[2]&0b111111))
+
+		default:
+			return isH265KeyFrameNALU(payload)
+		}
+
+	case *format.AV1:
+		// N bit of the aggregation header, set in the first packet of a coded video sequence
+		return (payload[0] & 0x08) != 0
+	}
+
+	return false
+}
+
+// rtpAV1SequenceHeader returns the sequence header contained in the first OBU of a AV1 RTP packet, if present.
+func rtpAV1SequenceHeader(payload []byte) []byte {
+	if len(payload) < 2 {
+		return nil
+	}
+
+	z := (payload[0] & 0x80) != 0
+	y := (payload[0] & 0x40) != 0
+	w := (payload[0] >> 4) & 0b11
+	payload = payload[1:]
+
+	// first OBU is a continuation of the previous packet
+	if z {
+		return nil
+	}
+
+	var obu []byte
+
+	if w == 1 {
+		// first OBU continues in the next packet
+		if y {
+			return nil
+		}
+		obu = payload
+	} else {
+		var size av1.LEB128
+		n, err := size.Unmarshal(payload)
+		if err != nil || int(size) > len(payload[n:]) {
+			return nil
+		}
+		obu = payload[n : n+int(size)]
+	}
+
+	if len(obu) == 0 || av1.OBUType((obu[0]>>3)&0b1111) != av1.OBUTypeSequenceHeader {
+		return nil
+	}
+
+	return obu
+}
+
+// trackStats measures statistics of a track.
+type trackStats struct {
+	media *description.Media
+	forma format.Format
+
+	mutex            sync.Mutex
+	start            time.Time
+	bytes            uint64
+	windowStart      time.Time
+	windowBytes      uint64
+	windowFrames     uint64
+	bitrate          uint64
+	fps              float64
+	hasPTS           bool
+	lastPTS          int64
+	hasKeyFrame      bool
+	lastKeyFramePTS  int64
+	keyFrameInterval time.Duration
+	bFrames          bool
+	av1SeqHeader     []byte
+}
+
+func (ts *trackStats) initialize() {
+	ts.start = time.Now()
+	ts.windowStart = ts.start
+}
+
+func (ts *trackStats) add(u *unit.Unit, size uint64) {
+	now := time.Now()
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.bytes += size
+	ts.windowBytes += size
+	ts.roll(now)
+
+	if ts.media.Type != description.MediaTypeVideo {
+		return
+	}
+
+	if u.NilPayload() {
+		// payload is not decoded: key frames and parameters are extracted from RTP packets,
+		// while the end of a frame is signaled by the RTP marker
+		for _, pkt := range u.RTPPackets {
+			if _, ok := ts.forma.(*format.AV1); ok {
+				if sh := rtpAV1SequenceHeader(pkt.Payload); sh != nil {
+					ts.av1SeqHeader = sh
+				}
+			}
+
+			if rtpIsKeyFrame(ts.forma, pkt.Payload) {
+				ts.addKeyFrame(u.PTS)
+			}
+		}
+
+		if len(u.RTPPackets) == 0 || !u.RTPPackets[len(u.RTPPackets)-1].Marker {
+			return
+		}
+	} else {
+		if tu, ok := u.Payload.(unit.PayloadAV1); ok {
+			for _, obu := range tu {
+				if av1.OBUType((obu[0]>>3)&0b1111) == av1.OBUTypeSequenceHeader {
+					ts.av1SeqHeader = obu
+				}
+			}
+		}
+
+		if isStatsKeyFrame(ts.forma, u) {
+			ts.addKeyFrame(u.PTS)
+		}
+	}
+
+	ts.windowFrames++
+
+	// B-frames cause timestamps to be out of order
+	if ts.hasPTS && u.PTS < ts.lastPTS {
+		ts.bFrames = true
+	}
+	ts.lastPTS = u.PTS
+	ts.hasPTS = true
+}
+
+func (ts *trackStats) addKeyFrame(pts int64) {
+	if ts.hasKeyFrame && pts > ts.lastKeyFramePTS {
+		ts.keyFrameInterval = time.Duration(pts-ts.lastKeyFramePTS) *
+			time.Second / time.Duration(ts.forma.ClockRate())
+	}
+	ts.lastKeyFramePTS = pts
+	ts.hasKeyFrame = true
+}
+
+// roll computes the instantaneous bitrate and frame rate at the end of every window.
+func (ts *trackStats) roll(now time.Time) {
+	elapsed := now.Sub(ts.windowStart)
+	if elapsed < statsWindow {
+		return
+	}
+
+	ts.bitrate = uint64(float64(ts.windowBytes*8) / elapsed.Seconds())
+	ts.fps = float64(ts.windowFrames) / elapsed.Seconds()
+	ts.windowStart = now
+	ts.windowBytes = 0
+	ts.windowFrames = 0
+}
+
+func (ts *trackStats) get() TrackStats {
+	now := time.Now()
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	ts.roll(now)
+
+	ret := TrackStats{
+		Codec:            ts.forma.Codec(),
+		KeyFrameInterval: ts.keyFrameInterval,
+		BFrames:          ts.bFrames,
+		Bitrate:          ts.bitrate,
+	}
+
+	if elapsed := now.Sub(ts.start); elapsed > 0 {
+		ret.AverageBitrate = uint64(float64(ts.bytes*8) / elapsed.Seconds())
+	}
+
+	if ts.media.Type == description.MediaTypeVideo {
+		ret.FPS = ts.fps
+	}
+
+	fillFormatStats(&ret, ts.forma, ts.av1SeqHeader)
+
+	return ret
+}
+
+// fillFormatStats fills statistics that can be extracted from parameters of a format.
+func fillFormatStats(ret *TrackStats, forma format.Format, av1SeqHeader []byte) {
+	switch forma := forma.(type) {
+	case *format.H264:
+		spsBuf, _ := forma.SafeParams()
+		var sps h264.SPS
+		if spsBuf != nil && sps.Unmarshal(spsBuf) == nil {
+			ret.Width = sps.Width()
+			ret.Height = sps.Height()
+			ret.Profile = profileName(h264ProfileNames, sps.ProfileIdc)
+			ret.Level = fmt.Sprintf("%d.%d", sps.LevelIdc/10, sps.LevelIdc%10)
+
+			chromaFormat := uint32(1)
+			if _, ok := h264ChromaProfiles[sps.ProfileIdc]; ok {
+				chromaFormat = sps.ChromaFormatIdc
+			}
+			ret.ChromaFormat = chromaFormatNames[chromaFormat]
+		}
+
+	case *format.H265:
+		_, spsBuf, _ := forma.SafeParams()
+		var sps h265.SPS
+		if spsBuf != nil && sps.Unmarshal(spsBuf) == nil {
+			ret.Width = sps.Width()
+			ret.Height = sps.Height()
+			ret.Profile = profileName(h265ProfileNames, sps.ProfileTierLevel.GeneralProfileIdc)
+			level := int(sps.ProfileTierLevel.GeneralLevelIdc) / 3
+			ret.Level = fmt.Sprintf("%d.%d", level/10, level%10)
+			ret.ChromaFormat = chromaFormatNames[sps.ChromaFormatIdc]
+		}
+
+	case *format.AV1:
+		var sh av1.SequenceHeader
+		if av1SeqHeader != nil && sh.Unmarshal(av1SeqHeader) == nil {
+			ret.Width = sh.Width()
+			ret.Height = sh.Height()
+			ret.Profile = profileName(av1ProfileNames, sh.SeqProfile)
+			if len(sh.SeqLevelIdx) != 0 {
+				ret.Level = fmt.Sprintf("%d.%d", 2+(sh.SeqLevelIdx[0]>>2), sh.SeqLevelIdx[0]&3)
+			}
+
+			switch {
+			case sh.ColorConfig.MonoChrome:
+				ret.ChromaFormat = chromaFormatNames[0]
+			case sh.ColorConfig.SubsamplingX && sh.ColorConfig.SubsamplingY:
+				ret.ChromaFormat = chromaFormatNames[1]
+			case sh.ColorConfig.SubsamplingX:
+				ret.ChromaFormat = chromaFormatNames[2]
+			default:
+				ret.ChromaFormat = chromaFormatNames[3]
+			}
+		}
+
+	case *format.MPEG4Audio:
+		if forma.Config != nil {
+			ret.SampleRate = forma.Config.SampleRate
+			ret.ChannelCount = forma.Config.ChannelCount
+		}
+
+	case *format.MPEG4AudioLATM:
+		if conf := latmAudioConfig(forma.StreamMuxConfig); conf != nil {
+			ret.SampleRate = conf.SampleRate
+			ret.ChannelCount = conf.ChannelCount
+		}
+
+	case *format.Opus:
+		ret.SampleRate = forma.ClockRate()
+		ret.ChannelCount = forma.ChannelCount
+
+	case *format.G711:
+		ret.SampleRate = forma.SampleRate
+		ret.ChannelCount = forma.ChannelCount
+
+	case *format.LPCM:
+		ret.SampleRate = forma.SampleRate
+		ret.ChannelCount = forma.ChannelCount
+
+	case *format.AC3:
+		ret.SampleRate = forma.SampleRate
+		ret.ChannelCount = forma.ChannelCount
+	}
+}
+
+func profileName(names map[uint8]string, idc uint8) string {
+	if name, ok := names[idc]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", idc)
+}
+
+func latmAudioConfig(smc *mpeg4audio.StreamMuxConfig) *mpeg4audio.AudioSpecificConfig {
+	if smc == nil || len(smc.Programs) == 0 || len(smc.Programs[0].Layers) == 0 {
+		return nil
+	}
+	return smc.Programs[0].Layers[0].AudioSpecificConfig
+}
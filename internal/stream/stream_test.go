@@ -2,10 +2,13 @@ package stream
 
 import (
 	"testing"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v5/pkg/description"
 	"github.com/bluenviron/gortsplib/v5/pkg/format"
+	"github.com/bluenviron/mediamtx/internal/logger"
 	"github.com/bluenviron/mediamtx/internal/unit"
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -104,3 +107,474 @@ func TestStreamSkipBytesSent(t *testing.T) {
 	require.Equal(t, uint64(14), strm.BytesReceived())
 	require.Equal(t, uint64(0), strm.BytesSent())
 }
+
+func TestStreamSplice(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{}},
+		},
+	}}
+
+	strm := &Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Spliceable:         true,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	r := &Reader{}
+
+	recv := make(chan *unit.Unit, 10)
+
+	r.OnData(desc.Medias[0], desc.Medias[0].Formats[0], func(u *unit.Unit) error {
+		recv <- u
+		return nil
+	})
+
+	strm.AddReader(r)
+	defer strm.RemoveReader(r)
+
+	strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+		PTS:     90000,
+		Payload: unit.PayloadH264{{5, 1}},
+	})
+
+	u := <-recv
+	require.Equal(t, int64(90000), u.PTS)
+	ssrc := u.RTPPackets[0].SSRC
+	seq := u.RTPPackets[0].SequenceNumber
+	ts := u.RTPPackets[0].Timestamp
+
+	newDesc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{}},
+		},
+	}}
+
+	err = strm.SpliceSource(newDesc, true)
+	require.NoError(t, err)
+
+	// non-key frames are discarded
+	strm.WriteUnit(newDesc.Medias[0], newDesc.Medias[0].Formats[0], &unit.Unit{
+		PTS:     500,
+		Payload: unit.PayloadH264{{1, 2}},
+	})
+
+	strm.WriteUnit(newDesc.Medias[0], newDesc.Medias[0].Formats[0], &unit.Unit{
+		PTS:     1000,
+		Payload: unit.PayloadH264{{5, 2}},
+	})
+
+	u = <-recv
+	require.Equal(t, unit.PayloadH264{{5, 2}}, u.Payload)
+	require.Equal(t, int64(90000+1800), u.PTS)
+	require.Equal(t, ssrc, u.RTPPackets[0].SSRC)
+	require.Equal(t, seq+1, u.RTPPackets[0].SequenceNumber)
+	require.Equal(t, ts+1800, u.RTPPackets[0].Timestamp)
+
+	err = strm.SpliceSource(&description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{&format.VP8{}},
+		},
+	}}, true)
+	require.Error(t, err)
+}
+
+func TestStreamGOPCache(t *testing.T) {
+	for _, ca := range []string{"regular", "overflow"} {
+		t.Run(ca, func(t *testing.T) {
+			desc := &description.Session{Medias: []*description.Media{
+				{
+					Type:    description.MediaTypeVideo,
+					Formats: []format.Format{&format.H264{}},
+				},
+				{
+					Type:    description.MediaTypeAudio,
+					Formats: []format.Format{&format.Opus{ChannelCount: 2}},
+				},
+			}}
+
+			strm := &Stream{
+				WriteQueueSize:     512,
+				RTPMaxPayloadSize:  1450,
+				Desc:               desc,
+				GenerateRTPPackets: true,
+				GOPCache:           true,
+				GOPCacheMaxSize: func() uint64 {
+					if ca == "overflow" {
+						return 40
+					}
+					return 1024
+				}(),
+			}
+			err := strm.Initialize()
+			require.NoError(t, err)
+			defer strm.Close()
+
+			for _, u := range []struct {
+				media int
+				u     *unit.Unit
+			}{
+				{0, &unit.Unit{PTS: 0, Payload: unit.PayloadH264{{5, 1}}}},
+				{0, &unit.Unit{PTS: 3000, Payload: unit.PayloadH264{{1, 1}}}},
+				{1, &unit.Unit{PTS: 1000, Payload: unit.PayloadOpus{{1}}}},
+				{0, &unit.Unit{PTS: 6000, Payload: unit.PayloadH264{{5, 2}}}},
+				{1, &unit.Unit{PTS: 2000, Payload: unit.PayloadOpus{{2}}}},
+				{0, &unit.Unit{PTS: 9000, Payload: unit.PayloadH264{{1, 2}}}},
+			} {
+				strm.WriteUnit(desc.Medias[u.media], desc.Medias[u.media].Formats[0], u.u)
+			}
+
+			r := &Reader{}
+
+			recv := make(chan *unit.Unit, 10)
+
+			for _, medi := range desc.Medias {
+				r.OnData(medi, medi.Formats[0], func(u *unit.Unit) error {
+					recv <- u
+					return nil
+				})
+			}
+
+			strm.AddReader(r)
+			defer strm.RemoveReader(r)
+
+			strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+				PTS:     12000,
+				Payload: unit.PayloadH264{{1, 3}},
+			})
+
+			var received []unit.Payload
+
+			for {
+				u := <-recv
+				received = append(received, u.Payload)
+				if u.PTS == 12000 {
+					break
+				}
+			}
+
+			if ca == "regular" {
+				require.Equal(t, []unit.Payload{
+					unit.PayloadH264{{5, 2}},
+					unit.PayloadOpus{{2}},
+					unit.PayloadH264{{1, 2}},
+					unit.PayloadH264{{1, 3}},
+				}, received)
+			} else {
+				require.Equal(t, []unit.Payload{
+					unit.PayloadH264{{1, 3}},
+				}, received)
+			}
+		})
+	}
+}
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...any) {
+}
+
+func TestStreamReaderSkip(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{}},
+		},
+		{
+			Type:    description.MediaTypeAudio,
+			Formats: []format.Format{&format.Opus{ChannelCount: 2}},
+		},
+	}}
+
+	strm := &Stream{
+		WriteQueueSize:     4,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		Parent:             nilLogger{},
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	var skips uint64
+	r := &Reader{
+		Skips:  &skips,
+		Parent: nilLogger{},
+	}
+
+	recv := make(chan *unit.Unit, 10)
+	pulled := make(chan struct{})
+	unblock := make(chan struct{})
+	first := true
+
+	for _, medi := range desc.Medias {
+		r.OnData(medi, medi.Formats[0], func(u *unit.Unit) error {
+			if first {
+				first = false
+				close(pulled)
+				<-unblock
+			}
+			recv <- u
+			return nil
+		})
+	}
+
+	strm.AddReader(r)
+	defer strm.RemoveReader(r)
+
+	writeVideo := func(pts int64, payload []byte) {
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+			PTS:     pts,
+			Payload: unit.PayloadH264{payload},
+		})
+	}
+
+	writeAudio := func(pts int64) {
+		strm.WriteUnit(desc.Medias[1], desc.Medias[1].Formats[0], &unit.Unit{
+			PTS:     pts,
+			Payload: unit.PayloadOpus{{1}},
+		})
+	}
+
+	writeVideo(1, []byte{5, 1})
+	<-pulled
+
+	writeVideo(2, []byte{1, 2})
+	writeVideo(3, []byte{1, 3})
+	writeVideo(4, []byte{1, 4})
+
+	// queue is full for video, remaining room is reserved to audio
+	writeVideo(5, []byte{1, 5})
+	require.Equal(t, uint64(1), strm.ReaderSkips())
+	require.Equal(t, uint64(1), skips)
+
+	writeAudio(6)
+
+	close(unblock)
+
+	for _, pts := range []int64{1, 2, 3, 4, 6} {
+		u := <-recv
+		require.Equal(t, pts, u.PTS)
+	}
+
+	// non-key frames are discarded until the next key frame, while audio is kept
+	writeVideo(7, []byte{1, 7})
+	writeAudio(8)
+	require.Equal(t, int64(8), (<-recv).PTS)
+
+	writeVideo(9, []byte{5, 9})
+	require.Equal(t, int64(9), (<-recv).PTS)
+
+	writeVideo(10, []byte{1, 10})
+	require.Equal(t, int64(10), (<-recv).PTS)
+
+	require.Equal(t, uint64(1), strm.ReaderSkips())
+	require.Equal(t, uint64(1), skips)
+}
+
+func TestStreamTimeShift(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type:    description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{}},
+		},
+	}}
+
+	strm := &Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+		TimeShift:          true,
+		TimeShiftDuration:  10 * time.Second,
+		TimeShiftMaxSize:   1024,
+		Parent:             nilLogger{},
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	writeUnit := func(pts int64, payload unit.PayloadH264) {
+		strm.WriteUnit(desc.Medias[0], desc.Medias[0].Formats[0], &unit.Unit{
+			PTS:     pts,
+			Payload: payload,
+		})
+	}
+
+	writeUnit(0, unit.PayloadH264{{5, 1}})
+	writeUnit(3000, unit.PayloadH264{{1, 1}})
+	time.Sleep(150 * time.Millisecond)
+	writeUnit(6000, unit.PayloadH264{{5, 2}})
+	writeUnit(9000, unit.PayloadH264{{1, 2}})
+	time.Sleep(150 * time.Millisecond)
+
+	r := &Reader{
+		Delay:  200 * time.Millisecond,
+		Parent: nilLogger{},
+	}
+
+	recv := make(chan *unit.Unit, 10)
+
+	r.OnData(desc.Medias[0], desc.Medias[0].Formats[0], func(u *unit.Unit) error {
+		recv <- u
+		return nil
+	})
+
+	strm.AddReader(r)
+	defer strm.RemoveReader(r)
+
+	start := time.Now()
+	writeUnit(12000, unit.PayloadH264{{1, 3}})
+
+	var received []unit.Payload
+
+	for {
+		u := <-recv
+		received = append(received, u.Payload)
+		if u.PTS == 12000 {
+			break
+		}
+	}
+
+	// reading starts from the last key frame received before the delay
+	require.Equal(t, []unit.Payload{
+		unit.PayloadH264{{5, 1}},
+		unit.PayloadH264{{1, 1}},
+		unit.PayloadH264{{5, 2}},
+		unit.PayloadH264{{1, 2}},
+		unit.PayloadH264{{1, 3}},
+	}, received)
+
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestStreamTrackStats(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{
+				PayloadTyp: 96,
+				SPS: []byte{ // 1920x1080 baseline
+					0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+					0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+					0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20,
+				},
+				PPS:               []byte{0x08, 0x06, 0x07, 0x08},
+				PacketizationMode: 1,
+			}},
+		},
+		{
+			Type:    description.MediaTypeAudio,
+			Formats: []format.Format{&format.Opus{PayloadTyp: 97, ChannelCount: 2}},
+		},
+	}}
+
+	strm := &Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: true,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	for _, u := range []struct {
+		media int
+		u     *unit.Unit
+	}{
+		{0, &unit.Unit{PTS: 0, Payload: unit.PayloadH264{{5, 1}}}},
+		{0, &unit.Unit{PTS: 6000, Payload: unit.PayloadH264{{1, 1}}}},
+		{0, &unit.Unit{PTS: 3000, Payload: unit.PayloadH264{{1, 2}}}},
+		{1, &unit.Unit{PTS: 0, Payload: unit.PayloadOpus{{1, 2, 3}}}},
+		{0, &unit.Unit{PTS: 180000, Payload: unit.PayloadH264{{5, 2}}}},
+	} {
+		strm.WriteUnit(desc.Medias[u.media], desc.Medias[u.media].Formats[0], u.u)
+	}
+
+	stats := strm.TrackStats()
+	require.Len(t, stats, 2)
+
+	require.Equal(t, "H264", stats[0].Codec)
+	require.Equal(t, 1920, stats[0].Width)
+	require.Equal(t, 1080, stats[0].Height)
+	require.Equal(t, "Baseline", stats[0].Profile)
+	require.Equal(t, "4.0", stats[0].Level)
+	require.Equal(t, "4:2:0", stats[0].ChromaFormat)
+	require.Equal(t, 2*time.Second, stats[0].KeyFrameInterval)
+	require.True(t, stats[0].BFrames)
+	require.NotZero(t, stats[0].AverageBitrate)
+
+	require.Equal(t, "Opus", stats[1].Codec)
+	require.Equal(t, 48000, stats[1].SampleRate)
+	require.Equal(t, 2, stats[1].ChannelCount)
+	require.Zero(t, stats[1].FPS)
+}
+
+func TestStreamTrackStatsRTP(t *testing.T) {
+	desc := &description.Session{Medias: []*description.Media{
+		{
+			Type: description.MediaTypeVideo,
+			Formats: []format.Format{&format.H264{
+				PayloadTyp: 96,
+				SPS: []byte{ // 1920x1080 baseline
+					0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02,
+					0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04,
+					0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20,
+				},
+				PPS:               []byte{0x08, 0x06, 0x07, 0x08},
+				PacketizationMode: 1,
+			}},
+		},
+	}}
+
+	strm := &Stream{
+		WriteQueueSize:     512,
+		RTPMaxPayloadSize:  1450,
+		Desc:               desc,
+		GenerateRTPPackets: false,
+	}
+	err := strm.Initialize()
+	require.NoError(t, err)
+	defer strm.Close()
+
+	// there are no readers, therefore packets are not decoded
+	for i, pkt := range []struct {
+		ts      uint32
+		marker  bool
+		payload []byte
+	}{
+		{0, false, []byte{0x1c, 0x85, 1}},                             // FU-A, start of IDR
+		{0, true, []byte{0x1c, 0x45, 2}},                              // FU-A, end of IDR
+		{3000, true, []byte{0x01, 3}},                                 // non-IDR
+		{180000, true, []byte{0x18, 0, 2, 0x09, 0xf0, 0, 2, 0x05, 4}}, // STAP-A with AUD and IDR
+	} {
+		strm.WriteRTPPacket(desc.Medias[0], desc.Medias[0].Formats[0], &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				Marker:         pkt.marker,
+				PayloadType:    96,
+				SequenceNumber: uint16(i),
+				Timestamp:      pkt.ts,
+			},
+			Payload: pkt.payload,
+		}, time.Time{}, int64(pkt.ts))
+	}
+
+	stats := strm.TrackStats()
+	require.Len(t, stats, 1)
+
+	require.Equal(t, 1920, stats[0].Width)
+	require.Equal(t, 1080, stats[0].Height)
+	require.Equal(t, 2*time.Second, stats[0].KeyFrameInterval)
+	require.False(t, stats[0].BFrames)
+}
@@ -0,0 +1,172 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bluenviron/mediamtx/internal/unit"
+)
+
+type timeShiftEntry struct {
+	time time.Time
+	sf   *streamFormat
+	u    *unit.Unit
+	size uint64
+}
+
+// timeShift stores units received in the last period of time,
+// in order to send them to readers with a delay.
+type timeShift struct {
+	duration time.Duration
+	maxSize  uint64
+
+	mutex   sync.Mutex
+	entries []timeShiftEntry
+	first   int
+	size    uint64
+}
+
+func (ts *timeShift) add(sf *streamFormat, u *unit.Unit, size uint64) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	now := time.Now()
+
+	ts.entries = append(ts.entries, timeShiftEntry{
+		time: now,
+		sf:   sf,
+		u:    u,
+		size: size,
+	})
+	ts.size += size
+
+	for len(ts.entries) != 0 &&
+		(now.Sub(ts.entries[0].time) > ts.duration || ts.size > ts.maxSize) {
+		ts.size -= ts.entries[0].size
+		ts.entries[0] = timeShiftEntry{}
+		ts.entries = ts.entries[1:]
+		ts.first++
+	}
+}
+
+// startPosition returns the position of the last key frame received before the given time.
+func (ts *timeShift) startPosition(t time.Time, cbs map[*streamFormat]OnDataFunc) int {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	hasKeyFrames := false
+	for sf := range cbs {
+		if FormatHasKeyFrames(sf.format) {
+			hasKeyFrames = true
+		}
+	}
+
+	for i := len(ts.entries) - 1; i >= 0; i-- {
+		entry := ts.entries[i]
+
+		if entry.time.After(t) {
+			continue
+		}
+
+		if !hasKeyFrames {
+			return ts.first + i
+		}
+
+		if _, ok := cbs[entry.sf]; ok &&
+			FormatHasKeyFrames(entry.sf.format) && IsKeyFrame(entry.sf.format, entry.u) {
+			return ts.first + i
+		}
+	}
+
+	return ts.first
+}
+
+// get returns the entry in given position.
+// If the entry has been removed, it returns the oldest entry and its position.
+func (ts *timeShift) get(pos int) (timeShiftEntry, int, bool) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+
+	pos = max(pos, ts.first)
+
+	i := pos - ts.first
+	if i >= len(ts.entries) {
+		return timeShiftEntry{}, pos, false
+	}
+
+	return ts.entries[i], pos, true
+}
+
+// timeShiftReader sends units of a timeShift to a reader, with a delay.
+type timeShiftReader struct {
+	stream *Stream
+	ts     *timeShift
+	reader *Reader
+	cbs    map[*streamFormat]OnDataFunc
+
+	terminate chan struct{}
+	done      chan struct{}
+}
+
+func (tr *timeShiftReader) initialize() {
+	tr.terminate = make(chan struct{})
+	tr.done = make(chan struct{})
+
+	go tr.run()
+}
+
+func (tr *timeShiftReader) close() {
+	close(tr.terminate)
+	<-tr.done
+}
+
+func (tr *timeShiftReader) run() {
+	defer close(tr.done)
+
+	delay := tr.reader.Delay
+
+	// readers start decoding from a key frame
+	tr.reader.skipUntilKeyFrame()
+	pos := tr.ts.startPosition(time.Now().Add(-delay), tr.cbs)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		entry, newPos, ok := tr.ts.get(pos)
+
+		// entries have been removed before being sent
+		if newPos != pos {
+			tr.reader.skipUntilKeyFrame()
+			pos = newPos
+		}
+
+		var wait time.Duration
+		if ok {
+			wait = time.Until(entry.time.Add(delay))
+		} else {
+			// next entry will be received after now and will be sent after now + delay
+			wait = delay
+		}
+
+		if wait > 0 {
+			timer.Reset(wait)
+
+			select {
+			case <-timer.C:
+			case <-tr.terminate:
+				return
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		if cb, ok2 := tr.cbs[entry.sf]; ok2 {
+			tr.stream.pushUnit(tr.reader, entry.sf.format, cb, entry.u, entry.size)
+		}
+
+		pos++
+	}
+}
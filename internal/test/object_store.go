@@ -0,0 +1,121 @@
+package test
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ObjectStore is a minimal S3-compatible object storage,
+// that supports path-style PUT, HEAD, GET and DELETE requests.
+type ObjectStore struct {
+	server  *httptest.Server
+	mutex   sync.Mutex
+	objects map[string][]byte
+	fail    bool
+}
+
+// NewObjectStore allocates an ObjectStore.
+func NewObjectStore() *ObjectStore {
+	s := &ObjectStore{
+		objects: make(map[string][]byte),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Close closes the ObjectStore.
+func (s *ObjectStore) Close() {
+	s.server.Close()
+}
+
+// URL returns the endpoint of the ObjectStore.
+func (s *ObjectStore) URL() string {
+	return s.server.URL
+}
+
+// Object returns an object, in the form bucket/key.
+func (s *ObjectStore) Object(name string) ([]byte, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	byts, ok := s.objects[name]
+	return byts, ok
+}
+
+// SetFail sets whether requests should fail with an internal server error.
+func (s *ObjectStore) SetFail(v bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fail = v
+}
+
+func (s *ObjectStore) handle(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	s.mutex.Lock()
+	fail := s.fail
+	s.mutex.Unlock()
+
+	if fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/")
+
+	switch r.Method {
+	case http.MethodPut:
+		byts, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		sum := md5.Sum(byts) //nolint:gosec
+
+		if v := r.Header.Get("Content-Md5"); v != "" && v != base64.StdEncoding.EncodeToString(sum[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.mutex.Lock()
+		s.objects[name] = byts
+		s.mutex.Unlock()
+
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodHead, http.MethodGet:
+		byts, ok := s.Object(name)
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		sum := md5.Sum(byts) //nolint:gosec
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(byts))
+
+	case http.MethodDelete:
+		s.mutex.Lock()
+		delete(s.objects, name)
+		s.mutex.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -64,6 +64,14 @@ func TestAPIDocs(t *testing.T) {
 			"PathConf",
 			conf.Path{},
 		},
+		{
+			"RecordScheduleWindow",
+			conf.RecordScheduleWindow{},
+		},
+		{
+			"CompositeInput",
+			conf.CompositeInput{},
+		},
 		{
 			"PathConfList",
 			defs.APIPathConfList{},
@@ -72,6 +80,30 @@ func TestAPIDocs(t *testing.T) {
 			"Path",
 			defs.APIPath{},
 		},
+		{
+			"PathTrackStats",
+			defs.APIPathTrackStats{},
+		},
+		{
+			"PathRecordSchedule",
+			defs.APIPathRecordSchedule{},
+		},
+		{
+			"PathSwitcher",
+			defs.APIPathSwitcher{},
+		},
+		{
+			"PathSwitch",
+			defs.APIPathSwitch{},
+		},
+		{
+			"PathHealth",
+			defs.APIPathHealth{},
+		},
+		{
+			"PathHealthTrack",
+			defs.APIPathHealthTrack{},
+		},
 		{
 			"PathList",
 			defs.APIPathList{},
@@ -82,7 +114,7 @@ func TestAPIDocs(t *testing.T) {
 		},
 		{
 			"PathReader",
-			defs.APIPathSourceOrReader{},
+			defs.APIPathReader{},
 		},
 		{
 			"HLSMuxer",
@@ -100,10 +132,46 @@ func TestAPIDocs(t *testing.T) {
 			"RecordingList",
 			defs.APIRecordingList{},
 		},
+		{
+			"RecordingGap",
+			defs.APIRecordingGap{},
+		},
+		{
+			"RecordingLock",
+			defs.APIRecordingLock{},
+		},
+		{
+			"RecordingBookmark",
+			defs.APIRecordingBookmark{},
+		},
+		{
+			"RecordingBookmarkList",
+			defs.APIRecordingBookmarkList{},
+		},
+		{
+			"RecordingBookmarkAdd",
+			defs.APIRecordingBookmarkAdd{},
+		},
+		{
+			"RecordingBookmarkPatch",
+			defs.APIRecordingBookmarkPatch{},
+		},
+		{
+			"RecordingLockAdd",
+			defs.APIRecordingLockAdd{},
+		},
 		{
 			"RecordingSegment",
 			defs.APIRecordingSegment{},
 		},
+		{
+			"RecordingVerification",
+			defs.APIRecordingVerification{},
+		},
+		{
+			"RecordingVerificationSegment",
+			defs.APIRecordingVerificationSegment{},
+		},
 		{
 			"RTMPConn",
 			defs.APIRTMPConn{},